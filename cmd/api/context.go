@@ -11,18 +11,94 @@ type contextKey string
 
 const (
 	authenticatedUserContextKey = contextKey("authenticatedUser")
+	requestIDContextKey         = contextKey("requestID")
+	authMethodsContextKey       = contextKey("authMethods")
+	serviceAccountContextKey    = contextKey("serviceAccount")
+	impersonationContextKey     = contextKey("impersonation")
+	organizationContextKey      = contextKey("organization")
 )
 
-func contextSetAuthenticatedUser(r *http.Request, employee *database.Employee) *http.Request {
+func contextSetAuthenticatedUser(r *http.Request, employee *database.User) *http.Request {
 	ctx := context.WithValue(r.Context(), authenticatedUserContextKey, employee)
 	return r.WithContext(ctx)
 }
 
-func contextGetAuthenticatedUser(r *http.Request) *database.Employee {
-	employee, ok := r.Context().Value(authenticatedUserContextKey).(*database.Employee)
+func contextGetAuthenticatedUser(r *http.Request) *database.User {
+	employee, ok := r.Context().Value(authenticatedUserContextKey).(*database.User)
 	if !ok {
 		return nil
 	}
 
 	return employee
 }
+
+// contextSetAuthMethods records the authentication methods the authenticated
+// user went through to get their token.
+func contextSetAuthMethods(r *http.Request, methods []string) *http.Request {
+	ctx := context.WithValue(r.Context(), authMethodsContextKey, methods)
+	return r.WithContext(ctx)
+}
+
+func contextGetAuthMethods(r *http.Request) []string {
+	methods, _ := r.Context().Value(authMethodsContextKey).([]string)
+	return methods
+}
+
+// contextSetServiceAccount records the service account authenticated with an
+// API key. Requests are authenticated either as an employee or as a service
+// account, never both.
+func contextSetServiceAccount(r *http.Request, serviceAccount *serviceAccountPrincipal) *http.Request {
+	ctx := context.WithValue(r.Context(), serviceAccountContextKey, serviceAccount)
+	return r.WithContext(ctx)
+}
+
+func contextGetServiceAccount(r *http.Request) *serviceAccountPrincipal {
+	serviceAccount, _ := r.Context().Value(serviceAccountContextKey).(*serviceAccountPrincipal)
+	return serviceAccount
+}
+
+// contextSetImpersonation records that the authenticated user is impersonated
+// by an admin. contextGetAuthenticatedUser keeps returning the impersonated
+// employee, so handlers act as they would for them.
+func contextSetImpersonation(r *http.Request, impersonation *impersonation) *http.Request {
+	ctx := context.WithValue(r.Context(), impersonationContextKey, impersonation)
+	return r.WithContext(ctx)
+}
+
+func contextGetImpersonation(r *http.Request) *impersonation {
+	impersonation, _ := r.Context().Value(impersonationContextKey).(*impersonation)
+	return impersonation
+}
+
+// contextSetOrganization records the organization the request is for. The
+// queries run with the request context only see the rows of the organization.
+func contextSetOrganization(r *http.Request, organization *database.Organization) *http.Request {
+	ctx := context.WithValue(r.Context(), organizationContextKey, organization)
+	ctx = database.WithOrganization(ctx, organization.ID)
+	return r.WithContext(ctx)
+}
+
+func contextGetOrganization(r *http.Request) *database.Organization {
+	organization, ok := r.Context().Value(organizationContextKey).(*database.Organization)
+	if !ok {
+		panic("missing organization value in request context")
+	}
+
+	return organization
+}
+
+func contextSetRequestID(r *http.Request, requestID string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, requestID)
+	return r.WithContext(ctx)
+}
+
+func contextGetRequestID(r *http.Request) string {
+	return requestIDFromContext(r.Context())
+}
+
+// requestIDFromContext returns the ID of the request ctx derives from, which
+// includes the contexts of the background tasks it started.
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
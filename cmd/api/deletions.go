@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// purgeInterval is how often the deleted employees and roles past the
+// retention period are looked for.
+const purgeInterval = time.Hour
+
+// errDependentRecords is returned when purging an employee or a role other
+// records still reference. They're kept, deleted, and tried again at the next
+// purge.
+var errDependentRecords = errors.New("dependent records")
+
+type roleResponse struct {
+	ID          uuid.UUID `json:"id"`
+	DisplayName string    `json:"displayName"`
+	Description string    `json:"description"`
+}
+
+func newRoleResponse(role database.Role) roleResponse {
+	return roleResponse{
+		ID:          role.ID,
+		DisplayName: role.DisplayName,
+		Description: role.Description,
+	}
+}
+
+// deleteEmployeeHandler deletes the employee. They're hidden and can't sign in
+// any more, but keep their roles and the records referencing them until they're
+// restored or purged.
+func (app *application) deleteEmployeeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	var v validator.Validator
+
+	v.Check(id != contextGetAuthenticatedUser(r).ID, "employee_self", "You can't delete yourself")
+
+	if v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	organizationID := contextGetOrganization(r).ID
+
+	var (
+		employee   database.User
+		deliveries []database.WebhookDelivery
+	)
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		var err error
+
+		employee, err = q.GetUser(ctx, database.GetUserParams{
+			ID:             id,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.DeleteUser(ctx, database.DeleteUserParams{
+			ID:             id,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishEvent(ctx, q, organizationID, eventEmployeeDeleted, employeeEventData(employee))
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.InfoContext(ctx, "employee deleted", slog.Group("employee",
+		"id", employee.ID,
+		"deletedBy", contextGetAuthenticatedUser(r).ID,
+	))
+
+	app.deliverWebhooks(r, deliveries)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// restoreEmployeeHandler restores a deleted employee that wasn't purged yet,
+// with the roles they had.
+func (app *application) restoreEmployeeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	var (
+		employee   database.User
+		deliveries []database.WebhookDelivery
+	)
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		var err error
+
+		employee, err = q.RestoreUser(ctx, database.RestoreUserParams{
+			ID:             id,
+			OrganizationID: contextGetOrganization(r).ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishEvent(ctx, q, employee.OrganizationID, eventEmployeeRestored, employeeEventData(employee))
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.InfoContext(ctx, "employee restored", slog.Group("employee",
+		"id", employee.ID,
+		"restoredBy", contextGetAuthenticatedUser(r).ID,
+	))
+
+	app.deliverWebhooks(r, deliveries)
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"employee": newEmployeeResponse(employee)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// restoreRoleHandler restores a deleted role that wasn't purged yet, with its
+// members and permissions.
+func (app *application) restoreRoleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	organizationID := contextGetOrganization(r).ID
+
+	var (
+		role       database.Role
+		deliveries []database.WebhookDelivery
+	)
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		var err error
+
+		role, err = q.RestoreRole(ctx, database.RestoreRoleParams{
+			ID:             id,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		members, err := q.GetRoleMembers(ctx, database.GetRoleMembersParams{
+			RoleID:         id,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, member := range members {
+			scheduled, err := publishEvent(ctx, q, organizationID, eventEmployeeRolesChanged, rolesChangedEventData(member.ID, role.ID, true))
+			if err != nil {
+				return err
+			}
+
+			deliveries = append(deliveries, scheduled...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.InfoContext(ctx, "role restored", slog.Group("role",
+		"id", role.ID,
+		"restoredBy", contextGetAuthenticatedUser(r).ID,
+	))
+
+	app.deliverWebhooks(r, deliveries)
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"role": newRoleResponse(role)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// runPurge purges the employees and roles deleted for longer than the
+// retention period, until ctx is cancelled.
+func (app *application) runPurge(ctx context.Context) {
+	app.runOrganizationJob(ctx, "purge", purgeInterval, app.purgeOrganization)
+}
+
+// purgeOrganization hard-deletes the employees and roles of the organization
+// deleted before the retention period. The ones other records still reference
+// are skipped, and a purge failing is reported without stopping the others.
+func (app *application) purgeOrganization(ctx context.Context, r *http.Request, organizationID uuid.UUID) error {
+	deletedBefore := pgtype.Timestamp{Time: time.Now().UTC().Add(-app.config.deletion.retention), Valid: true}
+
+	employeeIDs, err := app.store.ListPurgeableUsers(ctx, database.ListPurgeableUsersParams{
+		DeletedBefore:  deletedBefore,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	roleIDs, err := app.store.ListPurgeableRoles(ctx, database.ListPurgeableRolesParams{
+		DeletedBefore:  deletedBefore,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	var purged, skipped int
+
+	purge := func(fn func(q *database.Queries) error) error {
+		err := app.store.ExecTx(ctx, fn)
+		switch {
+		case err == nil:
+			purged++
+		case errors.Is(err, errDependentRecords):
+			skipped++
+		case ctx.Err() != nil:
+			return err
+		default:
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			app.reportServerError(r, err)
+		}
+		return nil
+	}
+
+	for _, id := range employeeIDs {
+		err := purge(func(q *database.Queries) error {
+			return purgeEmployee(ctx, q, organizationID, id)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, id := range roleIDs {
+		err := purge(func(q *database.Queries) error {
+			return purgeRole(ctx, q, organizationID, id)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if purged > 0 || skipped > 0 {
+		app.logger.InfoContext(ctx, "purge completed", slog.Group("purge",
+			"organization", organizationID,
+			"purged", purged,
+			"skipped", skipped,
+		))
+	}
+
+	return nil
+}
+
+// purgeEmployee hard-deletes a deleted employee with their own roles, sign-ins
+// and secrets. It fails with errDependentRecords while other records, such as
+// the roles they granted or the resources they created, reference them.
+func purgeEmployee(ctx context.Context, q *database.Queries, organizationID, id uuid.UUID) error {
+	err := q.DeleteRolesForUser(ctx, database.DeleteRolesForUserParams{
+		UserID:         id,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.PurgeUser(ctx, database.PurgeUserParams{
+		ID:             id,
+		OrganizationID: organizationID,
+	})
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return errDependentRecords
+	}
+
+	return err
+}
+
+// purgeRole hard-deletes a deleted role with its members and permissions. It
+// fails with errDependentRecords while the history of revoked roles references
+// it.
+func purgeRole(ctx context.Context, q *database.Queries, organizationID, id uuid.UUID) error {
+	err := q.DeleteRoleMembers(ctx, database.DeleteRoleMembersParams{
+		RoleID:         id,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = q.DeleteRolePermissions(ctx, database.DeleteRolePermissionsParams{
+		RoleID:         id,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	rows, err := q.PurgeRole(ctx, database.PurgeRoleParams{
+		ID:             id,
+		OrganizationID: organizationID,
+	})
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return errDependentRecords
+	}
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return errDependentRecords
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestPurgeSkipsDependentRecords(t *testing.T) {
+	app, store := newTestApplication(t)
+	app.config.deletion.retention = 30 * 24 * time.Hour
+
+	var logs bytes.Buffer
+	app.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+	expired := pgtype.Timestamp{Time: time.Now().UTC().Add(-60 * 24 * time.Hour), Valid: true}
+	recent := pgtype.Timestamp{Time: time.Now().UTC().Add(-24 * time.Hour), Valid: true}
+
+	deleteEmployee := func(name, email string, deletedAt pgtype.Timestamp) database.User {
+		employee := newTestEmployee(store, name, email)
+		employee.Status = employeeStatusDeactivated
+		employee.DeletedAt = deletedAt
+		store.employees[employee.ID] = employee
+
+		return employee
+	}
+
+	member := newTestEmployee(store, "Alan Turing", "alan@example.com")
+	grantor := deleteEmployee("Ada Lovelace", "ada@example.com", expired)
+	unreferenced := deleteEmployee("Grace Hopper", "grace@example.com", expired)
+	retained := deleteEmployee("Edsger Dijkstra", "edsger@example.com", recent)
+
+	revokedRole := database.Role{ID: uuid.New(), DisplayName: "contractor", DeletedAt: expired, OrganizationID: store.organization.ID}
+	unusedRole := database.Role{ID: uuid.New(), DisplayName: "intern", DeletedAt: expired, OrganizationID: store.organization.ID}
+	heldRole := database.Role{ID: uuid.New(), DisplayName: "employee", OrganizationID: store.organization.ID}
+	store.roles = append(store.roles, revokedRole, unusedRole, heldRole)
+
+	// The deleted employee granted a role still held, and the deleted role is
+	// in the history of revoked roles.
+	store.grants = append(store.grants, database.UsersRole{
+		UserID:         member.ID,
+		RoleID:         heldRole.ID,
+		Grantor:        grantor.ID,
+		OrganizationID: store.organization.ID,
+	})
+	store.revokedRoles = append(store.revokedRoles, database.UsersRole{
+		UserID:         member.ID,
+		RoleID:         revokedRole.ID,
+		Grantor:        member.ID,
+		OrganizationID: store.organization.ID,
+	})
+
+	r := newTestRequest(store, "JOB", "/jobs/purge", nil)
+
+	err := app.purgeOrganization(context.Background(), r, store.organization.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		employee database.User
+		kept     bool
+	}{
+		{name: "grantor of a held role", employee: grantor, kept: true},
+		{name: "unreferenced", employee: unreferenced},
+		{name: "within the retention period", employee: retained, kept: true},
+		{name: "active", employee: member, kept: true},
+	} {
+		if _, ok := store.employees[tt.employee.ID]; ok != tt.kept {
+			t.Errorf("%s employee: got kept %t; want %t", tt.name, ok, tt.kept)
+		}
+	}
+
+	var roles []string
+	for _, role := range store.roles {
+		roles = append(roles, role.DisplayName)
+	}
+
+	if len(roles) != 2 || roles[0] != revokedRole.DisplayName || roles[1] != heldRole.DisplayName {
+		t.Errorf("got roles %v; want %s and %s kept", roles, revokedRole.DisplayName, heldRole.DisplayName)
+	}
+
+	// The transactions of the skipped rows fail without stopping the purge,
+	// and aren't reported as errors.
+	if len(store.transactions) != 4 {
+		t.Errorf("got %d transactions; want one per expired employee and role", len(store.transactions))
+	}
+
+	if strings.Contains(logs.String(), `"level":"ERROR"`) || !strings.Contains(logs.String(), `"purged":2,"skipped":2`) {
+		t.Errorf("got logs %s; want 2 purged and 2 skipped without errors", logs.String())
+	}
+}
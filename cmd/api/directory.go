@@ -0,0 +1,134 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+)
+
+// rgxSearchWord matches the words of a directory search: letters and digits,
+// along with the punctuation of email addresses and compound names. The
+// characters with a meaning in tsquery syntax are left out.
+var rgxSearchWord = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{N}.@_+-]*`)
+
+type employeeSearchResponse struct {
+	ID         uuid.UUID                `json:"id"`
+	Name       string                   `json:"name"`
+	Email      string                   `json:"email"`
+	Status     string                   `json:"status"`
+	Profile    map[string]any           `json:"profile,omitempty"`
+	Rank       float32                  `json:"rank"`
+	Similarity float32                  `json:"similarity"`
+	Highlights employeeSearchHighlights `json:"highlights"`
+}
+
+// employeeSearchHighlights are the name and email address of an employee found
+// by a search, HTML-escaped, with the words matching the query wrapped in
+// <mark> elements.
+type employeeSearchHighlights struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// searchEmployeesHandler searches the employee directory by name and email
+// address, with q. Every employee may search it, but only the active
+// employees are found unless a holder of the user_manager permission asks for
+// the others with inactive=true. The profile fields are the ones the caller
+// can see.
+func (app *application) searchEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	query := r.URL.Query()
+
+	term := strings.TrimSpace(query.Get("q"))
+	words := rgxSearchWord.FindAllString(term, -1)
+	v.CheckField(len(words) > 0, "Q", "q_required", "Search query is required")
+	v.CheckField(validator.MaxRunes(term, 100), "Q", "q_too_long", "Must not be more than 100 characters long")
+
+	limit, err := strconv.Atoi(cmp.Or(query.Get("limit"), "20"))
+	v.CheckField(err == nil && validator.Between(limit, 1, 50), "Limit", "limit_invalid", "Must be between 1 and 50")
+
+	inactive, err := strconv.ParseBool(cmp.Or(query.Get("inactive"), "false"))
+	v.CheckField(err == nil, "Inactive", "inactive_invalid", "Must be true or false")
+
+	if v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	if inactive {
+		permissions, err := app.callerPermissions(ctx, r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if !validator.In("user_manager", permissions...) && !validator.In("admin", permissions...) {
+			app.notPermitted(w, r)
+			return
+		}
+	}
+
+	fields, err := app.visibleProfileFields(ctx, r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Every word matches as a prefix, so results come up while the query is
+	// typed.
+	for i := range words {
+		words[i] += ":*"
+	}
+
+	results, err := app.store.SearchUsers(ctx, database.SearchUsersParams{
+		Query:          strings.Join(words, " & "),
+		Term:           term,
+		Inactive:       inactive,
+		OrganizationID: contextGetOrganization(r).ID,
+		Limit:          int32(limit),
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := []employeeSearchResponse{}
+	for _, result := range results {
+		profile, err := visibleProfile(result.Profile, fields)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data = append(data, employeeSearchResponse{
+			ID:         result.ID,
+			Name:       result.Name,
+			Email:      result.Email,
+			Status:     result.Status,
+			Profile:    profile,
+			Rank:       result.Rank,
+			Similarity: result.Similarity,
+			Highlights: employeeSearchHighlights{
+				Name:  result.NameHighlight,
+				Email: result.EmailHighlight,
+			},
+		})
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"employees": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
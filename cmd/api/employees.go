@@ -1,8 +1,10 @@
 package main
 
 import (
-	"context"
+	"cmp"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	database "github.com/brGuirra/uai/internal/database/sqlc"
@@ -10,11 +12,21 @@ import (
 	"github.com/brGuirra/uai/internal/request"
 	"github.com/brGuirra/uai/internal/response"
 	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/pascaldekloe/jwt"
 )
 
+// Statuses of employees, besides the unverified status of the employees who
+// signed up.
+const (
+	employeeStatusActive      = "active"
+	employeeStatusDeactivated = "deactivated"
+	employeeStatusTerminated  = "terminated"
+)
+
 func (app *application) createEmployeeHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Name      string              `json:"name"`
@@ -30,41 +42,61 @@ func (app *application) createEmployeeHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := detachedContext(r, 5*time.Second)
 	defer cancel()
 
-	exists, err := app.store.CheckEmployeeEmailExists(ctx, input.Email)
+	exists, err := app.store.UserEmailExists(ctx, database.UserEmailExistsParams{
+		Email:          input.Email,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	input.Validator.CheckField(input.Email != "", "Email", "Email is required")
-	input.Validator.CheckField(validator.Matches(input.Email, validator.RgxEmail), "Email", "Must be a valid email address")
-	input.Validator.CheckField(!exists, "Email", "Email is already in use")
+	input.Validator.CheckField(input.Email != "", "Email", "email_required", "Email is required")
+	input.Validator.CheckField(validator.Matches(input.Email, validator.RgxEmail), "Email", "email_invalid", "Must be a valid email address")
+	input.Validator.CheckField(!exists, "Email", "email_taken", "Email is already in use")
 
-	input.Validator.CheckField(input.Password != "", "Password", "Password is required")
-	input.Validator.CheckField(len(input.Password) >= 8, "Password", "Password is too short")
-	input.Validator.CheckField(len(input.Password) <= 72, "Password", "Password is too long")
-	input.Validator.CheckField(validator.NotIn(input.Password, password.CommonPasswords...), "Password", "Password is too common")
-	input.Validator.CheckField(validator.AllIn(input.Roles, "staff", "leader", "employee"), "Roles", "Invalid role, must be 'staff', 'leader' or 'employee'")
+	input.Validator.CheckField(input.Password != "", "Password", "password_required", "Password is required")
+	app.checkPassword(&input.Validator, "Password", input.Password, input.Name, input.Email)
+	input.Validator.CheckField(validator.AllIn(input.Roles, "staff", "leader", "employee"), "Roles", "role_invalid", "Invalid role, must be 'staff', 'leader' or 'employee'")
 
 	if input.Validator.HasErrors() {
 		app.failedValidation(w, r, input.Validator)
 		return
 	}
 
-	_, err = app.store.CreateEmployee(ctx, database.CreateEmployeeParams{
-		Name:           input.Name,
-		Email:          input.Email,
-		Status:         "unverified",
-		HashedPassword: pgtype.Text{},
+	hashedPassword, err := password.Hash(input.Password)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var deliveries []database.WebhookDelivery
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		employee, err := q.CreateUser(ctx, database.CreateUserParams{
+			Name:           input.Name,
+			Email:          input.Email,
+			Status:         "unverified",
+			HashedPassword: pgtype.Text{String: hashedPassword, Valid: true},
+			OrganizationID: contextGetOrganization(r).ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishEvent(ctx, q, employee.OrganizationID, eventEmployeeCreated, employeeEventData(employee))
+		return err
 	})
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	app.deliverWebhooks(r, deliveries)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -81,32 +113,92 @@ func (app *application) createAuthenticationToken(w http.ResponseWriter, r *http
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := detachedContext(r, 5*time.Second)
 	defer cancel()
 
-	employee, err := app.store.GetEmployeeByEmail(ctx, input.Email)
-	if err != nil {
+	// Deleted employees can't sign in until they're restored, the query
+	// leaves them out.
+	employee, err := app.store.GetUserByEmail(ctx, database.GetUserByEmailParams{
+		Email:          input.Email,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		app.serverError(w, r, err)
 		return
 	}
 
-	input.Validator.CheckField(input.Email != "", "Email", "Email is required")
-	input.Validator.CheckField(employee.Email != "", "Email", "Email address could not be found")
+	input.Validator.CheckField(input.Email != "", "Email", "email_required", "Email is required")
+	input.Validator.CheckField(employee.Email != "", "Email", "email_not_found", "Email address could not be found")
+
+	if employee.Email != "" {
+		locked, err := app.accountLockRemaining(ctx, employee)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if locked > 0 {
+			app.accountLocked(w, r, locked)
+			return
+		}
+	}
 
-	passwordMatches, err := password.Matches(input.Password, employee.HashedPassword.String)
+	passwordMatches, directory, err := app.passwordMatches(ctx, employee, input.Password)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	input.Validator.CheckField(input.Password != "", "Password", "Password is required")
-	input.Validator.CheckField(passwordMatches, "Password", "Password is incorrect")
+	input.Validator.CheckField(input.Password != "", "Password", "password_required", "Password is required")
+	input.Validator.CheckField(passwordMatches, "Password", "password_incorrect", "Password is incorrect")
+
+	if employee.Email != "" && input.Password != "" && !passwordMatches {
+		err = app.recordFailedLogin(ctx, r, employee)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
 
 	if input.Validator.HasErrors() {
 		app.failedValidation(w, r, input.Validator)
 		return
 	}
 
+	// The status is only disclosed to the holders of the password.
+	if employee.Status != employeeStatusActive {
+		app.accountInactive(w, r)
+		return
+	}
+
+	if !directory {
+		app.rehashPassword(r, employee, input.Password)
+	}
+
+	enabled, err := app.twoFactorEnabled(ctx, employee)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if enabled {
+		app.writeTwoFactorChallenge(w, r, employee, authMethodPassword)
+		return
+	}
+
+	err = app.recordSuccessfulLogin(ctx, r, employee)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeAuthenticationToken(w, r, employee, authMethodPassword)
+}
+
+// writeAuthenticationToken responds with a new authentication token for the
+// employee. methods are the authentication methods the employee went through,
+// recorded in the amr claim.
+func (app *application) writeAuthenticationToken(w http.ResponseWriter, r *http.Request, employee database.User, methods ...string) {
 	var claims jwt.Claims
 
 	claims.Subject = employee.ID.String()
@@ -119,6 +211,8 @@ func (app *application) createAuthenticationToken(w http.ResponseWriter, r *http
 	claims.Issuer = app.config.baseURL
 	claims.Audiences = []string{app.config.baseURL}
 
+	claims.Set = map[string]any{"amr": methods, organizationClaim: employee.OrganizationID.String()}
+
 	jwtBytes, err := claims.HMACSign(jwt.HS256, []byte(app.config.jwt.secretKey))
 	if err != nil {
 		app.serverError(w, r, err)
@@ -136,6 +230,157 @@ func (app *application) createAuthenticationToken(w http.ResponseWriter, r *http
 	}
 }
 
+type employeeResponse struct {
+	ID        uuid.UUID      `json:"id"`
+	Name      string         `json:"name"`
+	Email     string         `json:"email"`
+	Status    string         `json:"status"`
+	DeletedAt *time.Time     `json:"deletedAt,omitempty"`
+	Profile   map[string]any `json:"profile,omitempty"`
+}
+
+func newEmployeeResponse(user database.User) employeeResponse {
+	res := employeeResponse{
+		ID:     user.ID,
+		Name:   user.Name,
+		Email:  user.Email,
+		Status: user.Status,
+	}
+
+	if user.DeletedAt.Valid {
+		res.DeletedAt = &user.DeletedAt.Time
+	}
+
+	return res
+}
+
+// listEmployeesHandler lists the employees a page at a time, optionally
+// filtered by email address and by the values of the profile fields the caller
+// can see. The deleted employees are listed instead with deleted=true.
+func (app *application) listEmployeesHandler(w http.ResponseWriter, r *http.Request) {
+	var v validator.Validator
+
+	query := r.URL.Query()
+
+	page, err := strconv.Atoi(cmp.Or(query.Get("page"), "1"))
+	v.CheckField(err == nil && page >= 1, "Page", "page_invalid", "Must be a positive integer")
+
+	pageSize, err := strconv.Atoi(cmp.Or(query.Get("page_size"), "20"))
+	v.CheckField(err == nil && validator.Between(pageSize, 1, 100), "PageSize", "page_size_invalid", "Must be between 1 and 100")
+
+	deleted, err := strconv.ParseBool(cmp.Or(query.Get("deleted"), "false"))
+	v.CheckField(err == nil, "Deleted", "deleted_invalid", "Must be true or false")
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	fields, err := app.visibleProfileFields(ctx, r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	profile := readProfileFilter(query, fields, &v)
+
+	if v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	var email pgtype.Text
+	if value := query.Get("email"); value != "" {
+		email = pgtype.Text{String: value, Valid: true}
+	}
+
+	organizationID := contextGetOrganization(r).ID
+
+	total, err := app.store.CountUsers(ctx, database.CountUsersParams{
+		Email:          email,
+		Profile:        profile,
+		Deleted:        deleted,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	users, err := app.store.ListUsers(ctx, database.ListUsersParams{
+		Email:          email,
+		Profile:        profile,
+		Deleted:        deleted,
+		OrganizationID: organizationID,
+		Limit:          int32(pageSize),
+		Offset:         int32((page - 1) * pageSize),
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := []employeeResponse{}
+	for _, user := range users {
+		res := newEmployeeResponse(user)
+
+		res.Profile, err = visibleProfile(user.Profile, fields)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data = append(data, res)
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"employees": data, "total": total})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) showEmployeeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	user, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	fields, err := app.visibleProfileFields(ctx, r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := newEmployeeResponse(user)
+
+	data.Profile, err = visibleProfile(user.Profile, fields)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"employee": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
 func (app *application) protected(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("This is a protected handler"))
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/password"
+)
+
+func TestCreateEmployeePassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		status   int
+	}{
+		{name: "compliant", password: "x7#Kp2!vQz9@Lm", status: http.StatusNoContent},
+		{name: "too short", password: "x7#Kp2!v", status: http.StatusUnprocessableEntity},
+		{name: "common", password: "unbelievable", status: http.StatusUnprocessableEntity},
+		{name: "missing", password: "", status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, store := newTestApplication(t)
+			app.passwordPolicy = password.Policy{MinLength: 12}
+
+			body := `{"name": "Ada Lovelace", "email": "ada@example.com", "password": "` + tt.password + `"}`
+
+			r := newTestRequest(store, http.MethodPost, "/api/v1/employees", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			app.createEmployeeHandler(rr, r)
+
+			if rr.Code != tt.status {
+				t.Fatalf("got status %d and body %s; want %d", rr.Code, rr.Body, tt.status)
+			}
+
+			employee, err := store.GetUserByEmail(context.Background(), database.GetUserByEmailParams{
+				Email:          "ada@example.com",
+				OrganizationID: store.organization.ID,
+			})
+
+			if tt.status != http.StatusNoContent {
+				if err == nil {
+					t.Error("the employee was created")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("the employee wasn't created: %v", err)
+			}
+
+			matches, err := password.Matches(tt.password, employee.HashedPassword.String)
+			if err != nil || !matches {
+				t.Errorf("got the password stored %t and error %v; want it stored", matches, err)
+			}
+		})
+	}
+}
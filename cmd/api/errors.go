@@ -3,12 +3,16 @@ package main
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/brGuirra/uai/internal/response"
 	"github.com/brGuirra/uai/internal/validator"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (app *application) reportServerError(r *http.Request, err error) {
@@ -20,56 +24,127 @@ func (app *application) reportServerError(r *http.Request, err error) {
 	)
 
 	requestAttrs := slog.Group("request", "method", method, "url", url)
-	app.logger.Error(message, requestAttrs, "trace", trace)
+	app.logger.ErrorContext(r.Context(), message, requestAttrs, "trace", trace)
 }
 
-func (app *application) errorMessage(w http.ResponseWriter, r *http.Request, status int, message string, headers http.Header) {
-	message = strings.ToUpper(message[:1]) + message[1:]
+// generalErrorsKey is the key of the problem errors holding the validation
+// errors that are not about a specific field.
+const generalErrorsKey = "_"
+
+// problem writes p as an application/problem+json response, filling in the
+// members that come from the request. Every error response goes through it.
+func (app *application) problem(w http.ResponseWriter, r *http.Request, p response.Problem, headers http.Header) {
+	p.Instance = r.URL.Path
+	p.RequestID = contextGetRequestID(r)
 
-	err := response.JSONWithHeaders(w, status, map[string]string{"Error": message}, headers)
+	err := response.ProblemJSON(w, p, headers)
 	if err != nil {
 		app.reportServerError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
+func (app *application) errorMessage(w http.ResponseWriter, r *http.Request, status int, code, message string, headers http.Header) {
+	message = strings.ToUpper(message[:1]) + message[1:]
+
+	app.problem(w, r, response.NewProblem(status, code, message), headers)
+}
+
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	trace.SpanFromContext(r.Context()).RecordError(err)
 	app.reportServerError(r, err)
 
 	message := "The server encountered a problem and could not process your request"
-	app.errorMessage(w, r, http.StatusInternalServerError, message, nil)
+	app.errorMessage(w, r, http.StatusInternalServerError, "server_error", message, nil)
 }
 
 func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
 	message := "The requested resource could not be found"
-	app.errorMessage(w, r, http.StatusNotFound, message, nil)
+	app.errorMessage(w, r, http.StatusNotFound, "not_found", message, nil)
 }
 
 func (app *application) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
 	message := fmt.Sprintf("The %s method is not supported for this resource", r.Method)
-	app.errorMessage(w, r, http.StatusMethodNotAllowed, message, nil)
+	app.errorMessage(w, r, http.StatusMethodNotAllowed, "method_not_allowed", message, nil)
 }
 
 func (app *application) badRequest(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorMessage(w, r, http.StatusBadRequest, err.Error(), nil)
+	app.errorMessage(w, r, http.StatusBadRequest, "bad_request", err.Error(), nil)
 }
 
 func (app *application) failedValidation(w http.ResponseWriter, r *http.Request, v validator.Validator) {
-	err := response.JSON(w, http.StatusUnprocessableEntity, v)
-	if err != nil {
-		app.serverError(w, r, err)
+	p := response.NewProblem(http.StatusUnprocessableEntity, "validation_failed", "The request contains invalid data")
+	p.Errors = make(map[string][]validator.Error, len(v.FieldErrors)+1)
+
+	for key, errs := range v.FieldErrors {
+		p.Errors[key] = errs
+	}
+
+	if len(v.Errors) > 0 {
+		p.Errors[generalErrorsKey] = v.Errors
 	}
+
+	app.problem(w, r, p, nil)
 }
 
 func (app *application) invalidAuthenticationToken(w http.ResponseWriter, r *http.Request) {
 	headers := make(http.Header)
 	headers.Set("WWW-Authenticate", "Bearer")
 
-	app.errorMessage(w, r, http.StatusUnauthorized, "Invalid authentication token", headers)
+	app.errorMessage(w, r, http.StatusUnauthorized, "invalid_authentication_token", "Invalid authentication token", headers)
 }
 
 func (app *application) authenticationRequired(w http.ResponseWriter, r *http.Request) {
-	app.errorMessage(w, r, http.StatusUnauthorized, "You must be authenticated to access this resource", nil)
+	app.errorMessage(w, r, http.StatusUnauthorized, "authentication_required", "You must be authenticated to access this resource", nil)
+}
+
+func (app *application) notPermitted(w http.ResponseWriter, r *http.Request) {
+	app.errorMessage(w, r, http.StatusForbidden, "not_permitted", "Your user account doesn't have the necessary permissions to access this resource", nil)
+}
+
+func (app *application) serviceAccountNotPermitted(w http.ResponseWriter, r *http.Request) {
+	app.errorMessage(w, r, http.StatusForbidden, "service_account_not_permitted", "This resource can only be accessed by employees", nil)
+}
+
+func (app *application) impersonationNotPermitted(w http.ResponseWriter, r *http.Request) {
+	app.errorMessage(w, r, http.StatusForbidden, "impersonation_not_permitted", "This action can't be performed while impersonating an employee", nil)
+}
+
+func (app *application) twoFactorRequired(w http.ResponseWriter, r *http.Request) {
+	message := "Your role requires two-factor authentication, enable it and sign in again to access this resource"
+	app.errorMessage(w, r, http.StatusForbidden, "two_factor_required", message, nil)
+}
+
+func (app *application) twoFactorAlreadyEnabled(w http.ResponseWriter, r *http.Request) {
+	app.errorMessage(w, r, http.StatusConflict, "two_factor_already_enabled", "Two-factor authentication is already enabled", nil)
+}
+
+func (app *application) rateLimitExceeded(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	headers := make(http.Header)
+	headers.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	app.errorMessage(w, r, http.StatusTooManyRequests, "rate_limited", "Too many requests, please try again later", headers)
+}
+
+func (app *application) accountLocked(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	headers := make(http.Header)
+	headers.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	app.errorMessage(w, r, http.StatusLocked, "account_locked", "Your account is locked after too many failed sign-in attempts, please try again later", headers)
+}
+
+func (app *application) accountInactive(w http.ResponseWriter, r *http.Request) {
+	app.errorMessage(w, r, http.StatusForbidden, "account_inactive", "Your account isn't active, please contact an administrator", nil)
+}
+
+func (app *application) ssoFailed(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.WarnContext(r.Context(), "single sign-on failed", "error", err.Error())
+
+	app.errorMessage(w, r, http.StatusUnauthorized, "sso_failed", "Single sign-on failed, please try again", nil)
+}
+
+func (app *application) ssoAccountNotFound(w http.ResponseWriter, r *http.Request) {
+	app.errorMessage(w, r, http.StatusForbidden, "sso_account_not_found", "No employee account matches the email address of your identity provider account", nil)
 }
 
 func (app *application) basicAuthenticationRequired(w http.ResponseWriter, r *http.Request) {
@@ -77,5 +152,5 @@ func (app *application) basicAuthenticationRequired(w http.ResponseWriter, r *ht
 	headers.Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
 
 	message := "You must be authenticated to access this resource"
-	app.errorMessage(w, r, http.StatusUnauthorized, message, headers)
+	app.errorMessage(w, r, http.StatusUnauthorized, "authentication_required", message, headers)
 }
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	eventEmployeeCreated      = "employee.created"
+	eventEmployeeActivated    = "employee.activated"
+	eventEmployeeDeactivated  = "employee.deactivated"
+	eventEmployeeTerminated   = "employee.terminated"
+	eventEmployeeRolesChanged = "employee.roles_changed"
+	eventEmployeeDeleted      = "employee.deleted"
+	eventEmployeeRestored     = "employee.restored"
+)
+
+var eventTypes = []string{
+	eventEmployeeCreated,
+	eventEmployeeActivated,
+	eventEmployeeDeactivated,
+	eventEmployeeTerminated,
+	eventEmployeeRolesChanged,
+	eventEmployeeDeleted,
+	eventEmployeeRestored,
+}
+
+// eventPermissions maps every event type to the permission needed to receive
+// it from the event stream.
+var eventPermissions = map[string]string{
+	eventEmployeeCreated:      "user_manager",
+	eventEmployeeActivated:    "user_manager",
+	eventEmployeeDeactivated:  "user_manager",
+	eventEmployeeTerminated:   "user_manager",
+	eventEmployeeRolesChanged: "user_manager",
+	eventEmployeeDeleted:      "user_manager",
+	eventEmployeeRestored:     "user_manager",
+}
+
+const (
+	// eventsChannel is the PostgreSQL notification channel a trigger on the
+	// events table notifies with the organization and sequence of every new
+	// event, separated by a colon.
+	eventsChannel = "events"
+
+	eventStreamKeepAlive   = 15 * time.Second
+	eventStreamReplayLimit = 500
+)
+
+type event struct {
+	ID             uuid.UUID       `json:"id"`
+	Sequence       int64           `json:"-"`
+	OrganizationID uuid.UUID       `json:"-"`
+	Type           string          `json:"type"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	Data           json.RawMessage `json:"data"`
+}
+
+func newEvent(evt database.Event) event {
+	return event{
+		ID:             evt.ID,
+		Sequence:       evt.Sequence,
+		OrganizationID: evt.OrganizationID,
+		Type:           evt.Type,
+		CreatedAt:      evt.CreatedAt.Time,
+		Data:           evt.Data,
+	}
+}
+
+// publishEvent records the event in the transaction of q, with the webhook
+// deliveries it schedules, so the event is published if and only if the change
+// it reports is committed. The trigger on the events table notifies the event
+// streams of every API instance on commit, and the returned deliveries are to
+// be made with deliverWebhooks once the transaction is committed. Those left
+// unmade, if the instance stops before, are made by runWebhookRetries.
+func publishEvent(ctx context.Context, q *database.Queries, organizationID uuid.UUID, eventType string, data any) ([]database.WebhookDelivery, error) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// An event taking its sequence before an event committed ahead of it would
+	// be skipped by the event streams reading past the latter. The lock is held
+	// until the transaction ends.
+	err = q.LockEvents(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	evt, err := q.CreateEvent(ctx, database.CreateEventParams{
+		Type:           eventType,
+		Data:           js,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return scheduleWebhooks(ctx, q, newEvent(evt))
+}
+
+// listenForEvents relays the events stored by any API instance to the local
+// event stream subscribers, reconnecting until ctx is cancelled.
+func (app *application) listenForEvents(ctx context.Context) {
+	relay := func(payload string) {
+		organization, sequence, _ := strings.Cut(payload, ":")
+
+		organizationID, err := uuid.Parse(organization)
+		if err != nil {
+			app.logger.Error(err.Error())
+			return
+		}
+
+		seq, err := strconv.ParseInt(sequence, 10, 64)
+		if err != nil {
+			app.logger.Error(err.Error())
+			return
+		}
+
+		evt, err := app.store.GetEventBySequence(database.WithOrganization(ctx, organizationID), database.GetEventBySequenceParams{
+			Sequence:       seq,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			app.logger.Error(err.Error())
+			return
+		}
+
+		app.events.Publish(newEvent(evt))
+	}
+
+	for {
+		err := app.store.Listen(ctx, eventsChannel, relay)
+		if ctx.Err() != nil {
+			return
+		}
+
+		app.logger.Error(err.Error(), slog.Group("listener", "channel", eventsChannel))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// streamEventsHandler sends the events of the organization the employee is
+// allowed to receive, as Server-Sent Events. Their status and permissions are
+// checked again at every keep-alive, so a stream doesn't outlive a
+// deactivation or a revoked role by more than eventStreamKeepAlive.
+func (app *application) streamEventsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	employee := contextGetAuthenticatedUser(r)
+
+	permissions, err := app.store.GetPermissionsForEmployee(ctx, database.GetPermissionsForEmployeeParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// recheck reloads the permissions of the employee, and reports whether
+	// they may still follow the stream.
+	recheck := func() (bool, error) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		current, err := app.store.GetUser(ctx, database.GetUserParams{
+			ID:             employee.ID,
+			OrganizationID: employee.OrganizationID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if current.Status != employeeStatusActive {
+			return false, nil
+		}
+
+		permissions, err = app.store.GetPermissionsForEmployee(ctx, database.GetPermissionsForEmployeeParams{
+			UserID:         employee.ID,
+			OrganizationID: employee.OrganizationID,
+		})
+		return err == nil, err
+	}
+
+	var lastSequence int64
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	if lastEventID != "" {
+		lastSequence, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || lastSequence < 0 {
+			app.badRequest(w, r, fmt.Errorf("invalid Last-Event-ID %q", lastEventID))
+			return
+		}
+	}
+
+	// The stream outlives the server write timeout, so lift it for this
+	// request only.
+	rc := http.NewResponseController(w)
+
+	err = rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Subscribe before reading the events, so no event is lost in between.
+	events, unsubscribe := app.events.Subscribe()
+	defer unsubscribe()
+
+	// Without a Last-Event-ID, the stream starts with the events to come.
+	if lastEventID == "" {
+		lastSequence, err = app.store.GetLatestEventSequence(ctx, employee.OrganizationID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(evt event) error {
+		lastSequence = evt.Sequence
+
+		if !canReceiveEvent(permissions, evt.Type) {
+			return nil
+		}
+
+		js, err := json.Marshal(evt)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evt.Sequence, evt.Type, js)
+		if err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	}
+
+	// catchUp sends the events stored after the last one sent, in order. Events
+	// take their sequences in the order they are committed in (see
+	// publishEvent), so none can be stored behind one already sent.
+	catchUp := func() error {
+		for {
+			backlog, err := app.store.GetEventsAfterSequence(r.Context(), database.GetEventsAfterSequenceParams{
+				Sequence:       lastSequence,
+				Limit:          eventStreamReplayLimit,
+				OrganizationID: employee.OrganizationID,
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, evt := range backlog {
+				err = send(newEvent(evt))
+				if err != nil {
+					return err
+				}
+			}
+
+			if len(backlog) < eventStreamReplayLimit {
+				return nil
+			}
+		}
+	}
+
+	if lastEventID != "" {
+		err = catchUp()
+		if err != nil {
+			if r.Context().Err() == nil {
+				app.reportServerError(r, err)
+			}
+			return
+		}
+	}
+
+	err = rc.Flush()
+	if err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(eventStreamKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-ticker.C:
+			var allowed bool
+
+			allowed, err = recheck()
+			if err != nil {
+				if r.Context().Err() == nil {
+					app.reportServerError(r, err)
+				}
+				return
+			}
+
+			if !allowed {
+				return
+			}
+
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+			if err == nil {
+				err = rc.Flush()
+			}
+
+		case evt, ok := <-events:
+			if !ok {
+				// The subscriber fell behind. Closing the stream makes the
+				// client reconnect and resume from its Last-Event-ID.
+				return
+			}
+
+			// The notification only says there are new events. The ones
+			// already sent by an earlier catch up are skipped.
+			if evt.OrganizationID != employee.OrganizationID || evt.Sequence <= lastSequence {
+				continue
+			}
+
+			err = catchUp()
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func canReceiveEvent(permissions []string, eventType string) bool {
+	if validator.In("admin", permissions...) {
+		return true
+	}
+
+	permission, ok := eventPermissions[eventType]
+
+	return ok && validator.In(permission, permissions...)
+}
+
+// publishEmployeeStatusChange publishes the activation or deactivation of an
+// employee in the transaction of q, if their status changed.
+func publishEmployeeStatusChange(ctx context.Context, q *database.Queries, before, after database.User) ([]database.WebhookDelivery, error) {
+	if before.Status == after.Status {
+		return nil, nil
+	}
+
+	switch after.Status {
+	case employeeStatusActive:
+		return publishEvent(ctx, q, after.OrganizationID, eventEmployeeActivated, employeeEventData(after))
+	case employeeStatusDeactivated:
+		return publishEvent(ctx, q, after.OrganizationID, eventEmployeeDeactivated, employeeEventData(after))
+	case employeeStatusTerminated:
+		return publishEvent(ctx, q, after.OrganizationID, eventEmployeeTerminated, employeeEventData(after))
+	}
+
+	return nil, nil
+}
+
+func employeeEventData(employee database.User) map[string]any {
+	return map[string]any{
+		"id":     employee.ID,
+		"name":   employee.Name,
+		"email":  employee.Email,
+		"status": employee.Status,
+	}
+}
+
+func rolesChangedEventData(employeeID, roleID uuid.UUID, granted bool) map[string]any {
+	return map[string]any{
+		"id":      employeeID,
+		"roleId":  roleID,
+		"granted": granted,
+	}
+}
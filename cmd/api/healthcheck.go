@@ -1,15 +1,64 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/brGuirra/uai/internal/database/migrations"
 	"github.com/brGuirra/uai/internal/response"
 )
 
-func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Caiu aqui")
+const (
+	readinessCheckTimeout = 3 * time.Second
+
+	// smtpCheckInterval is how long the outcome of connecting to the SMTP
+	// server is reused for. Every connection goes through the TLS handshake
+	// and authentication, which providers rate limit, so the server isn't
+	// connected to on every probe.
+	smtpCheckInterval = time.Minute
+)
+
+type checkResult struct {
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// cachedCheck runs a readiness check at most once per interval, reusing its
+// last outcome in between.
+type cachedCheck struct {
+	check    func(ctx context.Context) error
+	interval time.Duration
+
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+func (c *cachedCheck) run(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checked.IsZero() && time.Since(c.checked) < c.interval {
+		return c.err
+	}
+
+	err := c.check(ctx)
+
+	// An outcome cut short by the probe going away says nothing about the
+	// dependency, so it isn't kept.
+	if ctx.Err() == nil {
+		c.err = err
+		c.checked = time.Now()
+	}
 
+	return err
+}
+
+func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
 	data := map[string]any{
 		"status": "available",
 		"systemInfo": map[string]string{
@@ -23,3 +72,105 @@ func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		app.serverError(w, r, err)
 	}
 }
+
+// livezHandler reports whether the process is able to serve requests at all.
+// It checks no dependency, so an outage of one of them doesn't get the
+// application restarted.
+func (app *application) livezHandler(w http.ResponseWriter, r *http.Request) {
+	err := response.JSON(w, http.StatusOK, map[string]string{"status": "alive"})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// readyzHandler reports whether the application should receive traffic: all
+// of its dependencies are reachable, the database schema is up to date and
+// the server is not shutting down. The endpoint is public, so only the
+// outcome of each check is reported, and the errors are logged instead.
+func (app *application) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func(ctx context.Context) error{
+		"database":   app.store.Ping,
+		"smtp":       app.smtpCheck.run,
+		"migrations": app.checkMigrations,
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]checkResult, len(checks))
+		ready   = true
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+
+			result := checkResult{
+				Status:     "pass",
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+
+			if err != nil {
+				result.Status = "fail"
+				app.logger.WarnContext(ctx, "readiness check failed", slog.Group("check", "name", name, "error", err.Error()))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			results[name] = result
+			ready = ready && err == nil
+		}()
+	}
+
+	wg.Wait()
+
+	status := http.StatusOK
+	data := map[string]any{
+		"status": "ready",
+		"checks": results,
+	}
+
+	switch {
+	case app.shuttingDown.Load():
+		status = http.StatusServiceUnavailable
+		data["status"] = "shutting down"
+	case !ready:
+		status = http.StatusServiceUnavailable
+		data["status"] = "unavailable"
+	}
+
+	err := response.JSON(w, status, data)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) checkMigrations(ctx context.Context) error {
+	expected, err := migrations.LatestVersion()
+	if err != nil {
+		return err
+	}
+
+	current, dirty, err := app.store.MigrationVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case dirty:
+		return fmt.Errorf("migration %d failed and left the schema dirty", current)
+	case current < expected:
+		return fmt.Errorf("schema is at version %d but %d is expected", current, expected)
+	}
+
+	return nil
+}
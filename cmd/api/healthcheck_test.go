@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brGuirra/uai/internal/database/migrations"
+)
+
+// readinessStore is a testStore whose database checks fail with pingErr, with
+// a schema at the given migration version.
+type readinessStore struct {
+	*testStore
+	pingErr error
+	version int64
+}
+
+func (s *readinessStore) Ping(ctx context.Context) error {
+	return s.pingErr
+}
+
+func (s *readinessStore) MigrationVersion(ctx context.Context) (int64, bool, error) {
+	return s.version, false, nil
+}
+
+func TestReadyzHidesCheckErrors(t *testing.T) {
+	app, store := newTestApplication(t)
+
+	latest, err := migrations.LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.store = &readinessStore{
+		testStore: store,
+		pingErr:   errors.New("dial tcp 10.0.0.5:5432: connect: connection refused"),
+		version:   latest,
+	}
+	app.smtpCheck = &cachedCheck{
+		check:    func(ctx context.Context) error { return errors.New("535 authentication failed for smtp-user") },
+		interval: time.Minute,
+	}
+
+	rr := httptest.NewRecorder()
+	app.readyzHandler(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d; want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	for _, leak := range []string{"10.0.0.5", "smtp-user", "error"} {
+		if strings.Contains(rr.Body.String(), leak) {
+			t.Errorf("body %s discloses %q", rr.Body, leak)
+		}
+	}
+
+	var body struct {
+		Checks map[string]checkResult `json:"checks"`
+	}
+
+	err = json.Unmarshal(rr.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"database": "fail", "smtp": "fail", "migrations": "pass"}
+
+	for name, status := range want {
+		if got := body.Checks[name].Status; got != status {
+			t.Errorf("%s: got status %q; want %q", name, got, status)
+		}
+	}
+}
+
+func TestCachedCheck(t *testing.T) {
+	calls := 0
+	failure := errors.New("unreachable")
+
+	c := &cachedCheck{
+		check: func(ctx context.Context) error {
+			calls++
+			return failure
+		},
+		interval: time.Minute,
+	}
+
+	for range 3 {
+		err := c.run(context.Background())
+		if !errors.Is(err, failure) {
+			t.Fatalf("got error %v; want %v", err, failure)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("got %d checks within the interval; want 1", calls)
+	}
+
+	c.checked = time.Now().Add(-2 * time.Minute)
+
+	c.run(context.Background())
+
+	if calls != 2 {
+		t.Errorf("got %d checks after the interval; want 2", calls)
+	}
+
+	// A check cut short by the probe going away isn't cached.
+	c.checked = time.Time{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c.run(ctx)
+
+	if !c.checked.IsZero() {
+		t.Error("the outcome of a cancelled check was cached")
+	}
+}
@@ -1,34 +1,211 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pascaldekloe/jwt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func (app *application) newEmailData() map[string]any {
+const (
+	tracerName = "github.com/brGuirra/uai/cmd/api"
+
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+
+	maxAccountBodyBytes = 1_048_576
+)
+
+// newEmailData returns the data common to the emails sent to the employees of
+// the organization, including its branding.
+func (app *application) newEmailData(organization *database.Organization) map[string]any {
 	data := map[string]any{
-		"BaseURL": app.config.baseURL,
+		"BaseURL":    app.config.baseURL,
+		"BrandName":  cmp.Or(organization.BrandName.String, organization.Name),
+		"BrandColor": organization.BrandColor.String,
+		"LogoURL":    organization.LogoUrl.String,
 	}
 
 	return data
 }
 
-func (app *application) backgroundTask(r *http.Request, fn func() error) {
+// detachedContext returns a context carrying the values of the request
+// context, such as its trace, that is cancelled by the timeout only. Work
+// started by a handler is not aborted when the client goes away.
+func detachedContext(r *http.Request, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), timeout)
+}
+
+// backgroundTask runs fn in its own trace, linked to the trace of the request
+// that started it, since the task usually outlives the request. The context of
+// fn keeps the request ID, so the task logs can be correlated with the
+// request.
+func (app *application) backgroundTask(r *http.Request, fn func(ctx context.Context) error) {
 	app.wg.Add(1)
+	app.backgroundTasks.Add(1)
+
+	ctx, span := otel.Tracer(tracerName).Start(context.WithoutCancel(r.Context()), "background task",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(r.Context())),
+	)
+
+	// Errors are reported with the context of the task.
+	r = r.WithContext(ctx)
 
 	go func() {
 		defer app.wg.Done()
+		defer app.backgroundTasks.Add(-1)
+		defer span.End()
 
 		defer func() {
 			err := recover()
 			if err != nil {
+				span.SetStatus(codes.Error, "panic")
 				app.reportServerError(r, fmt.Errorf("%s", err))
 			}
 		}()
 
-		err := fn()
+		err := fn(ctx)
 		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 			app.reportServerError(r, err)
 		}
 	}()
 }
+
+// jobRequest returns a request standing for a job the application runs on its
+// own, such as the directory sync, so the job can use the helpers taking the
+// request they work for.
+func jobRequest(ctx context.Context, name string) *http.Request {
+	r, err := http.NewRequestWithContext(ctx, "JOB", "/jobs/"+name, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+func readUUIDParam(r *http.Request, name string) (uuid.UUID, error) {
+	return uuid.Parse(chi.URLParam(r, name))
+}
+
+// routePattern returns the chi route pattern the request matched, or
+// "unmatched" when no route did.
+func routePattern(r *http.Request) string {
+	route := chi.RouteContext(r.Context()).RoutePattern()
+	if route == "" {
+		return "unmatched"
+	}
+
+	return route
+}
+
+// validRequestID reports whether an incoming request ID is safe to log and
+// echo back: non-empty, reasonably short and made of printable ASCII.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < '!' || requestID[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// clientIP returns the IP address of the client, as reported by the trusted
+// proxies in front of the application when the request comes from one.
+func (app *application) clientIP(r *http.Request) string {
+	return app.config.trustedProxies.ClientIP(r)
+}
+
+// accountEmail returns the normalized email of the account a JSON request
+// body is about, such as the credentials sent to the authentication endpoint,
+// prefixed by the organization of the request. The body is left unread for
+// the handler.
+func accountEmail(r *http.Request) string {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAccountBodyBytes))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+	if err != nil {
+		return ""
+	}
+
+	var input struct {
+		Email string `json:"Email"`
+	}
+
+	_ = json.Unmarshal(body, &input)
+
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+	if email == "" {
+		return ""
+	}
+
+	return contextGetOrganization(r).ID.String() + ":" + email
+}
+
+// authMethods returns the authentication methods listed in the amr claim of an
+// authentication token.
+func authMethods(claims *jwt.Claims) []string {
+	values, _ := claims.Set["amr"].([]any)
+
+	methods := make([]string, 0, len(values))
+	for _, value := range values {
+		if method, ok := value.(string); ok {
+			methods = append(methods, method)
+		}
+	}
+
+	return methods
+}
+
+// checkPassword adds the violations of the password policy by a password
+// chosen by a user to the field errors of v under key. userInputs is the
+// personal information of the user, such as their name and email address.
+// The flows setting a password check it with this helper, except SCIM, whose
+// errors aren't field errors and which checks the policy in scimPasswordHash.
+func (app *application) checkPassword(v *validator.Validator, key, plaintextPassword string, userInputs ...string) {
+	if plaintextPassword == "" {
+		return
+	}
+
+	for _, violation := range app.passwordPolicy.Check(plaintextPassword, userInputs...) {
+		v.AddFieldError(key, violation.Code, violation.Message)
+	}
+}
+
+// systemGrantor returns the employee recorded as the grantor of the roles no
+// employee grants, such as the ones assigned by the identity provider, so the
+// members aren't recorded as granting roles to themselves.
+func systemGrantor(ctx context.Context, q *database.Queries, organizationID uuid.UUID) (uuid.UUID, error) {
+	grantor, err := q.GetSystemGrantor(ctx, organizationID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, fmt.Errorf("no employee of organization %s holds the admin permission to grant roles", organizationID)
+	}
+
+	return grantor, err
+}
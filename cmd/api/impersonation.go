@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/request"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pascaldekloe/jwt"
+)
+
+// impersonationDuration is how long an impersonation session lasts, unless it
+// is stopped earlier.
+const impersonationDuration = time.Hour
+
+var errInvalidImpersonation = errors.New("invalid impersonation")
+
+// impersonation is the session of an admin impersonating the authenticated
+// user.
+type impersonation struct {
+	SessionID uuid.UUID
+	Actor     *database.User
+}
+
+type impersonationSessionResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	ActorID   uuid.UUID  `json:"actorId"`
+	SubjectID uuid.UUID  `json:"subjectId"`
+	Reason    string     `json:"reason"`
+	StartedAt time.Time  `json:"startedAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+}
+
+func newImpersonationSessionResponse(session database.ImpersonationSession) impersonationSessionResponse {
+	res := impersonationSessionResponse{
+		ID:        session.ID,
+		ActorID:   session.ActorID,
+		SubjectID: session.SubjectID,
+		Reason:    session.Reason,
+		StartedAt: session.StartedAt.Time,
+		ExpiresAt: session.ExpiresAt.Time,
+	}
+
+	if session.EndedAt.Valid {
+		res.EndedAt = &session.EndedAt.Time
+	}
+
+	return res
+}
+
+// checkImpersonation returns the impersonation session of a token carrying an
+// act claim, the admin acting as the subject of the token. The token is
+// rejected with errInvalidImpersonation unless its session is still running
+// for the same admin and employee, so stopping a session revokes its token.
+func (app *application) checkImpersonation(ctx context.Context, claims *jwt.Claims, organizationID uuid.UUID) (*impersonation, error) {
+	act, _ := claims.Set["act"].(map[string]any)
+	actorSubject, _ := act["sub"].(string)
+
+	actorID, err := uuid.Parse(actorSubject)
+	if err != nil {
+		return nil, errInvalidImpersonation
+	}
+
+	sessionID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, errInvalidImpersonation
+	}
+
+	session, err := app.store.GetActiveImpersonationSession(ctx, database.GetActiveImpersonationSessionParams{
+		ID:             sessionID,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errInvalidImpersonation
+		}
+
+		return nil, err
+	}
+
+	if session.ActorID != actorID || session.SubjectID.String() != claims.Subject {
+		return nil, errInvalidImpersonation
+	}
+
+	actor, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             actorID,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errInvalidImpersonation
+		}
+
+		return nil, err
+	}
+
+	return &impersonation{SessionID: session.ID, Actor: &actor}, nil
+}
+
+// startImpersonationHandler responds with an authentication token for the
+// employee, carrying the admin in its act claim. The admin keeps the
+// authentication methods of their own token, and the token is only valid
+// while the session lasts. Admins can't be impersonated, nor employees who
+// aren't active, whose tokens authenticate rejects.
+func (app *application) startImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	var input struct {
+		Reason    string              `json:"reason"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	subject, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	permissions, err := app.store.GetPermissionsForEmployee(ctx, database.GetPermissionsForEmployeeParams{
+		UserID:         subject.ID,
+		OrganizationID: subject.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	actor := contextGetAuthenticatedUser(r)
+
+	input.Validator.CheckField(validator.NotBlank(input.Reason), "Reason", "reason_required", "Reason is required")
+	input.Validator.CheckField(validator.MaxRunes(input.Reason, 500), "Reason", "reason_too_long", "Reason must not be more than 500 characters long")
+	input.Validator.Check(subject.ID != actor.ID, "impersonation_self", "You can't impersonate yourself")
+	input.Validator.Check(!validator.In("admin", permissions...), "impersonation_admin", "Holders of the admin permission can't be impersonated")
+	input.Validator.Check(subject.Status == employeeStatusActive, "impersonation_inactive", "Only active employees can be impersonated")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	session, err := app.store.CreateImpersonationSession(ctx, database.CreateImpersonationSessionParams{
+		ActorID:        actor.ID,
+		SubjectID:      subject.ID,
+		Reason:         input.Reason,
+		ExpiresAt:      pgtype.Timestamp{Time: time.Now().UTC().Add(impersonationDuration), Valid: true},
+		OrganizationID: subject.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.InfoContext(ctx, "impersonation started", slog.Group("impersonation",
+		"session", session.ID,
+		"actor", session.ActorID,
+		"subject", session.SubjectID,
+		"reason", session.Reason,
+	))
+
+	var claims jwt.Claims
+
+	claims.ID = session.ID.String()
+	claims.Subject = subject.ID.String()
+
+	claims.Issued = jwt.NewNumericTime(time.Now())
+	claims.NotBefore = jwt.NewNumericTime(time.Now())
+	claims.Expires = jwt.NewNumericTime(session.ExpiresAt.Time)
+
+	claims.Issuer = app.config.baseURL
+	claims.Audiences = []string{app.config.baseURL}
+
+	claims.Set = map[string]any{
+		"amr":             contextGetAuthMethods(r),
+		"act":             map[string]any{"sub": actor.ID.String()},
+		organizationClaim: subject.OrganizationID.String(),
+	}
+
+	jwtBytes, err := claims.HMACSign(jwt.HS256, []byte(app.config.jwt.secretKey))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := map[string]any{
+		"authenticationToken":       string(jwtBytes),
+		"authenticationTokenExpiry": session.ExpiresAt.Time.Format(time.RFC3339),
+		"impersonationSession":      newImpersonationSessionResponse(session),
+	}
+
+	err = response.JSON(w, http.StatusCreated, data)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// stopImpersonationHandler ends the impersonation session of the token, which
+// can't be used anymore.
+func (app *application) stopImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	impersonation := contextGetImpersonation(r)
+	if impersonation == nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	session, err := app.store.EndImpersonationSession(ctx, database.EndImpersonationSessionParams{
+		ID:             impersonation.SessionID,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.logger.InfoContext(ctx, "impersonation stopped", slog.Group("impersonation",
+		"session", session.ID,
+		"actor", session.ActorID,
+		"subject", session.SubjectID,
+	))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) listImpersonationSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	sessions, err := app.store.GetImpersonationSessions(ctx, database.GetImpersonationSessionsParams{
+		Limit:          100,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := []impersonationSessionResponse{}
+	for _, session := range sessions {
+		data = append(data, newImpersonationSessionResponse(session))
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"impersonationSessions": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// organizationJob does the work of a periodic job for a single organization.
+// r stands for the job, and its context is bound to the organization.
+type organizationJob func(ctx context.Context, r *http.Request, organizationID uuid.UUID) error
+
+// runOrganizationJob runs job for every organization on start and then at every
+// interval, until ctx is cancelled.
+func (app *application) runOrganizationJob(ctx context.Context, name string, interval time.Duration, job organizationJob) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.runOrganizationJobOnce(ctx, name, job)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOrganizationJobOnce runs job for every organization, in a trace of its
+// own. An organization failing is reported without stopping the others.
+func (app *application) runOrganizationJobOnce(ctx context.Context, name string, job organizationJob) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name, trace.WithNewRoot())
+	defer span.End()
+
+	r := jobRequest(ctx, strings.ReplaceAll(name, " ", "-"))
+
+	organizations, err := app.store.GetOrganizations(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			app.reportServerError(r, err)
+		}
+		return
+	}
+
+	for _, organization := range organizations {
+		r := contextSetOrganization(r, &organization)
+
+		err := job(r.Context(), r, organization.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			app.reportServerError(r, err)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/ldap"
+	"github.com/brGuirra/uai/internal/password"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ldapSyncTimeout bounds a whole directory sync.
+const ldapSyncTimeout = 10 * time.Minute
+
+// errLDAPEmployeeDeleted is returned when the employee of a directory user is
+// deleted. They're left alone until an admin restores them.
+var errLDAPEmployeeDeleted = errors.New("employee deleted")
+
+// ldapDirectory is the directory the employees are synchronized from, and
+// sign in against with LDAP authentication. It is an *ldap.Directory.
+type ldapDirectory interface {
+	Users(ctx context.Context) ([]ldap.User, error)
+	Authenticate(ctx context.Context, dn, password string) error
+}
+
+type ldapSyncResult struct {
+	Users       int `json:"users"`
+	Created     int `json:"created"`
+	Updated     int `json:"updated"`
+	Deactivated int `json:"deactivated"`
+	Skipped     int `json:"skipped"`
+	Failed      int `json:"failed"`
+}
+
+// runLDAPSync synchronizes the employees with the directory on start and then
+// at every sync interval, until ctx is cancelled.
+func (app *application) runLDAPSync(ctx context.Context) {
+	ticker := time.NewTicker(app.config.ldap.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		app.runLDAPSyncOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *application) runLDAPSyncOnce(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, ldapSyncTimeout)
+	defer cancel()
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "ldap sync", trace.WithNewRoot())
+	defer span.End()
+
+	r := jobRequest(ctx, "ldap-sync")
+
+	organization, err := app.store.GetOrganizationBySlug(ctx, app.config.ldap.organization)
+	if err == nil {
+		r = contextSetOrganization(r, &organization)
+		_, err = app.syncLDAP(r.Context(), r)
+	}
+	if err != nil && ctx.Err() == nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		app.reportServerError(r, err)
+	}
+}
+
+// syncLDAP creates and updates the employees of the directory and their roles,
+// and deactivates the synchronized employees no longer in it. An employee
+// already having the email address of a directory user is linked to them.
+// Failing to synchronize a user is logged without stopping the sync. The
+// directory is synchronized into the organization of r.
+func (app *application) syncLDAP(ctx context.Context, r *http.Request) (ldapSyncResult, error) {
+	var result ldapSyncResult
+
+	organizationID := contextGetOrganization(r).ID
+
+	users, err := app.ldap.Users(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	result.Users = len(users)
+
+	roleIDs := make(map[string]uuid.UUID)
+
+	for _, name := range app.config.ldap.groupRoles.Managed() {
+		role, err := app.store.GetRoleByDisplayName(ctx, database.GetRoleByDisplayNameParams{
+			DisplayName:    name,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return result, fmt.Errorf("ldap sync: role %q of the group mapping doesn't exist", name)
+			}
+
+			return result, err
+		}
+
+		roleIDs[name] = role.ID
+	}
+
+	dns := make([]string, 0, len(users))
+
+	for _, user := range users {
+		dns = append(dns, user.DN)
+
+		created, updated, err := app.syncLDAPUser(ctx, r, user, roleIDs)
+		if errors.Is(err, errLDAPEmployeeDeleted) {
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+
+			result.Failed++
+			app.logger.WarnContext(ctx, "ldap sync failed for user", slog.Group("ldap", "dn", user.DN, "error", err.Error()))
+			continue
+		}
+
+		switch {
+		case created:
+			result.Created++
+		case updated:
+			result.Updated++
+		}
+	}
+
+	// An empty directory is more likely a misconfigured base DN or filter
+	// than everyone leaving, so nobody is deactivated.
+	if len(users) > 0 {
+		var deliveries []database.WebhookDelivery
+
+		err := app.store.ExecTx(ctx, func(q *database.Queries) error {
+			deactivated, err := q.DeactivateMissingLDAPUsers(ctx, database.DeactivateMissingLDAPUsersParams{
+				Dns:            dns,
+				OrganizationID: organizationID,
+			})
+			if err != nil {
+				return err
+			}
+
+			result.Deactivated = len(deactivated)
+
+			for _, user := range deactivated {
+				scheduled, err := publishEvent(ctx, q, organizationID, eventEmployeeDeactivated, employeeEventData(user))
+				if err != nil {
+					return err
+				}
+
+				deliveries = append(deliveries, scheduled...)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+
+		app.deliverWebhooks(r, deliveries)
+	} else {
+		app.logger.WarnContext(ctx, "ldap sync found no users, skipping deactivations")
+	}
+
+	app.logger.InfoContext(ctx, "ldap sync completed", slog.Group("ldap",
+		"users", result.Users,
+		"created", result.Created,
+		"updated", result.Updated,
+		"deactivated", result.Deactivated,
+		"skipped", result.Skipped,
+		"failed", result.Failed,
+	))
+
+	return result, nil
+}
+
+// syncLDAPUser creates or updates the employee of a directory user, and
+// grants or revokes the roles mapped to directory groups so they match the
+// groups of the user. The roles that aren't mapped are left alone.
+func (app *application) syncLDAPUser(ctx context.Context, r *http.Request, entry ldap.User, roleIDs map[string]uuid.UUID) (created, updated bool, err error) {
+	status := employeeStatusActive
+	if entry.Disabled {
+		status = employeeStatusDeactivated
+	}
+
+	name := entry.Name
+	if name == "" {
+		name = entry.Email
+	}
+
+	roles := app.config.ldap.groupRoles.Roles(entry.Groups)
+
+	organizationID := contextGetOrganization(r).ID
+
+	var (
+		before, after    database.User
+		granted, revoked []uuid.UUID
+		deliveries       []database.WebhookDelivery
+	)
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		account, err := q.GetLDAPAccountByDN(ctx, database.GetLDAPAccountByDNParams{
+			Dn:             entry.DN,
+			OrganizationID: organizationID,
+		})
+
+		switch {
+		case err == nil:
+			before, err = q.GetUser(ctx, database.GetUserParams{
+				ID:             account.UserID,
+				OrganizationID: organizationID,
+			})
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errLDAPEmployeeDeleted
+			}
+			if err != nil {
+				return err
+			}
+
+		case errors.Is(err, pgx.ErrNoRows):
+			existing, err := q.ListUsers(ctx, database.ListUsersParams{
+				Email:          pgtype.Text{String: entry.Email, Valid: true},
+				OrganizationID: organizationID,
+				Limit:          1,
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(existing) > 0 {
+				before = existing[0]
+				break
+			}
+
+			deleted, err := q.UserEmailExists(ctx, database.UserEmailExistsParams{
+				Email:          entry.Email,
+				OrganizationID: organizationID,
+			})
+			if err != nil {
+				return err
+			}
+
+			if deleted {
+				return errLDAPEmployeeDeleted
+			}
+
+			before, err = q.CreateUser(ctx, database.CreateUserParams{
+				Name:           name,
+				Email:          entry.Email,
+				Status:         status,
+				OrganizationID: organizationID,
+			})
+			if err != nil {
+				return err
+			}
+
+			created = true
+
+		default:
+			return err
+		}
+
+		after = before
+		after.Name = name
+		after.Email = entry.Email
+
+		// Terminated employees stay terminated, even if their directory
+		// entry is still enabled.
+		if before.Status != employeeStatusTerminated {
+			after.Status = status
+		}
+
+		if after.Name != before.Name || after.Email != before.Email || after.Status != before.Status {
+			err = q.UpdateUser(ctx, database.UpdateUserParams{
+				ID:             after.ID,
+				Name:           after.Name,
+				Email:          after.Email,
+				HashedPassword: after.HashedPassword,
+				Status:         after.Status,
+				OrganizationID: organizationID,
+			})
+			if err != nil {
+				return err
+			}
+
+			updated = true
+		}
+
+		err = q.UpsertLDAPAccount(ctx, database.UpsertLDAPAccountParams{
+			UserID:         after.ID,
+			Dn:             entry.DN,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		current, err := q.GetRolesForUser(ctx, database.GetRolesForUserParams{
+			UserID:         after.ID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		for name, roleID := range roleIDs {
+			want := slices.Contains(roles, name)
+			has := slices.ContainsFunc(current, func(role database.GetRolesForUserRow) bool {
+				return role.ID == roleID
+			})
+
+			switch {
+			case want && !has:
+				var grantor uuid.UUID
+
+				grantor, err = systemGrantor(ctx, q, organizationID)
+				if err != nil {
+					return err
+				}
+
+				err = q.AddRoleMembers(ctx, database.AddRoleMembersParams{RoleID: roleID, Grantor: grantor, OrganizationID: organizationID, UserIds: []uuid.UUID{after.ID}})
+				granted = append(granted, roleID)
+			case !want && has:
+				err = q.RemoveRoleMembers(ctx, database.RemoveRoleMembersParams{RoleID: roleID, UserIds: []uuid.UUID{after.ID}, OrganizationID: organizationID})
+				revoked = append(revoked, roleID)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if created {
+			deliveries, err = publishEvent(ctx, q, organizationID, eventEmployeeCreated, employeeEventData(after))
+		} else {
+			deliveries, err = publishEmployeeStatusChange(ctx, q, before, after)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, roleID := range granted {
+			scheduled, err := publishEvent(ctx, q, organizationID, eventEmployeeRolesChanged, rolesChangedEventData(after.ID, roleID, true))
+			if err != nil {
+				return err
+			}
+
+			deliveries = append(deliveries, scheduled...)
+		}
+
+		for _, roleID := range revoked {
+			scheduled, err := publishEvent(ctx, q, organizationID, eventEmployeeRolesChanged, rolesChangedEventData(after.ID, roleID, false))
+			if err != nil {
+				return err
+			}
+
+			deliveries = append(deliveries, scheduled...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, false, err
+	}
+
+	app.deliverWebhooks(r, deliveries)
+
+	return created, updated || len(granted) > 0 || len(revoked) > 0, nil
+}
+
+// passwordMatches checks the password of the employee. With LDAP
+// authentication enabled, the employees synchronized from the directory are
+// checked with a bind as their directory user instead of their stored hash.
+// directory reports whether the directory checked the password.
+func (app *application) passwordMatches(ctx context.Context, employee database.User, plaintextPassword string) (matches, directory bool, err error) {
+	if app.ldap != nil && app.config.ldap.authentication && employee.Email != "" {
+		account, err := app.store.GetLDAPAccount(ctx, database.GetLDAPAccountParams{
+			UserID:         employee.ID,
+			OrganizationID: employee.OrganizationID,
+		})
+
+		switch {
+		case err == nil:
+			err = app.ldap.Authenticate(ctx, account.Dn, plaintextPassword)
+			if errors.Is(err, ldap.ErrInvalidCredentials) {
+				return false, true, nil
+			}
+
+			return err == nil, true, err
+
+		case !errors.Is(err, pgx.ErrNoRows):
+			return false, false, err
+		}
+	}
+
+	matches, err = password.Matches(plaintextPassword, employee.HashedPassword.String)
+
+	return matches, false, err
+}
+
+func (app *application) syncLDAPHandler(w http.ResponseWriter, r *http.Request) {
+	// The directory belongs to a single organization.
+	if app.ldap == nil || contextGetOrganization(r).Slug != app.config.ldap.organization {
+		app.notFound(w, r)
+		return
+	}
+
+	app.backgroundTask(r, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, ldapSyncTimeout)
+		defer cancel()
+
+		_, err := app.syncLDAP(ctx, r.WithContext(ctx))
+		return err
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brGuirra/uai/internal/ldap"
+)
+
+// testDirectory is a directory with the given users, binding with the
+// passwords keyed by DN. Users missing from users can still bind, as the
+// accounts moved out of the user filter do.
+type testDirectory struct {
+	users     []ldap.User
+	passwords map[string]string
+}
+
+func (d *testDirectory) Users(ctx context.Context) ([]ldap.User, error) {
+	return d.users, nil
+}
+
+func (d *testDirectory) Authenticate(ctx context.Context, dn, password string) error {
+	if password == "" || d.passwords[dn] != password {
+		return ldap.ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+func TestLDAPSyncRevokesRemovedUsers(t *testing.T) {
+	app, store := newTestApplication(t)
+
+	ada := newTestEmployee(store, "Ada Lovelace", "ada@example.com")
+	grace := newTestEmployee(store, "Grace Hopper", "grace@example.com")
+
+	adaDN := "uid=ada,ou=people,dc=example,dc=com"
+	graceDN := "uid=grace,ou=people,dc=example,dc=com"
+
+	store.ldapAccounts[ada.ID] = adaDN
+	store.ldapAccounts[grace.ID] = graceDN
+
+	app.ldap = &testDirectory{
+		users: []ldap.User{{DN: graceDN, Email: grace.Email, Name: grace.Name}},
+		passwords: map[string]string{
+			adaDN:   "pa55word",
+			graceDN: "pa55word",
+		},
+	}
+	app.config.ldap.organization = store.organization.Slug
+	app.config.ldap.authentication = true
+
+	token := issueTestToken(t, app, store, ada)
+
+	if status := authenticateTestRequest(app, store, token); status != http.StatusOK {
+		t.Fatalf("before the sync: got status %d; want %d", status, http.StatusOK)
+	}
+
+	result, err := app.syncLDAP(context.Background(), newTestRequest(store, http.MethodPost, "/api/v1/ldap/sync", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.wg.Wait()
+
+	if result.Deactivated != 1 {
+		t.Fatalf("got %d deactivated; want 1", result.Deactivated)
+	}
+
+	if status := store.employees[ada.ID].Status; status != employeeStatusDeactivated {
+		t.Fatalf("got status %q; want %q", status, employeeStatusDeactivated)
+	}
+
+	if len(store.events) != 1 || store.events[0].Type != eventEmployeeDeactivated {
+		t.Errorf("got events %v; want a single %s event", store.events, eventEmployeeDeactivated)
+	}
+
+	if status := authenticateTestRequest(app, store, token); status != http.StatusUnauthorized {
+		t.Errorf("after the sync: got status %d; want %d", status, http.StatusUnauthorized)
+	}
+
+	// The directory still accepts the bind, the status check refuses it.
+	body := `{"Email": "ada@example.com", "Password": "pa55word"}`
+
+	r := newTestRequest(store, http.MethodPost, "/api/v1/authentication-tokens", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	app.createAuthenticationToken(rr, r)
+
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "account_inactive") {
+		t.Errorf("signing in after the sync: got status %d and body %s; want %d with account_inactive", rr.Code, rr.Body, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/password"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxUserAgentLength bounds the user agents stored to recognize the devices
+// employees sign in from.
+const maxUserAgentLength = 512
+
+// accountLockRemaining returns how long the account of the employee stays
+// locked, or zero when it isn't.
+func (app *application) accountLockRemaining(ctx context.Context, employee database.User) (time.Duration, error) {
+	seconds, err := app.store.GetAccountLockRemaining(ctx, database.GetAccountLockRemainingParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	if seconds <= 0 {
+		return 0, nil
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// recordFailedLogin counts a sign-in attempt with an incorrect password. After
+// too many the account is locked, for a cool-down doubling with every lockout
+// since the last successful sign-in, and the employee is notified.
+func (app *application) recordFailedLogin(ctx context.Context, r *http.Request, employee database.User) error {
+	lockout, err := app.store.RecordFailedLogin(ctx, database.RecordFailedLoginParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if int(lockout.FailedAttempts) < app.config.lockout.maxAttempts {
+		return nil
+	}
+
+	cooldown := app.config.lockout.cooldown
+	for i := int32(0); i < lockout.Lockouts && cooldown < app.config.lockout.maxCooldown; i++ {
+		cooldown *= 2
+	}
+
+	cooldown = min(cooldown, app.config.lockout.maxCooldown)
+
+	err = app.store.LockAccount(ctx, database.LockAccountParams{
+		CooldownSeconds: cooldown.Seconds(),
+		UserID:          employee.ID,
+		OrganizationID:  employee.OrganizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	app.logger.WarnContext(ctx, "account locked", "employeeId", employee.ID, "cooldown", cooldown.String())
+
+	ip := app.clientIP(r)
+	organization := contextGetOrganization(r)
+
+	app.backgroundTask(r, func(ctx context.Context) error {
+		data := app.newEmailData(organization)
+		data["Name"] = employee.Name
+		data["IP"] = ip
+		data["Cooldown"] = cooldown
+
+		return app.mailer.SendFrom(ctx, organization.EmailSender.String, employee.Email, data, "account_locked.tmpl")
+	})
+
+	return nil
+}
+
+// recordSuccessfulLogin clears the failed attempts of the employee and, when
+// the sign-in comes from an IP address and user agent combination the
+// employee never used before, emails them about it. The very first sign-in is
+// not reported.
+func (app *application) recordSuccessfulLogin(ctx context.Context, r *http.Request, employee database.User) error {
+	_, err := app.store.DeleteAccountLockout(ctx, database.DeleteAccountLockoutParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	ip := app.clientIP(r)
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	login, err := app.store.RecordKnownLogin(ctx, database.RecordKnownLoginParams{
+		UserID:         employee.ID,
+		Ip:             ip,
+		UserAgent:      userAgent,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !login.New || login.PreviousLogins == 0 {
+		return nil
+	}
+
+	organization := contextGetOrganization(r)
+
+	app.backgroundTask(r, func(ctx context.Context) error {
+		data := app.newEmailData(organization)
+		data["Name"] = employee.Name
+		data["IP"] = ip
+		data["UserAgent"] = userAgent
+		data["Time"] = time.Now()
+
+		return app.mailer.SendFrom(ctx, organization.EmailSender.String, employee.Email, data, "new_login.tmpl")
+	})
+
+	return nil
+}
+
+func (app *application) unlockEmployeeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	employee, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	_, err = app.store.DeleteAccountLockout(ctx, database.DeleteAccountLockoutParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// rehashPassword replaces the stored hash of the password of the employee in
+// the background when it was made with an outdated algorithm or parameters.
+// The hash is only replaced if it didn't change in the meantime.
+func (app *application) rehashPassword(r *http.Request, employee database.User, plaintextPassword string) {
+	if !password.NeedsRehash(employee.HashedPassword.String) {
+		return
+	}
+
+	app.backgroundTask(r, func(ctx context.Context) error {
+		hashedPassword, err := password.Hash(plaintextPassword)
+		if err != nil {
+			return err
+		}
+
+		_, err = app.store.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+			NewHashedPassword: pgtype.Text{String: hashedPassword, Valid: true},
+			ID:                employee.ID,
+			OldHashedPassword: employee.HashedPassword,
+			OrganizationID:    employee.OrganizationID,
+		})
+
+		return err
+	})
+}
@@ -1,22 +1,54 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/brGuirra/uai/internal/ldap"
+	"github.com/brGuirra/uai/internal/password"
+	"github.com/brGuirra/uai/internal/pubsub"
+	"github.com/brGuirra/uai/internal/ratelimit"
+	"github.com/brGuirra/uai/internal/request"
+	"github.com/brGuirra/uai/internal/settings"
 	"github.com/brGuirra/uai/internal/smtp"
+	"github.com/brGuirra/uai/internal/sso"
+	"github.com/brGuirra/uai/internal/tracing"
+	"github.com/brGuirra/uai/internal/twofactor"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/brGuirra/uai/internal/webhook"
 
 	database "github.com/brGuirra/uai/internal/database/sqlc"
 )
 
 const version = "1.0.0"
 
+// envPrefix is the prefix of the environment variables overriding the
+// configuration settings, e.g. UAI_HTTP_PORT for the -http-port flag.
+const envPrefix = "UAI"
+
+// secretSettings are the configuration settings holding secrets. They are
+// redacted by -print-config, and must not keep their default values in
+// production. Every setting holding a secret belongs here.
+var secretSettings = []string{
+	"db-dsn",
+	"jwt-secret-key",
+	"totp-encryption-key",
+	"smtp-password",
+	"sso-client-secret",
+	"ldap-bind-password",
+}
+
 func main() {
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger := slog.New(contextHandler{slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})})
 
 	err := run(logger)
 	if err != nil {
@@ -27,31 +59,107 @@ func main() {
 }
 
 type config struct {
-	baseURL  string
-	httpPort int
-	env      string
-	cors     struct{ trustedOrigins []string }
-	db       struct {
+	baseURL       string
+	httpPort      int
+	metricsPort   int
+	env           string
+	shutdownDelay time.Duration
+	cors          struct{ trustedOrigins []string }
+	db            struct {
 		dsn string
 	}
+	tenancy struct {
+		domain              string
+		defaultOrganization string
+	}
 	jwt struct {
 		secretKey string
 	}
+	totp struct {
+		encryptionKey string
+	}
 	smtp struct {
 		host     string
 		port     int
 		username string
 		password string
 		from     string
+		dkim     struct {
+			domain         string
+			selector       string
+			privateKeyFile string
+		}
+	}
+	lockout struct {
+		maxAttempts int
+		cooldown    time.Duration
+		maxCooldown time.Duration
+	}
+	sso struct {
+		issuerURL    string
+		clientID     string
+		clientSecret string
+		redirectURL  string
+		scopes       []string
+		provisioning bool
+		defaultRole  string
 	}
+	ldap struct {
+		url            string
+		startTLS       bool
+		bindDN         string
+		bindPassword   string
+		baseDN         string
+		userFilter     string
+		emailAttribute string
+		nameAttribute  string
+		groupAttribute string
+		groupRoles     ldap.GroupRoles
+		syncInterval   time.Duration
+		authentication bool
+		organization   string
+	}
+	deletion struct {
+		retention time.Duration
+	}
+	password struct {
+		minLength           int
+		minCharacterClasses int
+		minScore            int
+		breachList          string
+	}
+	rateLimit struct {
+		enabled      bool
+		store        string
+		api          ratelimit.Limit
+		loginIP      ratelimit.Limit
+		loginAccount ratelimit.Limit
+	}
+	otel struct {
+		exporter    string
+		endpoint    string
+		sampleRatio float64
+	}
+	trustedProxies request.TrustedProxies
 }
 
 type application struct {
-	config config
-	store  database.Querier
-	logger *slog.Logger
-	mailer *smtp.Mailer
-	wg     sync.WaitGroup
+	config          config
+	store           database.Store
+	logger          *slog.Logger
+	mailer          *smtp.Mailer
+	smtpCheck       *cachedCheck
+	limiter         ratelimit.Store
+	passwordPolicy  password.Policy
+	totpSecrets     *twofactor.SecretBox
+	sso             *sso.Provider
+	ldap            ldapDirectory
+	webhooks        *webhook.Client
+	events          *pubsub.Broker[event]
+	metrics         *metrics
+	shuttingDown    atomic.Bool
+	backgroundTasks atomic.Int64
+	wg              sync.WaitGroup
 }
 
 func run(logger *slog.Logger) error {
@@ -59,25 +167,110 @@ func run(logger *slog.Logger) error {
 
 	flag.StringVar(&cfg.baseURL, "base-url", "http://localhost:4000", "base URL for the application")
 	flag.IntVar(&cfg.httpPort, "http-port", 4000, "port to listen on for HTTP requests")
+	flag.IntVar(&cfg.metricsPort, "metrics-port", 4001, "port to listen on for metrics requests, to keep off the public network (metrics are not served when 0)")
 	flag.StringVar(&cfg.env, "env", "development", "environment (development|staging|production)")
+	flag.DurationVar(&cfg.shutdownDelay, "shutdown-delay", 5*time.Second, "time to report not ready before shutting down the server")
 
 	flag.StringVar(&cfg.db.dsn, "db-dsn", "user:pass@localhost:5432/db", "postgreSQL DSN")
 
+	flag.StringVar(&cfg.tenancy.domain, "tenant-domain", "", "domain whose subdomains are the slugs of the organizations, e.g. uai.example.com (organizations are only resolved from tokens when empty)")
+	flag.StringVar(&cfg.tenancy.defaultOrganization, "default-organization", "default", "slug of the organization of the requests naming none")
+
 	flag.StringVar(&cfg.jwt.secretKey, "jwt-secret-key", "ccw3wg3ombip3656l672bgwm3svsz7sh", "secret key for JWT authentication")
+	flag.StringVar(&cfg.totp.encryptionKey, "totp-encryption-key", "x8tq2m5v7cgn3jk4p6wz9rbd2hf5ls7a", "secret key encrypting the stored TOTP secrets (at least 32 characters)")
 
 	flag.StringVar(&cfg.smtp.host, "smtp-host", "example.smtp.host", "smtp host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "smtp port")
 	flag.StringVar(&cfg.smtp.username, "smtp-username", "example_username", "smtp username")
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "pa55word", "smtp password")
 	flag.StringVar(&cfg.smtp.from, "smtp-from", "Example Name <no-reply@example.org>", "smtp sender")
+	flag.StringVar(&cfg.smtp.dkim.domain, "smtp-dkim-domain", "", "DKIM signing domain (defaults to the smtp sender domain)")
+	flag.StringVar(&cfg.smtp.dkim.selector, "smtp-dkim-selector", "", "DKIM selector")
+	flag.StringVar(&cfg.smtp.dkim.privateKeyFile, "smtp-dkim-private-key", "", "path to the PEM encoded DKIM private key (signing is disabled when empty)")
 
-	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
-		cfg.cors.trustedOrigins = strings.Fields(val)
-		return nil
-	})
+	flag.IntVar(&cfg.lockout.maxAttempts, "lockout-max-attempts", 5, "failed sign-in attempts before an account is locked")
+	flag.DurationVar(&cfg.lockout.cooldown, "lockout-cooldown", 15*time.Minute, "duration of the first lockout of an account, doubled for each following one")
+	flag.DurationVar(&cfg.lockout.maxCooldown, "lockout-max-cooldown", 24*time.Hour, "maximum duration of a lockout")
+
+	cfg.rateLimit.api = ratelimit.Limit{Burst: 120, Period: time.Minute}
+	cfg.rateLimit.loginIP = ratelimit.Limit{Burst: 20, Period: time.Minute}
+	cfg.rateLimit.loginAccount = ratelimit.Limit{Burst: 5, Period: 15 * time.Minute}
+
+	flag.StringVar(&cfg.sso.issuerURL, "sso-issuer-url", "", "OpenID Connect issuer URL (single sign-on is disabled when empty)")
+	flag.StringVar(&cfg.sso.clientID, "sso-client-id", "", "OpenID Connect client ID")
+	flag.StringVar(&cfg.sso.clientSecret, "sso-client-secret", "", "OpenID Connect client secret")
+	flag.StringVar(&cfg.sso.redirectURL, "sso-redirect-url", "", "OpenID Connect redirect URL (defaults to the callback URL under the base URL)")
+	flag.Var((*settings.StringList)(&cfg.sso.scopes), "sso-scopes", "OpenID Connect scopes (space separated, defaults to openid email profile)")
+	flag.BoolVar(&cfg.sso.provisioning, "sso-provisioning", false, "create the employees signing in with single sign-on for the first time")
+	flag.StringVar(&cfg.sso.defaultRole, "sso-default-role", "employee", "role given to the employees created by single sign-on")
+
+	flag.StringVar(&cfg.ldap.url, "ldap-url", "", "LDAP server URL, as ldap:// or ldaps:// (directory sync is disabled when empty)")
+	flag.BoolVar(&cfg.ldap.startTLS, "ldap-start-tls", false, "upgrade ldap:// connections with StartTLS")
+	flag.StringVar(&cfg.ldap.bindDN, "ldap-bind-dn", "", "DN of the LDAP account searching the directory")
+	flag.StringVar(&cfg.ldap.bindPassword, "ldap-bind-password", "", "password of the LDAP account searching the directory")
+	flag.StringVar(&cfg.ldap.baseDN, "ldap-base-dn", "", "DN under which LDAP users are searched")
+	flag.StringVar(&cfg.ldap.userFilter, "ldap-user-filter", "(objectClass=person)", "LDAP filter selecting the users to synchronize")
+	flag.StringVar(&cfg.ldap.emailAttribute, "ldap-email-attribute", "mail", "LDAP attribute holding the email address of users")
+	flag.StringVar(&cfg.ldap.nameAttribute, "ldap-name-attribute", "cn", "LDAP attribute holding the name of users")
+	flag.StringVar(&cfg.ldap.groupAttribute, "ldap-group-attribute", "memberOf", "LDAP attribute holding the group DNs of users")
+	flag.Var(&cfg.ldap.groupRoles, "ldap-group-roles", "roles given to the members of LDAP groups, as <role>:<group DN> entries separated by semicolons")
+	flag.DurationVar(&cfg.ldap.syncInterval, "ldap-sync-interval", 15*time.Minute, "interval between LDAP directory syncs (periodic syncs are disabled when 0)")
+	flag.BoolVar(&cfg.ldap.authentication, "ldap-authentication", false, "check the passwords of synchronized employees with an LDAP bind")
+	flag.StringVar(&cfg.ldap.organization, "ldap-organization", "default", "slug of the organization the LDAP directory is synchronized into")
+
+	flag.DurationVar(&cfg.deletion.retention, "deletion-retention", 30*24*time.Hour, "time deleted employees and roles can be restored before they're purged (purging is disabled when 0)")
+
+	flag.IntVar(&cfg.password.minLength, "password-min-length", 8, "minimum length of passwords")
+	flag.IntVar(&cfg.password.minCharacterClasses, "password-min-character-classes", 0, "character classes (lowercase, uppercase, digits, symbols) passwords must contain")
+	flag.IntVar(&cfg.password.minScore, "password-min-score", 2, "minimum strength score of passwords, from 0 to 4")
+	flag.StringVar(&cfg.password.breachList, "password-breach-list", "", "path to a file of breached passwords or SHA-1 hashes to reject")
+
+	flag.BoolVar(&cfg.rateLimit.enabled, "rate-limit-enabled", true, "enable rate limiting")
+	flag.StringVar(&cfg.rateLimit.store, "rate-limit-store", "memory", "rate limit store (memory|postgres)")
+	flag.Var(&cfg.rateLimit.api, "rate-limit-api", "API requests allowed per client IP, as <requests>/<period>")
+	flag.Var(&cfg.rateLimit.loginIP, "rate-limit-login-ip", "authentication requests allowed per client IP, as <requests>/<period>")
+	flag.Var(&cfg.rateLimit.loginAccount, "rate-limit-login-account", "authentication requests allowed per account email, as <requests>/<period>")
+
+	flag.StringVar(&cfg.otel.exporter, "otel-exporter", "none", "trace exporter (none|stdout|otlp)")
+	flag.StringVar(&cfg.otel.endpoint, "otel-endpoint", "", "OTLP/HTTP endpoint URL, e.g. http://localhost:4318")
+	flag.Float64Var(&cfg.otel.sampleRatio, "otel-sample-ratio", 1, "fraction of the traces started by the application to sample")
+
+	flag.Var((*settings.StringList)(&cfg.cors.trustedOrigins), "cors-trusted-origins", "Trusted CORS origins (space separated)")
+	flag.Var(&cfg.trustedProxies, "trusted-proxies", "IP addresses or CIDR ranges of the proxies whose X-Forwarded-For and X-Real-IP headers are trusted (space separated)")
+
+	configFile := flag.String("config", os.Getenv(settings.EnvName(envPrefix, "config")), "path to a YAML or TOML configuration file")
+	printConfig := flag.Bool("print-config", false, "print the configuration with secrets redacted and exit")
 
 	flag.Parse()
 
+	err := settings.Load(flag.CommandLine, envPrefix, *configFile)
+	if err != nil {
+		return err
+	}
+
+	if *printConfig {
+		return settings.Print(os.Stdout, flag.CommandLine, secretSettings, "config", "print-config")
+	}
+
+	err = validateConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.otel.exporter, cfg.otel.endpoint, "uai", version, cfg.otel.sampleRatio)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := shutdownTracing(ctx)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}()
+
 	store, err := database.NewStore(cfg.db.dsn)
 	if err != nil {
 		return err
@@ -88,12 +281,170 @@ func run(logger *slog.Logger) error {
 		return err
 	}
 
+	if cfg.smtp.dkim.privateKeyFile != "" {
+		err = mailer.EnableDKIM(cfg.smtp.dkim.domain, cfg.smtp.dkim.selector, cfg.smtp.dkim.privateKeyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	var limiter ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.rateLimit.store == "postgres" {
+		limiter = ratelimit.NewPostgresStore(store)
+	}
+
+	passwordPolicy := password.Policy{
+		MinLength:           cfg.password.minLength,
+		MinCharacterClasses: cfg.password.minCharacterClasses,
+		MinScore:            cfg.password.minScore,
+	}
+
+	if cfg.password.breachList != "" {
+		passwordPolicy.Breached, err = password.LoadBreachList(cfg.password.breachList, 0.001)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("loaded password breach list", "entries", passwordPolicy.Breached.Len())
+	}
+
+	totpSecrets, err := twofactor.NewSecretBox(cfg.totp.encryptionKey)
+	if err != nil {
+		return err
+	}
+
+	var ssoProvider *sso.Provider
+	if cfg.sso.issuerURL != "" {
+		redirectURL := cfg.sso.redirectURL
+		if redirectURL == "" {
+			redirectURL = cfg.baseURL + ssoCookiePath + "/callback"
+		}
+
+		ssoProvider = sso.NewProvider(sso.Config{
+			IssuerURL:    cfg.sso.issuerURL,
+			ClientID:     cfg.sso.clientID,
+			ClientSecret: cfg.sso.clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       cfg.sso.scopes,
+		})
+	}
+
+	// directory stays a nil interface when LDAP is disabled, rather than
+	// holding a nil *ldap.Directory, so app.ldap != nil tells it apart.
+	var directory ldapDirectory
+	if cfg.ldap.url != "" {
+		directory = ldap.NewDirectory(ldap.Config{
+			URL:            cfg.ldap.url,
+			StartTLS:       cfg.ldap.startTLS,
+			BindDN:         cfg.ldap.bindDN,
+			BindPassword:   cfg.ldap.bindPassword,
+			BaseDN:         cfg.ldap.baseDN,
+			UserFilter:     cfg.ldap.userFilter,
+			EmailAttribute: cfg.ldap.emailAttribute,
+			NameAttribute:  cfg.ldap.nameAttribute,
+			GroupAttribute: cfg.ldap.groupAttribute,
+		})
+	}
+
 	app := &application{
-		config: cfg,
-		store:  store,
-		logger: logger,
-		mailer: mailer,
+		config:         cfg,
+		store:          store,
+		logger:         logger,
+		mailer:         mailer,
+		smtpCheck:      &cachedCheck{check: mailer.Ping, interval: smtpCheckInterval},
+		limiter:        limiter,
+		passwordPolicy: passwordPolicy,
+		totpSecrets:    totpSecrets,
+		sso:            ssoProvider,
+		ldap:           directory,
+		webhooks:       webhook.NewClient("UAI-Webhooks/" + version),
+		events:         pubsub.NewBroker[event](64),
 	}
 
+	app.metrics = app.newMetrics()
+
 	return app.serveHTTP()
 }
+
+func validateConfig(cfg config) error {
+	if !validator.In(cfg.env, "development", "staging", "production") {
+		return fmt.Errorf("invalid environment %q, must be 'development', 'staging' or 'production'", cfg.env)
+	}
+
+	if cfg.metricsPort == cfg.httpPort {
+		return errors.New("metrics-port must differ from http-port, so metrics stay off the public listener")
+	}
+
+	if cfg.tenancy.defaultOrganization == "" {
+		return errors.New("default-organization must not be empty")
+	}
+
+	if cfg.lockout.maxAttempts < 1 {
+		return errors.New("lockout-max-attempts must be at least 1")
+	}
+
+	if cfg.lockout.cooldown <= 0 || cfg.lockout.maxCooldown < cfg.lockout.cooldown {
+		return errors.New("lockout-cooldown must be positive and not greater than lockout-max-cooldown")
+	}
+
+	if cfg.sso.issuerURL != "" && cfg.sso.clientID == "" {
+		return errors.New("sso-client-id is required when sso-issuer-url is set")
+	}
+
+	if cfg.ldap.url != "" {
+		if !strings.HasPrefix(cfg.ldap.url, "ldap://") && !strings.HasPrefix(cfg.ldap.url, "ldaps://") {
+			return fmt.Errorf("invalid LDAP URL %q, must start with ldap:// or ldaps://", cfg.ldap.url)
+		}
+
+		if cfg.ldap.baseDN == "" {
+			return errors.New("ldap-base-dn is required when ldap-url is set")
+		}
+
+		if cfg.ldap.syncInterval < 0 {
+			return errors.New("ldap-sync-interval must not be negative")
+		}
+	}
+
+	if cfg.deletion.retention < 0 {
+		return errors.New("deletion-retention must not be negative")
+	}
+
+	if cfg.password.minLength < 1 || cfg.password.minLength > password.MaxLength {
+		return fmt.Errorf("password-min-length must be between 1 and %d", password.MaxLength)
+	}
+
+	if cfg.password.minCharacterClasses < 0 || cfg.password.minCharacterClasses > 4 {
+		return errors.New("password-min-character-classes must be between 0 and 4")
+	}
+
+	if cfg.password.minScore < 0 || cfg.password.minScore > 4 {
+		return errors.New("password-min-score must be between 0 and 4")
+	}
+
+	if !validator.In(cfg.rateLimit.store, "memory", "postgres") {
+		return fmt.Errorf("invalid rate limit store %q, must be 'memory' or 'postgres'", cfg.rateLimit.store)
+	}
+
+	if !validator.In(cfg.otel.exporter, "none", "stdout", "otlp") {
+		return fmt.Errorf("invalid trace exporter %q, must be 'none', 'stdout' or 'otlp'", cfg.otel.exporter)
+	}
+
+	if cfg.otel.sampleRatio < 0 || cfg.otel.sampleRatio > 1 {
+		return errors.New("otel-sample-ratio must be between 0 and 1")
+	}
+
+	if cfg.env != "production" {
+		return nil
+	}
+
+	err := settings.CheckSecrets(flag.CommandLine, secretSettings...)
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.jwt.secretKey) < 32 {
+		return errors.New("jwt-secret-key must be at least 32 characters long in production")
+	}
+
+	return nil
+}
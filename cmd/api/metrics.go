@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "uai"
+
+type metrics struct {
+	registry        *prometheus.Registry
+	httpRequests    *prometheus.CounterVec
+	httpDurations   *prometheus.HistogramVec
+	httpRequestsNow prometheus.Gauge
+}
+
+// newMetrics registers the application metrics. Besides the HTTP metrics,
+// which are updated by the recordMetrics middleware, the collectors read the
+// counters kept by the database pool, the mailer and backgroundTask at scrape
+// time.
+func (app *application) newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests handled, by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the HTTP requests, by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		httpRequestsNow: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests being handled.",
+		}),
+	}
+
+	emailsDesc := prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "emails_total"),
+		"Number of emails the mailer attempted to send, by result.",
+		[]string{"result"}, nil,
+	)
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDurations,
+		m.httpRequestsNow,
+		&poolCollector{app: app},
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "background_tasks_running",
+			Help:      "Number of background tasks currently running.",
+		}, func() float64 {
+			return float64(app.backgroundTasks.Load())
+		}),
+		collectorFunc{
+			desc: emailsDesc,
+			collect: func(ch chan<- prometheus.Metric) {
+				sent, failed := app.mailer.Stats()
+				ch <- prometheus.MustNewConstMetric(emailsDesc, prometheus.CounterValue, float64(sent), "success")
+				ch <- prometheus.MustNewConstMetric(emailsDesc, prometheus.CounterValue, float64(failed), "failure")
+			},
+		},
+	)
+
+	return m
+}
+
+func (app *application) metricsHandler() http.Handler {
+	return promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{})
+}
+
+var (
+	poolAcquiredConnsDesc = poolDesc("acquired_connections", "Number of connections currently acquired from the pool.", prometheus.GaugeValue)
+	poolIdleConnsDesc     = poolDesc("idle_connections", "Number of idle connections in the pool.", prometheus.GaugeValue)
+	poolTotalConnsDesc    = poolDesc("total_connections", "Number of connections in the pool.", prometheus.GaugeValue)
+	poolMaxConnsDesc      = poolDesc("max_connections", "Maximum size of the pool.", prometheus.GaugeValue)
+	poolAcquiresDesc      = poolDesc("acquires_total", "Number of successful acquires from the pool.", prometheus.CounterValue)
+	poolEmptyAcquiresDesc = poolDesc("empty_acquires_total", "Number of acquires that had to wait for a connection.", prometheus.CounterValue)
+	poolWaitDesc          = poolDesc("acquire_duration_seconds_total", "Total time spent acquiring connections from the pool.", prometheus.CounterValue)
+)
+
+type valuedDesc struct {
+	*prometheus.Desc
+	valueType prometheus.ValueType
+}
+
+func poolDesc(name, help string, valueType prometheus.ValueType) valuedDesc {
+	return valuedDesc{
+		Desc:      prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "db_pool", name), help, nil, nil),
+		valueType: valueType,
+	}
+}
+
+// poolCollector exposes the pgxpool statistics, read once per scrape.
+type poolCollector struct {
+	app *application
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []valuedDesc{poolAcquiredConnsDesc, poolIdleConnsDesc, poolTotalConnsDesc, poolMaxConnsDesc, poolAcquiresDesc, poolEmptyAcquiresDesc, poolWaitDesc} {
+		ch <- d.Desc
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.app.store.Stat()
+
+	values := map[valuedDesc]float64{
+		poolAcquiredConnsDesc: float64(stat.AcquiredConns()),
+		poolIdleConnsDesc:     float64(stat.IdleConns()),
+		poolTotalConnsDesc:    float64(stat.TotalConns()),
+		poolMaxConnsDesc:      float64(stat.MaxConns()),
+		poolAcquiresDesc:      float64(stat.AcquireCount()),
+		poolEmptyAcquiresDesc: float64(stat.EmptyAcquireCount()),
+		poolWaitDesc:          stat.AcquireDuration().Seconds(),
+	}
+
+	for d, value := range values {
+		ch <- prometheus.MustNewConstMetric(d.Desc, d.valueType, value)
+	}
+}
+
+// collectorFunc is a prometheus.Collector producing the metrics of a single
+// descriptor with a function.
+type collectorFunc struct {
+	desc    *prometheus.Desc
+	collect func(ch chan<- prometheus.Metric)
+}
+
+func (c collectorFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c collectorFunc) Collect(ch chan<- prometheus.Metric) {
+	c.collect(ch)
+}
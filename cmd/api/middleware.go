@@ -1,18 +1,28 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/brGuirra/uai/internal/apikey"
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/ratelimit"
 	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/validator"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/pascaldekloe/jwt"
-	"github.com/tomasen/realip"
 )
 
 func (app *application) recoverPanic(next http.Handler) http.Handler {
@@ -28,13 +38,30 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// requestID tags the request with the X-Request-ID header sent by the client or
+// a proxy in front of the application, generating a new ID when the header is
+// missing or unusable. The ID is echoed in the response.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(requestIDHeader, requestID)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("http.request.id", requestID))
+
+		next.ServeHTTP(w, contextSetRequestID(r, requestID))
+	})
+}
+
 func (app *application) logAccess(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		mw := response.NewMetricsResponseWriter(w)
 		next.ServeHTTP(mw, r)
 
 		var (
-			ip     = realip.FromRequest(r)
+			ip     = app.clientIP(r)
 			method = r.Method
 			url    = r.URL.String()
 			proto  = r.Proto
@@ -44,7 +71,130 @@ func (app *application) logAccess(next http.Handler) http.Handler {
 		requestAttrs := slog.Group("request", "method", method, "url", url, "proto", proto)
 		responseAttrs := slog.Group("repsonse", "status", mw.StatusCode, "size", mw.BytesCount)
 
-		app.logger.Info("access", userAttrs, requestAttrs, responseAttrs)
+		app.logger.InfoContext(r.Context(), "access", userAttrs, requestAttrs, responseAttrs)
+	})
+}
+
+// traceRequest starts the server span of the request, continuing the trace of
+// the caller when it sent a traceparent header. The span is renamed after the
+// route pattern once routing is done.
+func (app *application) traceRequest(next http.Handler) http.Handler {
+	tracer := otel.Tracer(tracerName)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", app.clientIP(r)),
+				attribute.String("user_agent.original", r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		mw := response.NewMetricsResponseWriter(w)
+		next.ServeHTTP(mw, r.WithContext(ctx))
+
+		route := routePattern(r)
+
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", mw.StatusCode),
+		)
+
+		if mw.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(mw.StatusCode))
+		}
+	})
+}
+
+// recordMetrics updates the HTTP metrics. Requests are labelled with the route
+// pattern they matched rather than their path, to keep the number of series
+// bounded.
+func (app *application) recordMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		app.metrics.httpRequestsNow.Inc()
+		defer app.metrics.httpRequestsNow.Dec()
+
+		mw := response.NewMetricsResponseWriter(w)
+		next.ServeHTTP(mw, r)
+
+		route := routePattern(r)
+
+		app.metrics.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(mw.StatusCode)).Inc()
+		app.metrics.httpDurations.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// rateLimit limits the requests for which key returns the same value, within
+// scope, to limit. Requests for which key returns an empty string are not
+// limited. When the rate limit store fails the request is let through, so an
+// outage of the store doesn't take the API down with it.
+func (app *application) rateLimit(scope string, limit ratelimit.Limit, key func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.config.rateLimit.enabled || limit.IsZero() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			value := key(r)
+			if value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := detachedContext(r, time.Second)
+			defer cancel()
+
+			result, err := app.limiter.Take(ctx, scope+":"+value, limit)
+			if err != nil {
+				app.reportServerError(r, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !result.Allowed {
+				app.rateLimitExceeded(w, r, result.RetryAfter)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// resolveOrganization records the organization named by the subdomain of the
+// request, or the default organization. authenticate switches to the
+// organization of the authentication token when no subdomain names one.
+func (app *application) resolveOrganization(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug, ok := app.organizationSlug(r)
+		if !ok {
+			slug = app.config.tenancy.defaultOrganization
+		}
+
+		ctx, cancel := detachedContext(r, 5*time.Second)
+		defer cancel()
+
+		organization, err := app.store.GetOrganizationBySlug(ctx, slug)
+		if err != nil {
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				app.notFound(w, r)
+			default:
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, contextSetOrganization(r, &organization))
 	})
 }
 
@@ -60,6 +210,22 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			if len(headerParts) == 2 && headerParts[0] == "Bearer" {
 				token := headerParts[1]
 
+				if keyID, ok := apikey.Parse(token); ok {
+					serviceAccount, err := app.authenticateAPIKey(r, keyID, token)
+					if err != nil {
+						switch {
+						case errors.Is(err, errInvalidAPIKey):
+							app.invalidAuthenticationToken(w, r)
+						default:
+							app.serverError(w, r, err)
+						}
+						return
+					}
+
+					next.ServeHTTP(w, contextSetServiceAccount(r, serviceAccount))
+					return
+				}
+
 				claims, err := jwt.HMACCheck([]byte(token), []byte(app.config.jwt.secretKey))
 				if err != nil {
 					app.invalidAuthenticationToken(w, r)
@@ -81,18 +247,63 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 					return
 				}
 
+				r, err = app.contextSetTokenOrganization(r, claims.Set)
+				if err != nil {
+					switch {
+					case errors.Is(err, errUnknownOrganization):
+						app.invalidAuthenticationToken(w, r)
+					default:
+						app.serverError(w, r, err)
+					}
+					return
+				}
+
 				emplooyeId := uuid.MustParse(claims.Subject)
 
-				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				ctx, cancel := detachedContext(r, 5*time.Second)
 				defer cancel()
 
-				emplooyee, err := app.store.GetEmployeeByID(ctx, emplooyeId)
+				// Deleted employees are left out by the query.
+				emplooyee, err := app.store.GetUser(ctx, database.GetUserParams{
+					ID:             emplooyeId,
+					OrganizationID: contextGetOrganization(r).ID,
+				})
 				if err != nil {
-					app.serverError(w, r, err)
+					switch {
+					case errors.Is(err, pgx.ErrNoRows):
+						app.invalidAuthenticationToken(w, r)
+					default:
+						app.serverError(w, r, err)
+					}
+					return
+				}
+
+				// Only active employees hold valid tokens: deactivating,
+				// terminating or deleting an employee revokes every token they
+				// hold, whichever way it was issued.
+				if emplooyee.Status != employeeStatusActive {
+					app.invalidAuthenticationToken(w, r)
 					return
 				}
 
 				r = contextSetAuthenticatedUser(r, &emplooyee)
+				r = contextSetAuthMethods(r, authMethods(claims))
+
+				if _, ok := claims.Set["act"]; ok {
+					impersonation, err := app.checkImpersonation(ctx, claims, emplooyee.OrganizationID)
+					if err != nil {
+						switch {
+						case errors.Is(err, errInvalidImpersonation):
+							app.invalidAuthenticationToken(w, r)
+						default:
+							app.serverError(w, r, err)
+						}
+						return
+					}
+
+					app.auditImpersonation(next).ServeHTTP(w, contextSetImpersonation(r, impersonation))
+					return
+				}
 			}
 		}
 
@@ -100,11 +311,58 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// forbidImpersonation rejects the requests of admins impersonating an employee,
+// for the actions that must be performed by the employee themselves or that
+// would escalate the privileges of the admin, such as changing credentials or
+// granting roles.
+func (app *application) forbidImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contextGetImpersonation(r) != nil {
+			app.impersonationNotPermitted(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// auditImpersonation logs the requests changing anything made while
+// impersonating an employee, along with the admin behind them and their
+// response status.
+func (app *application) auditImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if validator.In(r.Method, http.MethodGet, http.MethodHead, http.MethodOptions) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		mw := response.NewMetricsResponseWriter(w)
+		next.ServeHTTP(mw, r)
+
+		impersonation := contextGetImpersonation(r)
+
+		impersonationAttrs := slog.Group("impersonation",
+			"session", impersonation.SessionID,
+			"actor", impersonation.Actor.ID,
+			"subject", contextGetAuthenticatedUser(r).ID,
+		)
+		requestAttrs := slog.Group("request", "method", r.Method, "url", r.URL.String())
+		responseAttrs := slog.Group("response", "status", mw.StatusCode)
+
+		app.logger.InfoContext(r.Context(), "impersonated request", impersonationAttrs, requestAttrs, responseAttrs)
+	})
+}
+
 func (app *application) requireAuthenticatedUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authenticatedUser := contextGetAuthenticatedUser(r)
 
 		if authenticatedUser == nil {
+			if contextGetServiceAccount(r) != nil {
+				app.serviceAccountNotPermitted(w, r)
+				return
+			}
+
 			app.authenticationRequired(w, r)
 			return
 		}
@@ -112,3 +370,95 @@ func (app *application) requireAuthenticatedUser(next http.Handler) http.Handler
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requireTwoFactor rejects the authenticated users holding a role that
+// requires two-factor authentication when their token was obtained without a
+// second factor, either ours or the one of the OpenID Connect provider. Routes
+// for enabling two-factor authentication must not use it. Service accounts
+// are let through, as API keys have no second factor.
+func (app *application) requireTwoFactor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contextGetServiceAccount(r) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authenticatedUser := contextGetAuthenticatedUser(r)
+
+		if authenticatedUser == nil {
+			app.authenticationRequired(w, r)
+			return
+		}
+
+		methods := contextGetAuthMethods(r)
+		if validator.In(authMethodOTP, methods...) || validator.In(authMethodMFA, methods...) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := detachedContext(r, 5*time.Second)
+		defer cancel()
+
+		required, err := app.store.EmployeeRequiresTwoFactor(ctx, database.EmployeeRequiresTwoFactorParams{
+			UserID:         authenticatedUser.ID,
+			OrganizationID: authenticatedUser.OrganizationID,
+		})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if required {
+			app.twoFactorRequired(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requirePermission only lets through authenticated users holding the given
+// permission. Holders of the admin permission are allowed everywhere. Service
+// accounts are only allowed with the permissions granted to them and to their
+// API key.
+func (app *application) requirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if serviceAccount := contextGetServiceAccount(r); serviceAccount != nil {
+				if !validator.In(permission, serviceAccount.Permissions...) {
+					app.notPermitted(w, r)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			authenticatedUser := contextGetAuthenticatedUser(r)
+
+			if authenticatedUser == nil {
+				app.authenticationRequired(w, r)
+				return
+			}
+
+			ctx, cancel := detachedContext(r, 5*time.Second)
+			defer cancel()
+
+			permissions, err := app.store.GetPermissionsForEmployee(ctx, database.GetPermissionsForEmployeeParams{
+				UserID:         authenticatedUser.ID,
+				OrganizationID: authenticatedUser.OrganizationID,
+			})
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+
+			if !validator.In(permission, permissions...) && !validator.In("admin", permissions...) {
+				app.notPermitted(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
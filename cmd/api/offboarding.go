@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/request"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// offboardingInterval is how often the offboardings that reached their
+	// termination date are looked for.
+	offboardingInterval = time.Minute
+
+	offboardingBatchSize = 100
+)
+
+type offboardingResponse struct {
+	EmployeeID   uuid.UUID  `json:"employeeId"`
+	ManagerID    uuid.UUID  `json:"managerId"`
+	TerminatesAt time.Time  `json:"terminatesAt"`
+	RequestedBy  uuid.UUID  `json:"requestedBy"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	CompletedAt  *time.Time `json:"completedAt,omitempty"`
+}
+
+func newOffboardingResponse(offboarding database.Offboarding) offboardingResponse {
+	res := offboardingResponse{
+		EmployeeID:   offboarding.UserID,
+		ManagerID:    offboarding.ManagerID,
+		TerminatesAt: offboarding.TerminatesAt.Time,
+		RequestedBy:  offboarding.RequestedBy,
+		CreatedAt:    offboarding.CreatedAt.Time,
+	}
+
+	if offboarding.CompletedAt.Valid {
+		res.CompletedAt = &offboarding.CompletedAt.Time
+	}
+
+	return res
+}
+
+// runOffboarding completes the offboardings reaching their termination date,
+// until ctx is cancelled.
+func (app *application) runOffboarding(ctx context.Context) {
+	app.runOrganizationJob(ctx, "offboarding", offboardingInterval, app.offboardOrganization)
+}
+
+// offboardOrganization completes the due offboardings of the organization. An
+// offboarding failing is reported without stopping the others.
+func (app *application) offboardOrganization(ctx context.Context, r *http.Request, organizationID uuid.UUID) error {
+	employeeIDs, err := app.store.GetDueOffboardings(ctx, database.GetDueOffboardingsParams{
+		Now:            pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+		OrganizationID: organizationID,
+		Limit:          offboardingBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, employeeID := range employeeIDs {
+		err := app.offboardEmployee(ctx, r, employeeID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			app.reportServerError(r, err)
+		}
+	}
+
+	return nil
+}
+
+// offboardEmployee terminates the employee, in a single transaction: their
+// impersonation sessions are ended, their password is removed, their roles are
+// revoked and archived, and the webhooks and service accounts they created are
+// reassigned to their manager. Their tokens are rejected from then on, as
+// authenticate refuses terminated employees. The manager is emailed once it is
+// done. An offboarding another instance is completing is skipped. The schema
+// has no leave data yet, so there is no future leave to cancel.
+func (app *application) offboardEmployee(ctx context.Context, r *http.Request, employeeID uuid.UUID) error {
+	var (
+		before, after, manager    database.User
+		roles                     []database.GetRolesForUserRow
+		webhooks, serviceAccounts int64
+		deliveries                []database.WebhookDelivery
+		completed                 bool
+	)
+
+	organizationID := contextGetOrganization(r).ID
+
+	err := app.store.ExecTx(ctx, func(q *database.Queries) error {
+		offboarding, err := q.LockDueOffboarding(ctx, database.LockDueOffboardingParams{
+			UserID:         employeeID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+
+			return err
+		}
+
+		before, err = q.GetUser(ctx, database.GetUserParams{
+			ID:             offboarding.UserID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		manager, err = q.GetUser(ctx, database.GetUserParams{
+			ID:             offboarding.ManagerID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.EndImpersonationSessionsForEmployee(ctx, database.EndImpersonationSessionsForEmployeeParams{
+			ActorID:        before.ID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		roles, err = q.GetRolesForUser(ctx, database.GetRolesForUserParams{
+			UserID:         before.ID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.ArchiveRolesForUser(ctx, database.ArchiveRolesForUserParams{
+			UserID:         before.ID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteRolesForUser(ctx, database.DeleteRolesForUserParams{
+			UserID:         before.ID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		webhooks, err = q.ReassignWebhooks(ctx, database.ReassignWebhooksParams{
+			ToUserID:       manager.ID,
+			FromUserID:     before.ID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		serviceAccounts, err = q.ReassignServiceAccounts(ctx, database.ReassignServiceAccountsParams{
+			ToUserID:       manager.ID,
+			FromUserID:     before.ID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		after = before
+		after.Status = employeeStatusTerminated
+		after.HashedPassword = pgtype.Text{}
+
+		err = q.UpdateUser(ctx, database.UpdateUserParams{
+			ID:             after.ID,
+			Name:           after.Name,
+			Email:          after.Email,
+			HashedPassword: after.HashedPassword,
+			Status:         after.Status,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.CompleteOffboarding(ctx, database.CompleteOffboardingParams{
+			UserID:         after.ID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishEmployeeStatusChange(ctx, q, before, after)
+		if err != nil {
+			return err
+		}
+
+		for _, role := range roles {
+			scheduled, err := publishEvent(ctx, q, organizationID, eventEmployeeRolesChanged, rolesChangedEventData(after.ID, role.ID, false))
+			if err != nil {
+				return err
+			}
+
+			deliveries = append(deliveries, scheduled...)
+		}
+
+		completed = true
+
+		return nil
+	})
+	if err != nil || !completed {
+		return err
+	}
+
+	app.deliverWebhooks(r, deliveries)
+
+	app.logger.InfoContext(ctx, "employee offboarded", slog.Group("offboarding",
+		"employee", after.ID,
+		"manager", manager.ID,
+		"roles", len(roles),
+		"webhooks", webhooks,
+		"serviceAccounts", serviceAccounts,
+	))
+
+	organization := contextGetOrganization(r)
+
+	app.backgroundTask(r, func(ctx context.Context) error {
+		data := app.newEmailData(organization)
+		data["Name"] = manager.Name
+		data["EmployeeName"] = after.Name
+		data["EmployeeEmail"] = after.Email
+		data["Webhooks"] = webhooks
+		data["ServiceAccounts"] = serviceAccounts
+
+		return app.mailer.SendFrom(ctx, organization.EmailSender.String, manager.Email, data, "employee_offboarded.tmpl")
+	})
+
+	return nil
+}
+
+// scheduleOffboardingHandler schedules the termination of the employee, or
+// reschedules it. A termination date in the past terminates them within
+// offboardingInterval.
+func (app *application) scheduleOffboardingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	var input struct {
+		TerminatesAt time.Time           `json:"terminatesAt"`
+		ManagerID    uuid.UUID           `json:"managerId"`
+		Validator    validator.Validator `json:"-"`
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	organizationID := contextGetOrganization(r).ID
+
+	employee, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             id,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	input.Validator.Check(employee.Status != employeeStatusTerminated, "employee_terminated", "The employee is already terminated")
+	input.Validator.CheckField(!input.TerminatesAt.IsZero(), "TerminatesAt", "terminates_at_required", "Termination date is required")
+	input.Validator.CheckField(input.ManagerID != uuid.Nil, "ManagerID", "manager_required", "Manager is required")
+	input.Validator.CheckField(input.ManagerID != employee.ID, "ManagerID", "manager_invalid", "The employee can't be their own manager")
+
+	if input.ManagerID != uuid.Nil && input.ManagerID != employee.ID {
+		manager, err := app.store.GetUser(ctx, database.GetUserParams{
+			ID:             input.ManagerID,
+			OrganizationID: organizationID,
+		})
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		input.Validator.CheckField(err == nil, "ManagerID", "manager_not_found", "Manager could not be found")
+		input.Validator.CheckField(err != nil || manager.Status != employeeStatusTerminated, "ManagerID", "manager_terminated", "The manager is terminated")
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	offboarding, err := app.store.ScheduleOffboarding(ctx, database.ScheduleOffboardingParams{
+		UserID:         employee.ID,
+		ManagerID:      input.ManagerID,
+		TerminatesAt:   pgtype.Timestamp{Time: input.TerminatesAt.UTC(), Valid: true},
+		RequestedBy:    contextGetAuthenticatedUser(r).ID,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		// The offboarding was completed in the meantime.
+		if errors.Is(err, pgx.ErrNoRows) {
+			input.Validator.AddError("employee_terminated", "The employee is already terminated")
+			app.failedValidation(w, r, input.Validator)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.InfoContext(ctx, "offboarding scheduled", slog.Group("offboarding",
+		"employee", offboarding.UserID,
+		"manager", offboarding.ManagerID,
+		"terminatesAt", offboarding.TerminatesAt.Time,
+		"requestedBy", offboarding.RequestedBy,
+	))
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"offboarding": newOffboardingResponse(offboarding)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) showOffboardingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	offboarding, err := app.store.GetOffboarding(ctx, database.GetOffboardingParams{
+		UserID:         id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"offboarding": newOffboardingResponse(offboarding)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// cancelOffboardingHandler cancels an offboarding that isn't completed yet.
+func (app *application) cancelOffboardingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	rows, err := app.store.CancelOffboarding(ctx, database.CancelOffboardingParams{
+		UserID:         id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if rows == 0 {
+		app.notFound(w, r)
+		return
+	}
+
+	app.logger.InfoContext(ctx, "offboarding cancelled", slog.Group("offboarding", "employee", id))
+
+	w.WriteHeader(http.StatusNoContent)
+}
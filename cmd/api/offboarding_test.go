@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// newTestOffboarding schedules the termination of an employee holding a role,
+// an hour ago, and returns the employee and their manager.
+func newTestOffboarding(t *testing.T, app *application, store *testStore) (database.User, database.User) {
+	t.Helper()
+
+	admin := newTestEmployee(store, "Root", "root@example.com")
+	employee := newTestEmployee(store, "Ada Lovelace", "ada@example.com")
+	manager := newTestEmployee(store, "Grace Hopper", "grace@example.com")
+
+	role := database.Role{ID: uuid.New(), DisplayName: "employee", OrganizationID: store.organization.ID}
+	store.roles = append(store.roles, role)
+	store.grants = append(store.grants, database.UsersRole{
+		UserID:         employee.ID,
+		RoleID:         role.ID,
+		Grantor:        admin.ID,
+		OrganizationID: store.organization.ID,
+	})
+
+	store.offboardings[employee.ID] = database.Offboarding{
+		UserID:         employee.ID,
+		ManagerID:      manager.ID,
+		TerminatesAt:   pgtype.Timestamp{Time: time.Now().UTC().Add(-time.Hour), Valid: true},
+		RequestedBy:    admin.ID,
+		OrganizationID: store.organization.ID,
+	}
+
+	return employee, manager
+}
+
+func TestOffboardingCompletesInOneTransaction(t *testing.T) {
+	app, store := newTestApplication(t)
+
+	employee, manager := newTestOffboarding(t, app, store)
+
+	sink := &testSMTPServer{}
+	app.mailer = newTestMailer(t, sink)
+
+	r := newTestRequest(store, "JOB", "/jobs/offboarding", nil)
+
+	err := app.offboardEmployee(context.Background(), r, employee.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.wg.Wait()
+
+	if len(store.transactions) != 1 {
+		t.Fatalf("got %d transactions; want 1", len(store.transactions))
+	}
+
+	steps := []string{
+		"LockDueOffboarding",
+		"EndImpersonationSessionsForEmployee",
+		"ArchiveRolesForUser",
+		"DeleteRolesForUser",
+		"ReassignWebhooks",
+		"ReassignServiceAccounts",
+		"UpdateUser",
+		"CompleteOffboarding",
+		"CreateEvent",
+	}
+
+	for _, step := range steps {
+		if !slices.Contains(store.transactions[0], step) {
+			t.Errorf("%s: not run in the transaction %v", step, store.transactions[0])
+		}
+	}
+
+	terminated := store.employees[employee.ID]
+	if terminated.Status != employeeStatusTerminated || terminated.HashedPassword.Valid {
+		t.Errorf("got status %q and password %t; want %q without a password", terminated.Status, terminated.HashedPassword.Valid, employeeStatusTerminated)
+	}
+
+	if len(store.grants) != 0 || len(store.revokedRoles) != 1 {
+		t.Errorf("got %d grants and %d revoked roles; want 0 and 1", len(store.grants), len(store.revokedRoles))
+	}
+
+	if !store.offboardings[employee.ID].CompletedAt.Valid {
+		t.Error("offboarding not completed")
+	}
+
+	var types []string
+	for _, evt := range store.events {
+		types = append(types, evt.Type)
+	}
+
+	if !slices.Equal(types, []string{eventEmployeeTerminated, eventEmployeeRolesChanged}) {
+		t.Errorf("got events %v; want %s and %s", types, eventEmployeeTerminated, eventEmployeeRolesChanged)
+	}
+
+	if recipients := sink.Recipients(); !slices.Equal(recipients, []string{manager.Email}) {
+		t.Errorf("got emails to %v; want one to the manager", recipients)
+	}
+}
+
+func TestOffboardingSkipsLockedOffboardings(t *testing.T) {
+	app, store := newTestApplication(t)
+
+	employee, _ := newTestOffboarding(t, app, store)
+
+	// Another instance is completing the offboarding.
+	store.locked[employee.ID] = true
+
+	r := newTestRequest(store, "JOB", "/jobs/offboarding", nil)
+
+	err := app.offboardEmployee(context.Background(), r, employee.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.wg.Wait()
+
+	if len(store.transactions) != 1 || !slices.Equal(store.transactions[0], []string{"LockDueOffboarding"}) {
+		t.Errorf("got queries %v; want the lock only", store.transactions)
+	}
+
+	if status := store.employees[employee.ID].Status; status != employeeStatusActive {
+		t.Errorf("got status %q; want %q", status, employeeStatusActive)
+	}
+
+	if len(store.grants) != 1 || len(store.events) != 0 {
+		t.Errorf("got %d grants and %d events; want the grant kept and no event", len(store.grants), len(store.events))
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brGuirra/uai/internal/apikey"
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/request"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/smtp"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// organizationClaim is the claim of the authentication tokens holding the ID
+// of the organization they were issued for.
+const organizationClaim = "org"
+
+var errUnknownOrganization = errors.New("unknown organization")
+
+var rgxBrandColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type organizationResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Slug        string    `json:"slug"`
+	Name        string    `json:"name"`
+	EmailSender string    `json:"emailSender,omitempty"`
+	BrandName   string    `json:"brandName,omitempty"`
+	BrandColor  string    `json:"brandColor,omitempty"`
+	LogoURL     string    `json:"logoUrl,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	// SCIMTokenID identifies the SCIM token of the organization, when it has
+	// one, without disclosing it.
+	SCIMTokenID        string     `json:"scimTokenId,omitempty"`
+	SCIMTokenCreatedAt *time.Time `json:"scimTokenCreatedAt,omitempty"`
+}
+
+func newOrganizationResponse(organization database.Organization) organizationResponse {
+	res := organizationResponse{
+		ID:          organization.ID,
+		Slug:        organization.Slug,
+		Name:        organization.Name,
+		EmailSender: organization.EmailSender.String,
+		BrandName:   organization.BrandName.String,
+		BrandColor:  organization.BrandColor.String,
+		LogoURL:     organization.LogoUrl.String,
+		CreatedAt:   organization.CreatedAt.Time,
+		SCIMTokenID: organization.ScimTokenID.String,
+	}
+
+	if organization.ScimTokenCreatedAt.Valid {
+		res.SCIMTokenCreatedAt = &organization.ScimTokenCreatedAt.Time
+	}
+
+	return res
+}
+
+// organizationSlug returns the slug of the organization named by the subdomain
+// of the request host, under the tenant domain. The host of requests on the
+// tenant domain itself names no organization.
+func (app *application) organizationSlug(r *http.Request) (string, bool) {
+	if app.config.tenancy.domain == "" {
+		return "", false
+	}
+
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+
+	slug, found := strings.CutSuffix(strings.ToLower(host), "."+app.config.tenancy.domain)
+	if !found || slug == "" || strings.Contains(slug, ".") {
+		return "", false
+	}
+
+	return slug, true
+}
+
+// tokenOrganization returns the organization an authentication token was
+// issued for, or errUnknownOrganization when it doesn't exist anymore. Tokens
+// without the claim, issued before organizations existed, are for the default
+// organization.
+func (app *application) tokenOrganization(r *http.Request, claims map[string]any) (*database.Organization, error) {
+	value, ok := claims[organizationClaim].(string)
+	if !ok {
+		return app.defaultOrganization(r)
+	}
+
+	organizationID, err := uuid.Parse(value)
+	if err != nil {
+		return nil, errUnknownOrganization
+	}
+
+	if organizationID == contextGetOrganization(r).ID {
+		return contextGetOrganization(r), nil
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	organization, err := app.store.GetOrganization(ctx, organizationID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errUnknownOrganization
+		}
+
+		return nil, err
+	}
+
+	return &organization, nil
+}
+
+// contextSetTokenOrganization switches the request to the organization a token
+// was issued for. A token is only valid for its organization, so a request
+// naming another one by its host fails with errUnknownOrganization.
+func (app *application) contextSetTokenOrganization(r *http.Request, claims map[string]any) (*http.Request, error) {
+	organization, err := app.tokenOrganization(r, claims)
+	if err != nil {
+		return r, err
+	}
+
+	if organization.ID == contextGetOrganization(r).ID {
+		return r, nil
+	}
+
+	if _, ok := app.organizationSlug(r); ok {
+		return r, errUnknownOrganization
+	}
+
+	return contextSetOrganization(r, organization), nil
+}
+
+func (app *application) defaultOrganization(r *http.Request) (*database.Organization, error) {
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	organization, err := app.store.GetOrganizationBySlug(ctx, app.config.tenancy.defaultOrganization)
+	if err != nil {
+		return nil, err
+	}
+
+	return &organization, nil
+}
+
+func (app *application) showOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	err := response.JSON(w, http.StatusOK, map[string]any{"organization": newOrganizationResponse(*contextGetOrganization(r))})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// updateOrganizationHandler changes the name of the organization and the
+// sender and branding of the emails sent to its employees. An empty sender or
+// branding setting falls back to the default one.
+func (app *application) updateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	organization := *contextGetOrganization(r)
+
+	var input struct {
+		Name        *string             `json:"name"`
+		EmailSender *string             `json:"emailSender"`
+		BrandName   *string             `json:"brandName"`
+		BrandColor  *string             `json:"brandColor"`
+		LogoURL     *string             `json:"logoUrl"`
+		Validator   validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		organization.Name = *input.Name
+	}
+
+	setting := func(value *string, current pgtype.Text) pgtype.Text {
+		if value == nil {
+			return current
+		}
+
+		return pgtype.Text{String: *value, Valid: *value != ""}
+	}
+
+	organization.EmailSender = setting(input.EmailSender, organization.EmailSender)
+	organization.BrandName = setting(input.BrandName, organization.BrandName)
+	organization.BrandColor = setting(input.BrandColor, organization.BrandColor)
+	organization.LogoUrl = setting(input.LogoURL, organization.LogoUrl)
+
+	input.Validator.CheckField(validator.NotBlank(organization.Name), "Name", "name_required", "Name is required")
+	input.Validator.CheckField(validator.MaxRunes(organization.Name, 100), "Name", "name_too_long", "Name must not be more than 100 characters long")
+	if organization.EmailSender.Valid {
+		_, err := mail.ParseAddress(organization.EmailSender.String)
+		input.Validator.CheckField(err == nil, "EmailSender", "email_sender_invalid", "Must be a valid email address")
+
+		if err == nil {
+			err = app.mailer.CheckSender(organization.EmailSender.String)
+			input.Validator.CheckField(!errors.Is(err, smtp.ErrUnalignedSender), "EmailSender", "email_sender_unaligned", "Must be on the domain the emails are signed for")
+		}
+	}
+
+	input.Validator.CheckField(validator.MaxRunes(organization.BrandName.String, 100), "BrandName", "brand_name_too_long", "Brand name must not be more than 100 characters long")
+	input.Validator.CheckField(!organization.BrandColor.Valid || validator.Matches(organization.BrandColor.String, rgxBrandColor), "BrandColor", "brand_color_invalid", "Must be a hexadecimal color, such as #1a2b3c")
+	input.Validator.CheckField(!organization.LogoUrl.Valid || validator.IsURL(organization.LogoUrl.String), "LogoURL", "logo_url_invalid", "Must be a valid URL")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	organization, err = app.store.UpdateOrganization(ctx, database.UpdateOrganizationParams{
+		Name:        organization.Name,
+		EmailSender: organization.EmailSender,
+		BrandName:   organization.BrandName,
+		BrandColor:  organization.BrandColor,
+		LogoUrl:     organization.LogoUrl,
+		ID:          organization.ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.InfoContext(ctx, "organization updated", slog.Group("organization", "id", organization.ID, "slug", organization.Slug))
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"organization": newOrganizationResponse(organization)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// createSCIMTokenHandler generates the SCIM token of the organization,
+// replacing the previous one, which stops working right away.
+func (app *application) createSCIMTokenHandler(w http.ResponseWriter, r *http.Request) {
+	generated, err := apikey.New()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	organization, err := app.store.SetOrganizationSCIMToken(ctx, database.SetOrganizationSCIMTokenParams{
+		ScimTokenID:     pgtype.Text{String: generated.ID, Valid: true},
+		ScimHashedToken: pgtype.Text{String: generated.Hash, Valid: true},
+		ID:              contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.InfoContext(ctx, "scim token created", slog.Group("organization", "id", organization.ID, "slug", organization.Slug))
+
+	// The token is only disclosed once, when it is created.
+	err = response.JSON(w, http.StatusCreated, map[string]any{
+		"organization": newOrganizationResponse(organization),
+		"scimToken":    generated.Key,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// deleteSCIMTokenHandler deletes the SCIM token of the organization, which
+// disables its SCIM endpoints.
+func (app *application) deleteSCIMTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if !contextGetOrganization(r).ScimTokenID.Valid {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	organization, err := app.store.DeleteOrganizationSCIMToken(ctx, contextGetOrganization(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.InfoContext(ctx, "scim token deleted", slog.Group("organization", "id", organization.ID, "slug", organization.Slug))
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// erasedEmployeeName replaces the name of the employees whose personal data
+// was erased.
+const erasedEmployeeName = "Erased employee"
+
+// personalDataFile is a JSON file of a personal data export.
+type personalDataFile struct {
+	Name string
+	Data any
+}
+
+type personalDataProfile struct {
+	ID          uuid.UUID                `json:"id"`
+	Name        string                   `json:"name"`
+	Email       string                   `json:"email"`
+	Status      string                   `json:"status"`
+	Profile     json.RawMessage          `json:"profile"`
+	LDAPDN      string                   `json:"ldapDn,omitempty"`
+	TwoFactor   personalDataTwoFactor    `json:"twoFactor"`
+	Lockout     *personalDataLockout     `json:"lockout,omitempty"`
+	Offboarding *offboardingResponse     `json:"offboarding,omitempty"`
+	Erasure     *personalDataErasureInfo `json:"erasure,omitempty"`
+}
+
+type personalDataTwoFactor struct {
+	Enabled             bool       `json:"enabled"`
+	EnabledAt           *time.Time `json:"enabledAt,omitempty"`
+	UnusedRecoveryCodes int64      `json:"unusedRecoveryCodes"`
+}
+
+type personalDataLockout struct {
+	FailedAttempts int32      `json:"failedAttempts"`
+	Lockouts       int32      `json:"lockouts"`
+	LockedUntil    *time.Time `json:"lockedUntil,omitempty"`
+}
+
+type personalDataErasureInfo struct {
+	ErasedBy uuid.UUID `json:"erasedBy"`
+	ErasedAt time.Time `json:"erasedAt"`
+}
+
+type personalDataRole struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Grantor   uuid.UUID  `json:"grantor"`
+	GrantedAt time.Time  `json:"grantedAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+type personalDataGrant struct {
+	EmployeeID uuid.UUID `json:"employeeId"`
+	RoleID     uuid.UUID `json:"roleId"`
+	GrantedAt  time.Time `json:"grantedAt"`
+}
+
+type personalDataLogin struct {
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"userAgent"`
+	FirstSeenAt time.Time `json:"firstSeenAt"`
+	LastSeenAt  time.Time `json:"lastSeenAt"`
+}
+
+type personalDataResource struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// collectPersonalData returns everything stored about the employee, as the
+// files of their export. Secrets, such as their password hash, TOTP secret
+// and recovery codes, are left out.
+func (app *application) collectPersonalData(ctx context.Context, employee database.User) ([]personalDataFile, error) {
+	profile := personalDataProfile{
+		ID:      employee.ID,
+		Name:    employee.Name,
+		Email:   employee.Email,
+		Status:  employee.Status,
+		Profile: employee.Profile,
+	}
+
+	account, err := app.store.GetLDAPAccount(ctx, database.GetLDAPAccountParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	switch {
+	case err == nil:
+		profile.LDAPDN = account.Dn
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	secret, err := app.store.GetTOTPSecret(ctx, database.GetTOTPSecretParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	switch {
+	case err == nil:
+		profile.TwoFactor.Enabled = secret.ConfirmedAt.Valid
+		if secret.ConfirmedAt.Valid {
+			profile.TwoFactor.EnabledAt = &secret.ConfirmedAt.Time
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	profile.TwoFactor.UnusedRecoveryCodes, err = app.store.CountUnusedRecoveryCodes(ctx, database.CountUnusedRecoveryCodesParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lockout, err := app.store.GetAccountLockout(ctx, database.GetAccountLockoutParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	switch {
+	case err == nil:
+		profile.Lockout = &personalDataLockout{FailedAttempts: lockout.FailedAttempts, Lockouts: lockout.Lockouts}
+		if lockout.LockedUntil.Valid {
+			profile.Lockout.LockedUntil = &lockout.LockedUntil.Time
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	offboarding, err := app.store.GetOffboarding(ctx, database.GetOffboardingParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	switch {
+	case err == nil:
+		res := newOffboardingResponse(offboarding)
+		profile.Offboarding = &res
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	erasure, err := app.store.GetErasure(ctx, database.GetErasureParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	switch {
+	case err == nil:
+		profile.Erasure = &personalDataErasureInfo{ErasedBy: erasure.ErasedBy, ErasedAt: erasure.ErasedAt.Time}
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	grants, err := app.store.GetRoleGrantsForUser(ctx, database.GetRoleGrantsForUserParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revokedGrants, err := app.store.GetRevokedRolesForUser(ctx, database.GetRevokedRolesForUserParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	grantedByEmployee, err := app.store.GetRoleGrantsByGrantor(ctx, database.GetRoleGrantsByGrantorParams{
+		Grantor:        employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	roles := []personalDataRole{}
+	for _, grant := range grants {
+		roles = append(roles, personalDataRole{
+			ID:        grant.ID,
+			Name:      grant.DisplayName,
+			Grantor:   grant.Grantor,
+			GrantedAt: grant.GrantedAt.Time,
+		})
+	}
+
+	revoked := []personalDataRole{}
+	for _, grant := range revokedGrants {
+		revoked = append(revoked, personalDataRole{
+			ID:        grant.ID,
+			Name:      grant.DisplayName,
+			Grantor:   grant.Grantor,
+			GrantedAt: grant.GrantedAt.Time,
+			RevokedAt: &grant.RevokedAt.Time,
+		})
+	}
+
+	granted := []personalDataGrant{}
+	for _, grant := range grantedByEmployee {
+		granted = append(granted, personalDataGrant{
+			EmployeeID: grant.UserID,
+			RoleID:     grant.RoleID,
+			GrantedAt:  grant.GrantedAt.Time,
+		})
+	}
+
+	knownLogins, err := app.store.GetKnownLoginsForUser(ctx, database.GetKnownLoginsForUserParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logins := []personalDataLogin{}
+	for _, login := range knownLogins {
+		logins = append(logins, personalDataLogin{
+			IP:          login.Ip,
+			UserAgent:   login.UserAgent,
+			FirstSeenAt: login.FirstSeenAt.Time,
+			LastSeenAt:  login.LastSeenAt.Time,
+		})
+	}
+
+	impersonationSessions, err := app.store.GetImpersonationSessionsForEmployee(ctx, database.GetImpersonationSessionsForEmployeeParams{
+		ActorID:        employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []impersonationSessionResponse{}
+	for _, session := range impersonationSessions {
+		sessions = append(sessions, newImpersonationSessionResponse(session))
+	}
+
+	employeeEvents, err := app.store.GetEventsForEmployee(ctx, database.GetEventsForEmployeeParams{
+		EmployeeID:     employee.ID.String(),
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := []event{}
+	for _, evt := range employeeEvents {
+		events = append(events, newEvent(evt))
+	}
+
+	webhooks, err := app.store.GetWebhooksCreatedBy(ctx, database.GetWebhooksCreatedByParams{
+		CreatedBy:      employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	createdWebhooks := []personalDataResource{}
+	for _, hook := range webhooks {
+		createdWebhooks = append(createdWebhooks, personalDataResource{ID: hook.ID, Name: hook.Url, CreatedAt: hook.CreatedAt.Time})
+	}
+
+	serviceAccounts, err := app.store.GetServiceAccountsCreatedBy(ctx, database.GetServiceAccountsCreatedByParams{
+		CreatedBy:      employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	createdServiceAccounts := []personalDataResource{}
+	for _, account := range serviceAccounts {
+		createdServiceAccounts = append(createdServiceAccounts, personalDataResource{ID: account.ID, Name: account.Name, CreatedAt: account.CreatedAt.Time})
+	}
+
+	return []personalDataFile{
+		{Name: "profile.json", Data: profile},
+		{Name: "roles.json", Data: map[string]any{"current": roles, "revoked": revoked, "grantedToOthers": granted}},
+		{Name: "logins.json", Data: map[string]any{"knownLogins": logins}},
+		{Name: "audit.json", Data: map[string]any{"impersonationSessions": sessions, "events": events}},
+		{Name: "resources.json", Data: map[string]any{"webhooks": createdWebhooks, "serviceAccounts": createdServiceAccounts}},
+	}, nil
+}
+
+// exportPersonalDataHandler responds with a ZIP archive of JSON files holding
+// everything stored about the employee, to answer the data subject access
+// requests of the LGPD and GDPR.
+func (app *application) exportPersonalDataHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 30*time.Second)
+	defer cancel()
+
+	employee, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	files, err := app.collectPersonalData(ctx, employee)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The archive is built in memory first, so a failure still gets a proper
+	// error response.
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+
+	for _, file := range files {
+		f, err := zw.Create(file.Name)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "\t")
+
+		err = enc.Encode(file.Data)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	err = zw.Close()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.InfoContext(ctx, "personal data exported", slog.Group("personalData",
+		"employee", employee.ID,
+		"requestedBy", contextGetAuthenticatedUser(r).ID,
+	))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="personal-data-%s.zip"`, employee.ID))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// eraseEmployeeHandler erases the personal data of a terminated employee, to
+// answer the erasure requests of the LGPD and GDPR. The employee is kept and
+// pseudonymized rather than deleted, so the records that must be retained and
+// reference them, such as the roles they granted, stay valid: their name and
+// email address are replaced, in the events about them too, and their profile,
+// sign-ins, lockout, two-factor secrets and directory link are deleted.
+func (app *application) eraseEmployeeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 30*time.Second)
+	defer cancel()
+
+	employee, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	_, err = app.store.GetErasure(ctx, database.GetErasureParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var v validator.Validator
+
+	v.Check(employee.Status == employeeStatusTerminated, "employee_not_terminated", "Only terminated employees can be erased, offboard them first")
+	v.Check(err != nil, "employee_erased", "The personal data of the employee was already erased")
+
+	if v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	email := fmt.Sprintf("erased-%s@erased.invalid", employee.ID)
+
+	var erasure database.Erasure
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		err := q.UpdateUser(ctx, database.UpdateUserParams{
+			ID:             employee.ID,
+			Name:           erasedEmployeeName,
+			Email:          email,
+			HashedPassword: pgtype.Text{},
+			Status:         employee.Status,
+			OrganizationID: employee.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.UpdateUserProfile(ctx, database.UpdateUserProfileParams{
+			ID:             employee.ID,
+			Profile:        []byte("{}"),
+			OrganizationID: employee.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteKnownLoginsForUser(ctx, database.DeleteKnownLoginsForUserParams{
+			UserID:         employee.ID,
+			OrganizationID: employee.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.DeleteAccountLockout(ctx, database.DeleteAccountLockoutParams{
+			UserID:         employee.ID,
+			OrganizationID: employee.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.DeleteTOTPSecret(ctx, database.DeleteTOTPSecretParams{
+			UserID:         employee.ID,
+			OrganizationID: employee.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteRecoveryCodes(ctx, database.DeleteRecoveryCodesParams{
+			UserID:         employee.ID,
+			OrganizationID: employee.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteLDAPAccount(ctx, database.DeleteLDAPAccountParams{
+			UserID:         employee.ID,
+			OrganizationID: employee.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.PseudonymizeEvents(ctx, database.PseudonymizeEventsParams{
+			Name:           erasedEmployeeName,
+			Email:          email,
+			EmployeeID:     employee.ID.String(),
+			OrganizationID: employee.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.PseudonymizeWebhookDeliveries(ctx, database.PseudonymizeWebhookDeliveriesParams{
+			Name:           erasedEmployeeName,
+			Email:          email,
+			EmployeeID:     employee.ID.String(),
+			OrganizationID: employee.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		erasure, err = q.CreateErasure(ctx, database.CreateErasureParams{
+			UserID:         employee.ID,
+			ErasedBy:       contextGetAuthenticatedUser(r).ID,
+			OrganizationID: employee.OrganizationID,
+		})
+		return err
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.InfoContext(ctx, "personal data erased", slog.Group("personalData",
+		"employee", erasure.UserID,
+		"requestedBy", erasure.ErasedBy,
+	))
+
+	data := map[string]any{
+		"employeeId": erasure.UserID,
+		"erasedBy":   erasure.ErasedBy,
+		"erasedAt":   erasure.ErasedAt.Time,
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"erasure": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
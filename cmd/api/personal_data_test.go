@@ -0,0 +1,209 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// newTestDataSubject adds an employee holding a role, with a revoked role and a
+// role they granted to a colleague, and an event about them. It returns the
+// employee, the colleague and a token of the admin.
+func newTestDataSubject(t *testing.T, app *application, store *testStore) (database.User, database.User, string) {
+	t.Helper()
+
+	admin := newTestEmployee(store, "Root", "root@example.com")
+	store.admin = admin.ID
+
+	employee := newTestEmployee(store, "Ada Lovelace", "ada@example.com")
+	colleague := newTestEmployee(store, "Alan Turing", "alan@example.com")
+
+	engineer := database.Role{ID: uuid.New(), DisplayName: "engineer", OrganizationID: store.organization.ID}
+	contractor := database.Role{ID: uuid.New(), DisplayName: "contractor", OrganizationID: store.organization.ID}
+	store.roles = append(store.roles, engineer, contractor)
+
+	grantedAt := pgtype.Timestamp{Time: time.Now().UTC().Add(-time.Hour), Valid: true}
+
+	store.grants = append(store.grants,
+		database.UsersRole{UserID: employee.ID, RoleID: engineer.ID, Grantor: admin.ID, GrantedAt: grantedAt, OrganizationID: store.organization.ID},
+		database.UsersRole{UserID: colleague.ID, RoleID: engineer.ID, Grantor: employee.ID, GrantedAt: grantedAt, OrganizationID: store.organization.ID},
+	)
+	store.revokedRoles = append(store.revokedRoles,
+		database.UsersRole{UserID: employee.ID, RoleID: contractor.ID, Grantor: admin.ID, GrantedAt: grantedAt, OrganizationID: store.organization.ID},
+	)
+
+	for _, subject := range []database.User{employee, colleague} {
+		data, err := json.Marshal(employeeEventData(subject))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store.events = append(store.events, database.Event{
+			ID:             uuid.New(),
+			Sequence:       int64(len(store.events) + 1),
+			Type:           eventEmployeeCreated,
+			Data:           data,
+			CreatedAt:      grantedAt,
+			OrganizationID: store.organization.ID,
+		})
+	}
+
+	return employee, colleague, issueTestToken(t, app, store, admin)
+}
+
+func TestExportPersonalData(t *testing.T) {
+	app, store := newTestApplication(t)
+
+	employee, colleague, token := newTestDataSubject(t, app, store)
+
+	rr := serveTestRequest(app, http.MethodGet, "/api/v1/employees/"+employee.ID.String()+"/personal-data", token, nil)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d and body %s; want %d", rr.Code, rr.Body, http.StatusOK)
+	}
+
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/zip" {
+		t.Errorf("got content type %q; want application/zip", contentType)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	decode := func(name string, dst any) {
+		t.Helper()
+
+		f, ok := files[name]
+		if !ok {
+			t.Fatalf("%s missing from the export", name)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+
+		err = json.NewDecoder(rc).Decode(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var profile personalDataProfile
+	decode("profile.json", &profile)
+
+	if profile.ID != employee.ID || profile.Email != employee.Email || profile.Name != employee.Name {
+		t.Errorf("got profile %+v; want the one of %s", profile, employee.Email)
+	}
+
+	var roles struct {
+		Current         []personalDataRole  `json:"current"`
+		Revoked         []personalDataRole  `json:"revoked"`
+		GrantedToOthers []personalDataGrant `json:"grantedToOthers"`
+	}
+	decode("roles.json", &roles)
+
+	if len(roles.Current) != 1 || roles.Current[0].Name != "engineer" || roles.Current[0].Grantor != store.admin {
+		t.Errorf("got current roles %+v; want engineer, granted by the admin", roles.Current)
+	}
+
+	if len(roles.Revoked) != 1 || roles.Revoked[0].Name != "contractor" || roles.Revoked[0].RevokedAt == nil {
+		t.Errorf("got revoked roles %+v; want contractor", roles.Revoked)
+	}
+
+	if len(roles.GrantedToOthers) != 1 || roles.GrantedToOthers[0].EmployeeID != colleague.ID {
+		t.Errorf("got granted roles %+v; want the one of %s", roles.GrantedToOthers, colleague.Email)
+	}
+
+	var audit struct {
+		ImpersonationSessions []json.RawMessage `json:"impersonationSessions"`
+		Events                []struct {
+			Type string         `json:"type"`
+			Data map[string]any `json:"data"`
+		} `json:"events"`
+	}
+	decode("audit.json", &audit)
+
+	if len(audit.Events) != 1 || audit.Events[0].Type != eventEmployeeCreated || audit.Events[0].Data["email"] != employee.Email {
+		t.Errorf("got events %+v; want the %s event about the employee only", audit.Events, eventEmployeeCreated)
+	}
+
+	for _, name := range []string{"logins.json", "resources.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("%s missing from the export", name)
+		}
+	}
+}
+
+func TestErasePersonalDataKeepsGrantors(t *testing.T) {
+	app, store := newTestApplication(t)
+
+	employee, colleague, token := newTestDataSubject(t, app, store)
+	store.ldapAccounts[employee.ID] = "cn=Ada Lovelace,ou=People,dc=example,dc=com"
+
+	employee.Status = employeeStatusTerminated
+	employee.Profile = []byte(`{"phone":"+55 11 5555-0100"}`)
+	store.employees[employee.ID] = employee
+
+	rr := serveTestRequest(app, http.MethodPost, "/api/v1/employees/"+employee.ID.String()+"/erasure", token, nil)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d and body %s; want %d", rr.Code, rr.Body, http.StatusOK)
+	}
+
+	erased, ok := store.employees[employee.ID]
+	if !ok {
+		t.Fatal("the employee was deleted; want them pseudonymized")
+	}
+
+	if erased.Name != erasedEmployeeName || !strings.HasSuffix(erased.Email, "@erased.invalid") || erased.HashedPassword.Valid || string(erased.Profile) != "{}" {
+		t.Errorf("got %q <%s> with profile %s; want the personal data erased", erased.Name, erased.Email, erased.Profile)
+	}
+
+	if _, ok := store.ldapAccounts[employee.ID]; ok {
+		t.Error("the directory link was kept")
+	}
+
+	// The role the employee granted still names them as its grantor.
+	i := slices.IndexFunc(store.grants, func(grant database.UsersRole) bool { return grant.UserID == colleague.ID })
+	if i < 0 || store.grants[i].Grantor != employee.ID {
+		t.Errorf("got grants %+v; want the grant of %s kept, by the erased employee", store.grants, colleague.Email)
+	}
+
+	for _, evt := range store.events {
+		if bytes.Contains(evt.Data, []byte(employee.Email)) {
+			t.Errorf("event %s still holds %s", evt.Data, employee.Email)
+		}
+	}
+
+	if !bytes.Contains(store.events[1].Data, []byte(colleague.Email)) {
+		t.Errorf("got event %s; want the colleague left alone", store.events[1].Data)
+	}
+
+	if len(store.erasures) != 1 || store.erasures[0].ErasedBy != store.admin {
+		t.Errorf("got erasures %+v; want one by the admin", store.erasures)
+	}
+
+	// Erasing twice is refused.
+	rr = serveTestRequest(app, http.MethodPost, "/api/v1/employees/"+employee.ID.String()+"/erasure", token, nil)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("erasing again: got status %d; want %d", rr.Code, http.StatusUnprocessableEntity)
+	}
+}
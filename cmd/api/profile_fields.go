@@ -0,0 +1,593 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/request"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Types of the profile fields. Text and date values are stored as JSON
+// strings, dates formatted as YYYY-MM-DD.
+const (
+	profileFieldTypeText    = "text"
+	profileFieldTypeNumber  = "number"
+	profileFieldTypeBoolean = "boolean"
+	profileFieldTypeDate    = "date"
+)
+
+var profileFieldTypes = []string{
+	profileFieldTypeText,
+	profileFieldTypeNumber,
+	profileFieldTypeBoolean,
+	profileFieldTypeDate,
+}
+
+// profileFilterPrefix prefixes the query parameters filtering the list of
+// employees by the value of a profile field, as in profile.cost_center=42.
+const profileFilterPrefix = "profile."
+
+var rgxProfileFieldKey = regexp.MustCompile("^[a-z][a-z0-9_]{0,62}$")
+
+type profileFieldResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Key        string    `json:"key"`
+	Label      string    `json:"label"`
+	Type       string    `json:"type"`
+	Required   bool      `json:"required"`
+	Pattern    string    `json:"pattern,omitempty"`
+	Permission string    `json:"permission,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func newProfileFieldResponse(field database.ProfileField) profileFieldResponse {
+	return profileFieldResponse{
+		ID:         field.ID,
+		Key:        field.Key,
+		Label:      field.Label,
+		Type:       field.Type,
+		Required:   field.Required,
+		Pattern:    field.Pattern.String,
+		Permission: field.Permission.String,
+		CreatedAt:  field.CreatedAt.Time,
+	}
+}
+
+func (app *application) listProfileFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	fields, err := app.store.GetProfileFields(ctx, contextGetOrganization(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := []profileFieldResponse{}
+	for _, field := range fields {
+		data = append(data, newProfileFieldResponse(field))
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"profileFields": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createProfileFieldHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Key        string              `json:"key"`
+		Label      string              `json:"label"`
+		Type       string              `json:"type"`
+		Required   bool                `json:"required"`
+		Pattern    string              `json:"pattern"`
+		Permission string              `json:"permission"`
+		Validator  validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Validator.CheckField(validator.Matches(input.Key, rgxProfileFieldKey), "Key", "key_invalid", "Must start with a lowercase letter, followed by up to 62 lowercase letters, digits or underscores")
+	input.Validator.CheckField(validator.In(input.Type, profileFieldTypes...), "Type", "type_invalid", "Invalid type, must be 'text', 'number', 'boolean' or 'date'")
+
+	err = app.checkProfileField(r, &input.Validator, input.Type, input.Label, input.Pattern, input.Permission)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	field, err := app.store.CreateProfileField(ctx, database.CreateProfileFieldParams{
+		Key:            input.Key,
+		Label:          input.Label,
+		Type:           input.Type,
+		Required:       input.Required,
+		Pattern:        pgtype.Text{String: input.Pattern, Valid: input.Pattern != ""},
+		Permission:     pgtype.Text{String: input.Permission, Valid: input.Permission != ""},
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			input.Validator.AddFieldError("Key", "key_taken", "Key is already in use")
+			app.failedValidation(w, r, input.Validator)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusCreated, map[string]any{"profileField": newProfileFieldResponse(field)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// updateProfileFieldHandler updates the label, requirement, pattern and
+// permission of a profile field. Its key and type can't change, as the values
+// already stored depend on them. A new requirement or pattern applies to the
+// profiles updated from then on.
+func (app *application) updateProfileFieldHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	field, err := app.store.GetProfileField(ctx, database.GetProfileFieldParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Label      *string             `json:"label"`
+		Required   *bool               `json:"required"`
+		Pattern    *string             `json:"pattern"`
+		Permission *string             `json:"permission"`
+		Validator  validator.Validator `json:"-"`
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if input.Label != nil {
+		field.Label = *input.Label
+	}
+
+	if input.Required != nil {
+		field.Required = *input.Required
+	}
+
+	if input.Pattern != nil {
+		field.Pattern = pgtype.Text{String: *input.Pattern, Valid: *input.Pattern != ""}
+	}
+
+	if input.Permission != nil {
+		field.Permission = pgtype.Text{String: *input.Permission, Valid: *input.Permission != ""}
+	}
+
+	err = app.checkProfileField(r, &input.Validator, field.Type, field.Label, field.Pattern.String, field.Permission.String)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	field, err = app.store.UpdateProfileField(ctx, database.UpdateProfileFieldParams{
+		ID:             field.ID,
+		Label:          field.Label,
+		Required:       field.Required,
+		Pattern:        field.Pattern,
+		Permission:     field.Permission,
+		OrganizationID: field.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"profileField": newProfileFieldResponse(field)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// deleteProfileFieldHandler deletes a profile field along with its values in
+// the profiles of the employees.
+func (app *application) deleteProfileFieldHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 30*time.Second)
+	defer cancel()
+
+	organizationID := contextGetOrganization(r).ID
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		field, err := q.GetProfileField(ctx, database.GetProfileFieldParams{
+			ID:             id,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.DeleteProfileField(ctx, database.DeleteProfileFieldParams{
+			ID:             field.ID,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.RemoveProfileFieldValues(ctx, database.RemoveProfileFieldValuesParams{
+			Key:            field.Key,
+			OrganizationID: organizationID,
+		})
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkProfileField validates the definition of a profile field. A pattern
+// only applies to text fields, and the permission hiding the field must exist.
+func (app *application) checkProfileField(r *http.Request, v *validator.Validator, fieldType, label, pattern, permission string) error {
+	v.CheckField(validator.NotBlank(label), "Label", "label_required", "Label is required")
+
+	if pattern != "" {
+		_, err := regexp.Compile(pattern)
+		v.CheckField(err == nil, "Pattern", "pattern_invalid", "Must be a valid regular expression")
+		v.CheckField(fieldType == profileFieldTypeText, "Pattern", "pattern_not_allowed", "Only text fields can have a pattern")
+	}
+
+	if permission == "" {
+		return nil
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	existing, err := app.store.GetPermissions(ctx)
+	if err != nil {
+		return err
+	}
+
+	v.CheckField(validator.In(permission, existing...), "Permission", "permission_invalid", "Invalid permission")
+
+	return nil
+}
+
+// callerPermissions returns the permissions of the employee or service account
+// making the request.
+func (app *application) callerPermissions(ctx context.Context, r *http.Request) ([]string, error) {
+	if serviceAccount := contextGetServiceAccount(r); serviceAccount != nil {
+		return serviceAccount.Permissions, nil
+	}
+
+	authenticatedUser := contextGetAuthenticatedUser(r)
+
+	return app.store.GetPermissionsForEmployee(ctx, database.GetPermissionsForEmployeeParams{
+		UserID:         authenticatedUser.ID,
+		OrganizationID: authenticatedUser.OrganizationID,
+	})
+}
+
+// visibleProfileFields returns the profile fields of the organization the
+// employee or service account making the request may see and edit: the ones
+// without a permission, and the ones with a permission they hold. Admins see
+// every field.
+func (app *application) visibleProfileFields(ctx context.Context, r *http.Request) ([]database.ProfileField, error) {
+	fields, err := app.store.GetProfileFields(ctx, contextGetOrganization(r).ID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := app.callerPermissions(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := []database.ProfileField{}
+	for _, field := range fields {
+		if !field.Permission.Valid || validator.In(field.Permission.String, permissions...) || validator.In("admin", permissions...) {
+			visible = append(visible, field)
+		}
+	}
+
+	return visible, nil
+}
+
+// visibleProfile returns the values of the profile of the employee for the
+// given fields, leaving out the others.
+func visibleProfile(profile []byte, fields []database.ProfileField) (map[string]any, error) {
+	var values map[string]any
+
+	err := json.Unmarshal(profile, &values)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := map[string]any{}
+	for _, field := range fields {
+		if value, ok := values[field.Key]; ok {
+			visible[field.Key] = value
+		}
+	}
+
+	return visible, nil
+}
+
+// parseProfileValue decodes the JSON value of a profile field, reporting
+// whether it has the type of the field and matches its pattern.
+func parseProfileValue(field database.ProfileField, raw json.RawMessage) (any, bool) {
+	switch field.Type {
+	case profileFieldTypeText:
+		var value string
+		if json.Unmarshal(raw, &value) != nil {
+			return nil, false
+		}
+		if field.Pattern.Valid && !validator.Matches(value, regexp.MustCompile(field.Pattern.String)) {
+			return nil, false
+		}
+		return value, true
+	case profileFieldTypeNumber:
+		var value float64
+		if json.Unmarshal(raw, &value) != nil {
+			return nil, false
+		}
+		return value, true
+	case profileFieldTypeBoolean:
+		var value bool
+		if json.Unmarshal(raw, &value) != nil {
+			return nil, false
+		}
+		return value, true
+	case profileFieldTypeDate:
+		var value string
+		if json.Unmarshal(raw, &value) != nil || !validator.IsDate(value) {
+			return nil, false
+		}
+		return value, true
+	default:
+		return nil, false
+	}
+}
+
+// parseProfileFilter parses the value of a profile.<key> query parameter the
+// way parseProfileValue parses a JSON value, text and dates being taken as is.
+func parseProfileFilter(field database.ProfileField, s string) (any, bool) {
+	switch field.Type {
+	case profileFieldTypeNumber:
+		value, err := strconv.ParseFloat(s, 64)
+		return value, err == nil
+	case profileFieldTypeBoolean:
+		value, err := strconv.ParseBool(s)
+		return value, err == nil
+	case profileFieldTypeDate:
+		return s, validator.IsDate(s)
+	default:
+		return s, true
+	}
+}
+
+// readProfileFilter reads the profile.<key> query parameters into the JSON
+// document the profiles of the listed employees must contain. It's nil
+// without any such parameter.
+func readProfileFilter(query url.Values, fields []database.ProfileField, v *validator.Validator) []byte {
+	fieldsByKey := map[string]database.ProfileField{}
+	for _, field := range fields {
+		fieldsByKey[field.Key] = field
+	}
+
+	filter := map[string]any{}
+
+	for name, values := range query {
+		key, ok := strings.CutPrefix(name, profileFilterPrefix)
+		if !ok {
+			continue
+		}
+
+		field, ok := fieldsByKey[key]
+		if !ok {
+			v.AddFieldError("Profile."+key, "profile_field_invalid", "Unknown profile field")
+			continue
+		}
+
+		value, ok := parseProfileFilter(field, values[0])
+		if !ok {
+			v.AddFieldError("Profile."+key, "profile_value_invalid", "Must be a valid "+field.Type)
+			continue
+		}
+
+		filter[key] = value
+	}
+
+	if len(filter) == 0 {
+		return nil
+	}
+
+	// A map of strings, numbers and booleans always marshals.
+	profile, _ := json.Marshal(filter)
+	return profile
+}
+
+// updateEmployeeProfileHandler sets the values of the profile fields of the
+// employee, leaving the fields missing from the request as they are. A null
+// value removes the value of the field. Fields the caller can't see can't be
+// set either, and are kept.
+func (app *application) updateEmployeeProfileHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	var input struct {
+		Profile   map[string]json.RawMessage `json:"profile"`
+		Validator validator.Validator        `json:"-"`
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	employee, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	fields, err := app.visibleProfileFields(ctx, r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	fieldsByKey := map[string]database.ProfileField{}
+	for _, field := range fields {
+		fieldsByKey[field.Key] = field
+	}
+
+	var profile map[string]any
+
+	err = json.Unmarshal(employee.Profile, &profile)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	for key, raw := range input.Profile {
+		field, ok := fieldsByKey[key]
+		if !ok {
+			input.Validator.AddFieldError("Profile."+key, "profile_field_invalid", "Unknown profile field")
+			continue
+		}
+
+		if string(raw) == "null" {
+			delete(profile, key)
+			continue
+		}
+
+		value, ok := parseProfileValue(field, raw)
+		if !ok {
+			input.Validator.AddFieldError("Profile."+key, "profile_value_invalid", "Must be a valid "+field.Type)
+			continue
+		}
+
+		profile[key] = value
+	}
+
+	for _, field := range fields {
+		_, ok := profile[field.Key]
+		input.Validator.CheckField(ok || !field.Required, "Profile."+field.Key, "profile_value_required", field.Label+" is required")
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	employee.Profile, err = json.Marshal(profile)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.store.UpdateUserProfile(ctx, database.UpdateUserProfileParams{
+		ID:             employee.ID,
+		Profile:        employee.Profile,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.logger.InfoContext(ctx, "employee profile updated", slog.Group("employee",
+		"id", employee.ID,
+	))
+
+	data := newEmployeeResponse(employee)
+
+	data.Profile, err = visibleProfile(employee.Profile, fields)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"employee": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
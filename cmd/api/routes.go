@@ -12,15 +12,163 @@ func (app *application) routes() http.Handler {
 	mux.NotFound(app.notFound)
 	mux.MethodNotAllowed(app.methodNotAllowed)
 
+	mux.Use(app.traceRequest)
+	mux.Use(app.requestID)
 	mux.Use(app.logAccess)
+	mux.Use(app.recordMetrics)
 	mux.Use(app.recoverPanic)
 
+	mux.Get("/livez", app.livezHandler)
+	mux.Get("/readyz", app.readyzHandler)
+
 	v1Router := chi.NewRouter()
 
+	v1Router.Use(app.rateLimit("api", app.config.rateLimit.api, app.clientIP))
+	v1Router.Use(app.resolveOrganization)
+
 	v1Router.Get("/v1/healthcheck", app.healthcheckHandler)
 
 	v1Router.Post("/v1/employees", app.createEmployeeHandler)
 
+	v1Router.Group(func(v1Router chi.Router) {
+		v1Router.Use(app.rateLimit("login-ip", app.config.rateLimit.loginIP, app.clientIP))
+		v1Router.Use(app.rateLimit("login-account", app.config.rateLimit.loginAccount, accountEmail))
+
+		v1Router.Post("/v1/authentication-tokens", app.createAuthenticationToken)
+		v1Router.Post("/v1/authentication-tokens/two-factor", app.verifyTwoFactorHandler)
+	})
+
+	v1Router.Group(func(v1Router chi.Router) {
+		v1Router.Use(app.rateLimit("login-ip", app.config.rateLimit.loginIP, app.clientIP))
+
+		v1Router.Get("/v1/sso/login", app.ssoLoginHandler)
+		v1Router.Get("/v1/sso/callback", app.ssoCallbackHandler)
+	})
+
+	v1Router.Group(func(v1Router chi.Router) {
+		v1Router.Use(app.authenticate)
+		v1Router.Use(app.requireAuthenticatedUser)
+		v1Router.Use(app.forbidImpersonation)
+
+		v1Router.Get("/v1/two-factor", app.showTwoFactorHandler)
+		v1Router.Post("/v1/two-factor/totp", app.enrollTOTPHandler)
+		v1Router.Post("/v1/two-factor/totp/confirm", app.confirmTOTPHandler)
+		v1Router.Delete("/v1/two-factor/totp", app.disableTOTPHandler)
+		v1Router.Post("/v1/two-factor/recovery-codes", app.regenerateRecoveryCodesHandler)
+	})
+
+	v1Router.Group(func(v1Router chi.Router) {
+		v1Router.Use(app.authenticate)
+		v1Router.Use(app.requireAuthenticatedUser)
+		v1Router.Use(app.requireTwoFactor)
+
+		v1Router.Get("/v1/events", app.streamEventsHandler)
+	})
+
+	v1Router.Group(func(v1Router chi.Router) {
+		v1Router.Use(app.authenticate)
+		v1Router.Use(app.requireAuthenticatedUser)
+
+		v1Router.Delete("/v1/impersonation", app.stopImpersonationHandler)
+	})
+
+	v1Router.Group(func(v1Router chi.Router) {
+		v1Router.Use(app.authenticate)
+		v1Router.Use(app.requireTwoFactor)
+
+		v1Router.Get("/v1/employees/search", app.searchEmployeesHandler)
+	})
+
+	v1Router.Group(func(v1Router chi.Router) {
+		v1Router.Use(app.authenticate)
+		v1Router.Use(app.requireTwoFactor)
+		v1Router.Use(app.requirePermission("user_manager"))
+
+		v1Router.Get("/v1/employees", app.listEmployeesHandler)
+		v1Router.Get("/v1/employees/{id}", app.showEmployeeHandler)
+		v1Router.Patch("/v1/employees/{id}/profile", app.updateEmployeeProfileHandler)
+	})
+
+	v1Router.Group(func(v1Router chi.Router) {
+		v1Router.Use(app.authenticate)
+		v1Router.Use(app.forbidImpersonation)
+		v1Router.Use(app.requireTwoFactor)
+		v1Router.Use(app.requirePermission("admin"))
+
+		v1Router.Get("/v1/organization", app.showOrganizationHandler)
+		v1Router.Patch("/v1/organization", app.updateOrganizationHandler)
+		v1Router.Post("/v1/organization/scim-token", app.createSCIMTokenHandler)
+		v1Router.Delete("/v1/organization/scim-token", app.deleteSCIMTokenHandler)
+
+		v1Router.Get("/v1/two-factor/policy", app.showTwoFactorPolicyHandler)
+		v1Router.Put("/v1/two-factor/policy", app.updateTwoFactorPolicyHandler)
+		v1Router.Delete("/v1/employees/{id}/two-factor", app.resetTwoFactorHandler)
+
+		v1Router.Delete("/v1/employees/{id}", app.deleteEmployeeHandler)
+		v1Router.Post("/v1/employees/{id}/restore", app.restoreEmployeeHandler)
+		v1Router.Post("/v1/roles/{id}/restore", app.restoreRoleHandler)
+
+		v1Router.Post("/v1/employees/{id}/unlock", app.unlockEmployeeHandler)
+
+		v1Router.Post("/v1/employees/{id}/impersonation", app.startImpersonationHandler)
+
+		v1Router.Get("/v1/employees/{id}/offboarding", app.showOffboardingHandler)
+		v1Router.Put("/v1/employees/{id}/offboarding", app.scheduleOffboardingHandler)
+		v1Router.Delete("/v1/employees/{id}/offboarding", app.cancelOffboardingHandler)
+
+		v1Router.Get("/v1/employees/{id}/personal-data", app.exportPersonalDataHandler)
+		v1Router.Post("/v1/employees/{id}/erasure", app.eraseEmployeeHandler)
+		v1Router.Get("/v1/impersonation-sessions", app.listImpersonationSessionsHandler)
+
+		v1Router.Post("/v1/ldap/sync", app.syncLDAPHandler)
+
+		v1Router.Get("/v1/profile-fields", app.listProfileFieldsHandler)
+		v1Router.Post("/v1/profile-fields", app.createProfileFieldHandler)
+		v1Router.Patch("/v1/profile-fields/{id}", app.updateProfileFieldHandler)
+		v1Router.Delete("/v1/profile-fields/{id}", app.deleteProfileFieldHandler)
+
+		v1Router.Get("/v1/webhooks", app.listWebhooksHandler)
+		v1Router.Post("/v1/webhooks", app.createWebhookHandler)
+		v1Router.Get("/v1/webhooks/{id}", app.showWebhookHandler)
+		v1Router.Patch("/v1/webhooks/{id}", app.updateWebhookHandler)
+		v1Router.Delete("/v1/webhooks/{id}", app.deleteWebhookHandler)
+		v1Router.Get("/v1/webhooks/{id}/deliveries", app.listWebhookDeliveriesHandler)
+
+		v1Router.Get("/v1/service-accounts", app.listServiceAccountsHandler)
+		v1Router.Post("/v1/service-accounts", app.createServiceAccountHandler)
+		v1Router.Get("/v1/service-accounts/{id}", app.showServiceAccountHandler)
+		v1Router.Patch("/v1/service-accounts/{id}", app.updateServiceAccountHandler)
+		v1Router.Delete("/v1/service-accounts/{id}", app.deleteServiceAccountHandler)
+		v1Router.Get("/v1/service-accounts/{id}/api-keys", app.listAPIKeysHandler)
+		v1Router.Post("/v1/service-accounts/{id}/api-keys", app.createAPIKeyHandler)
+		v1Router.Delete("/v1/service-accounts/{id}/api-keys/{keyId}", app.revokeAPIKeyHandler)
+	})
+
+	mux.Route(scimPath, func(mux chi.Router) {
+		mux.Use(app.rateLimit("api", app.config.rateLimit.api, app.clientIP))
+		mux.Use(app.resolveOrganization)
+		mux.Use(app.requireSCIMToken)
+
+		mux.Get("/ServiceProviderConfig", app.scimServiceProviderConfigHandler)
+		mux.Get("/ResourceTypes", app.scimResourceTypesHandler)
+		mux.Get("/Schemas", app.scimSchemasHandler)
+		mux.Get("/Schemas/{id}", app.scimSchemaHandler)
+
+		mux.Get("/Users", app.listSCIMUsersHandler)
+		mux.Post("/Users", app.createSCIMUserHandler)
+		mux.Get("/Users/{id}", app.showSCIMUserHandler)
+		mux.Put("/Users/{id}", app.replaceSCIMUserHandler)
+		mux.Patch("/Users/{id}", app.patchSCIMUserHandler)
+		mux.Delete("/Users/{id}", app.deleteSCIMUserHandler)
+
+		mux.Get("/Groups", app.listSCIMGroupsHandler)
+		mux.Post("/Groups", app.createSCIMGroupHandler)
+		mux.Get("/Groups/{id}", app.showSCIMGroupHandler)
+		mux.Put("/Groups/{id}", app.replaceSCIMGroupHandler)
+		mux.Patch("/Groups/{id}", app.patchSCIMGroupHandler)
+		mux.Delete("/Groups/{id}", app.deleteSCIMGroupHandler)
+	})
+
 	// mux.Group(func(mux chi.Router) {
 	// 	mux.Use(app.authenticate)
 	// 	mux.Use(app.requireAuthenticatedUser)
@@ -32,3 +180,18 @@ func (app *application) routes() http.Handler {
 
 	return mux
 }
+
+// metricsRoutes serves the metrics, on a listener of its own kept off the
+// public network, as they aren't authenticated.
+func (app *application) metricsRoutes() http.Handler {
+	mux := chi.NewRouter()
+
+	mux.NotFound(app.notFound)
+	mux.MethodNotAllowed(app.methodNotAllowed)
+
+	mux.Use(app.recoverPanic)
+
+	mux.Method(http.MethodGet, "/metrics", app.metricsHandler())
+
+	return mux
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/brGuirra/uai/internal/apikey"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/scim"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scimPath is where the SCIM endpoints are mounted.
+const scimPath = "/scim/v2"
+
+// scimReference is a reference to another resource, such as a member of a
+// group.
+type scimReference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+func (app *application) scimURL(path string) string {
+	return app.config.baseURL + scimPath + path
+}
+
+// requireSCIMToken only lets through the requests authenticated with the SCIM
+// token of the organization of the request. Tokens are looked up by their ID,
+// so the token of another organization is told apart and rejected. The SCIM
+// endpoints don't exist for the organizations without a token.
+func (app *application) requireSCIMToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		organization := contextGetOrganization(r)
+
+		if !organization.ScimTokenID.Valid {
+			app.scimError(w, r, scim.NewError(http.StatusNotFound, "", "SCIM provisioning is disabled"))
+			return
+		}
+
+		invalidToken := func() {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.scimError(w, r, scim.NewError(http.StatusUnauthorized, "", "Invalid SCIM bearer token"))
+		}
+
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		tokenID, ok := apikey.Parse(token)
+		if !ok {
+			invalidToken()
+			return
+		}
+
+		ctx, cancel := detachedContext(r, 5*time.Second)
+		defer cancel()
+
+		owner, err := app.store.GetOrganizationBySCIMTokenID(ctx, pgtype.Text{String: tokenID, Valid: true})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				invalidToken()
+				return
+			}
+
+			app.scimFailed(w, r, err)
+			return
+		}
+
+		if !apikey.Matches(token, owner.ScimHashedToken.String) {
+			invalidToken()
+			return
+		}
+
+		if owner.ID != organization.ID {
+			app.logger.WarnContext(ctx, "scim token used for another organization", slog.Group("scim", "organization_id", organization.ID, "token_organization_id", owner.ID))
+			invalidToken()
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scimError responds with a SCIM error, the SCIM endpoints not using problem
+// details.
+func (app *application) scimError(w http.ResponseWriter, r *http.Request, e scim.Error) {
+	err := response.SCIMJSON(w, e.StatusCode(), e, nil)
+	if err != nil {
+		app.reportServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// scimFailed responds to a failed SCIM request with the error err stands for,
+// or else a server error.
+func (app *application) scimFailed(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		scimErr scim.Error
+		pgErr   *pgconn.PgError
+	)
+
+	switch {
+	case errors.As(err, &scimErr):
+		app.scimError(w, r, scimErr)
+	case errors.Is(err, pgx.ErrNoRows):
+		app.scimError(w, r, scim.NewError(http.StatusNotFound, "", "Resource not found"))
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		app.scimError(w, r, scim.NewError(http.StatusConflict, scim.ErrUniqueness, "A resource with the same unique attribute already exists"))
+	default:
+		trace.SpanFromContext(r.Context()).RecordError(err)
+		app.reportServerError(r, err)
+		app.scimError(w, r, scim.NewError(http.StatusInternalServerError, "", "The server encountered a problem and could not process your request"))
+	}
+}
+
+// writeSCIMResource responds with a resource and its ETag, along with its
+// location when it was just created. A GET request whose If-None-Match header
+// holds the ETag gets a 304 Not Modified response instead.
+func (app *application) writeSCIMResource(w http.ResponseWriter, r *http.Request, status int, resource any, meta scim.Meta) {
+	w.Header().Set("ETag", meta.Version)
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", meta.Location)
+	}
+
+	if r.Method == http.MethodGet && scim.MatchesETag(r.Header.Get("If-None-Match"), meta.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	err := response.SCIMJSON(w, status, resource, nil)
+	if err != nil {
+		app.scimFailed(w, r, err)
+	}
+}
+
+// checkSCIMPrecondition returns an error when the If-Match header of the
+// request doesn't hold the current ETag of the resource to modify.
+func checkSCIMPrecondition(r *http.Request, etag string) error {
+	ifMatch := r.Header.Get("If-Match")
+
+	if ifMatch != "" && !scim.MatchesETag(ifMatch, etag) {
+		return scim.NewError(http.StatusPreconditionFailed, "", "The resource has been modified since it was read")
+	}
+
+	return nil
+}
+
+func (app *application) scimServiceProviderConfigHandler(w http.ResponseWriter, r *http.Request) {
+	err := response.SCIMJSON(w, http.StatusOK, scim.ServiceProviderConfig(app.scimURL("")), nil)
+	if err != nil {
+		app.scimFailed(w, r, err)
+	}
+}
+
+func (app *application) scimResourceTypesHandler(w http.ResponseWriter, r *http.Request) {
+	resourceTypes := scim.ResourceTypes(app.scimURL(""))
+
+	err := response.SCIMJSON(w, http.StatusOK, scim.NewListResponse(int64(len(resourceTypes)), 1, resourceTypes), nil)
+	if err != nil {
+		app.scimFailed(w, r, err)
+	}
+}
+
+func (app *application) scimSchemasHandler(w http.ResponseWriter, r *http.Request) {
+	schemas := scim.Schemas(app.scimURL(""))
+
+	err := response.SCIMJSON(w, http.StatusOK, scim.NewListResponse(int64(len(schemas)), 1, schemas), nil)
+	if err != nil {
+		app.scimFailed(w, r, err)
+	}
+}
+
+func (app *application) scimSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	for _, schema := range scim.Schemas(app.scimURL("")) {
+		if schema.(map[string]any)["id"] == id {
+			err := response.SCIMJSON(w, http.StatusOK, schema, nil)
+			if err != nil {
+				app.scimFailed(w, r, err)
+			}
+			return
+		}
+	}
+
+	app.scimError(w, r, scim.NewError(http.StatusNotFound, "", "Schema not found"))
+}
@@ -0,0 +1,676 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/request"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/scim"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// scimGroup is the SCIM representation of a role, its members being the
+// employees holding it.
+type scimGroup struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id"`
+	DisplayName string          `json:"displayName"`
+	Members     []scimReference `json:"members"`
+	Meta        *scim.Meta      `json:"meta,omitempty"`
+}
+
+type scimGroupInput struct {
+	DisplayName string          `json:"displayName"`
+	Members     []scimReference `json:"members"`
+}
+
+// scimGroupState is a role being changed by a SCIM request.
+type scimGroupState struct {
+	displayName string
+	members     map[uuid.UUID]bool
+}
+
+// loadSCIMGroup returns the SCIM representation of the role, with its
+// version.
+func (app *application) loadSCIMGroup(ctx context.Context, q database.Querier, role database.Role) (scimGroup, error) {
+	members, err := q.GetRoleMembers(ctx, database.GetRoleMembersParams{
+		RoleID:         role.ID,
+		OrganizationID: role.OrganizationID,
+	})
+	if err != nil {
+		return scimGroup{}, err
+	}
+
+	resource := scimGroup{
+		Schemas:     []string{scim.GroupSchema},
+		ID:          role.ID.String(),
+		DisplayName: role.DisplayName,
+		Members:     make([]scimReference, 0, len(members)),
+	}
+
+	for _, member := range members {
+		resource.Members = append(resource.Members, scimReference{
+			Value:   member.ID.String(),
+			Display: member.Name,
+			Ref:     app.scimURL("/Users/" + member.ID.String()),
+		})
+	}
+
+	etag, err := scim.ETag(resource)
+	if err != nil {
+		return scimGroup{}, err
+	}
+
+	resource.Meta = &scim.Meta{
+		ResourceType: "Group",
+		Location:     app.scimURL("/Groups/" + resource.ID),
+		Version:      etag,
+	}
+
+	return resource, nil
+}
+
+// scimMemberIDs returns the IDs of the employees referenced by members.
+func scimMemberIDs(members []scimReference) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(members))
+
+	for _, member := range members {
+		id, err := uuid.Parse(member.Value)
+		if err != nil {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "Members must be referenced by the id of the user")
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func decodeSCIMMembers(value json.RawMessage) ([]uuid.UUID, error) {
+	var members []scimReference
+
+	// A single member can be sent without an array.
+	err := json.Unmarshal(value, &members)
+	if err != nil {
+		var member scimReference
+
+		if json.Unmarshal(value, &member) != nil {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "members must be an array of members")
+		}
+
+		members = []scimReference{member}
+	}
+
+	return scimMemberIDs(members)
+}
+
+func decodeSCIMDisplayName(value json.RawMessage) (string, error) {
+	var displayName string
+
+	err := json.Unmarshal(value, &displayName)
+	if err != nil || strings.TrimSpace(displayName) == "" {
+		return "", scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "displayName must be a non-empty string")
+	}
+
+	return displayName, nil
+}
+
+// applySCIMGroupInput replaces the display name and members of the role with
+// the body of a POST or PUT request.
+func applySCIMGroupInput(group *scimGroupState, input scimGroupInput) error {
+	if strings.TrimSpace(input.DisplayName) == "" {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "displayName must not be empty")
+	}
+
+	ids, err := scimMemberIDs(input.Members)
+	if err != nil {
+		return err
+	}
+
+	group.displayName = input.DisplayName
+	group.members = make(map[uuid.UUID]bool, len(ids))
+
+	for _, id := range ids {
+		group.members[id] = true
+	}
+
+	return nil
+}
+
+// applySCIMGroupPatch applies the operations of a PATCH request to the role.
+func applySCIMGroupPatch(group *scimGroupState, operations []scim.PatchOperation) error {
+	for _, op := range operations {
+		path, err := scim.ParsePath(op.Path)
+		if err != nil {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidPath, err.Error())
+		}
+
+		if path.Attribute == "" {
+			attributes, err := op.Attributes()
+			if err != nil {
+				return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, err.Error())
+			}
+
+			for attribute, value := range attributes {
+				err := applySCIMGroupOperation(group, op.Op, attribute, value)
+				if err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		// A remove of members[value eq "id"] removes one member.
+		if path.ValueFilter != nil {
+			if path.Attribute != "members" || path.ValueFilter.Attribute != "value" || op.Op != "remove" {
+				return scim.NewError(http.StatusBadRequest, scim.ErrInvalidPath, "Only members[value eq \"id\"] can be removed with a filter")
+			}
+
+			id, err := uuid.Parse(path.ValueFilter.Value)
+			if err != nil {
+				return scim.NewError(http.StatusBadRequest, scim.ErrNoTarget, "No member matches the filter")
+			}
+
+			delete(group.members, id)
+			continue
+		}
+
+		err = applySCIMGroupOperation(group, op.Op, path.Attribute, op.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applySCIMGroupOperation applies an operation to an attribute of the role
+// from its lowercase SCIM name. As for users, the attributes that aren't
+// stored are ignored.
+func applySCIMGroupOperation(group *scimGroupState, op, attribute string, value json.RawMessage) error {
+	switch attribute {
+	case "displayname":
+		if op == "remove" {
+			return scim.NewError(http.StatusBadRequest, scim.ErrMutability, "displayName can't be removed")
+		}
+
+		displayName, err := decodeSCIMDisplayName(value)
+		if err != nil {
+			return err
+		}
+
+		group.displayName = displayName
+
+	case "members":
+		var ids []uuid.UUID
+
+		if len(value) > 0 {
+			var err error
+
+			ids, err = decodeSCIMMembers(value)
+			if err != nil {
+				return err
+			}
+		}
+
+		switch op {
+		case "add":
+			for _, id := range ids {
+				group.members[id] = true
+			}
+		case "replace":
+			group.members = make(map[uuid.UUID]bool, len(ids))
+			for _, id := range ids {
+				group.members[id] = true
+			}
+		case "remove":
+			// A remove without value removes every member.
+			if len(value) == 0 {
+				group.members = map[uuid.UUID]bool{}
+			}
+			for _, id := range ids {
+				delete(group.members, id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// saveSCIMGroup stores the changes made to a role, returning the employees
+// who were granted or revoked it.
+func saveSCIMGroup(ctx context.Context, q *database.Queries, role database.Role, before, after scimGroupState) (granted, revoked []uuid.UUID, err error) {
+	if before.displayName != after.displayName {
+		n, err := q.CountRoles(ctx, database.CountRolesParams{
+			DisplayName:    pgtype.Text{String: after.displayName, Valid: true},
+			OrganizationID: role.OrganizationID,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if n > 0 {
+			return nil, nil, scim.NewError(http.StatusConflict, scim.ErrUniqueness, "displayName is already in use")
+		}
+
+		_, err = q.UpdateRoleDisplayName(ctx, database.UpdateRoleDisplayNameParams{
+			ID:             role.ID,
+			DisplayName:    after.displayName,
+			OrganizationID: role.OrganizationID,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for id := range after.members {
+		if !before.members[id] {
+			granted = append(granted, id)
+		}
+	}
+
+	for id := range before.members {
+		if !after.members[id] {
+			revoked = append(revoked, id)
+		}
+	}
+
+	if len(revoked) > 0 {
+		err = q.RemoveRoleMembers(ctx, database.RemoveRoleMembersParams{RoleID: role.ID, UserIds: revoked, OrganizationID: role.OrganizationID})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(granted) > 0 {
+		grantor, err := systemGrantor(ctx, q, role.OrganizationID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = q.AddRoleMembers(ctx, database.AddRoleMembersParams{RoleID: role.ID, Grantor: grantor, OrganizationID: role.OrganizationID, UserIds: granted})
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return nil, nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "Members must be existing users")
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return granted, revoked, nil
+}
+
+// publishSCIMGroupChanges publishes a roles changed event, in the transaction
+// of q, for every employee who was granted or revoked the role.
+func publishSCIMGroupChanges(ctx context.Context, q *database.Queries, role database.Role, granted, revoked []uuid.UUID) ([]database.WebhookDelivery, error) {
+	var deliveries []database.WebhookDelivery
+
+	publish := func(ids []uuid.UUID, grant bool) error {
+		for _, id := range ids {
+			scheduled, err := publishEvent(ctx, q, role.OrganizationID, eventEmployeeRolesChanged, rolesChangedEventData(id, role.ID, grant))
+			if err != nil {
+				return err
+			}
+
+			deliveries = append(deliveries, scheduled...)
+		}
+
+		return nil
+	}
+
+	err := publish(granted, true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = publish(revoked, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (app *application) listSCIMGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	var displayName pgtype.Text
+
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		f, err := scim.ParseFilter(filter)
+		if err != nil || f.Attribute != "displayname" {
+			app.scimError(w, r, scim.NewError(http.StatusBadRequest, scim.ErrInvalidFilter, "Groups can only be filtered with displayName eq"))
+			return
+		}
+
+		displayName = pgtype.Text{String: f.Value, Valid: true}
+	}
+
+	startIndex, count := scim.Pagination(r)
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	organizationID := contextGetOrganization(r).ID
+
+	total, err := app.store.CountRoles(ctx, database.CountRolesParams{
+		DisplayName:    displayName,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	roles, err := app.store.ListRoles(ctx, database.ListRolesParams{
+		DisplayName:    displayName,
+		OrganizationID: organizationID,
+		Limit:          int32(count),
+		Offset:         int32(startIndex - 1),
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	resources := make([]any, 0, len(roles))
+
+	for _, role := range roles {
+		resource, err := app.loadSCIMGroup(ctx, app.store, role)
+		if err != nil {
+			app.scimFailed(w, r, err)
+			return
+		}
+
+		resources = append(resources, resource)
+	}
+
+	err = response.SCIMJSON(w, http.StatusOK, scim.NewListResponse(total, startIndex, resources), nil)
+	if err != nil {
+		app.scimFailed(w, r, err)
+	}
+}
+
+func (app *application) showSCIMGroupHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusNotFound, "", "Resource not found"))
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	role, err := app.store.GetRole(ctx, database.GetRoleParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	resource, err := app.loadSCIMGroup(ctx, app.store, role)
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	app.writeSCIMResource(w, r, http.StatusOK, resource, *resource.Meta)
+}
+
+func (app *application) createSCIMGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var input scimGroupInput
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	group := scimGroupState{}
+
+	err = applySCIMGroupInput(&group, input)
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	var (
+		resource   scimGroup
+		deliveries []database.WebhookDelivery
+	)
+
+	organizationID := contextGetOrganization(r).ID
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		n, err := q.CountRoles(ctx, database.CountRolesParams{
+			DisplayName:    pgtype.Text{String: group.displayName, Valid: true},
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if n > 0 {
+			return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "displayName is already in use")
+		}
+
+		role, err := q.CreateRole(ctx, database.CreateRoleParams{
+			DisplayName:    group.displayName,
+			Description:    "",
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		before := scimGroupState{displayName: group.displayName, members: map[uuid.UUID]bool{}}
+
+		granted, _, err := saveSCIMGroup(ctx, q, role, before, group)
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishSCIMGroupChanges(ctx, q, role, granted, nil)
+		if err != nil {
+			return err
+		}
+
+		resource, err = app.loadSCIMGroup(ctx, q, role)
+		return err
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	app.deliverWebhooks(r, deliveries)
+
+	app.writeSCIMResource(w, r, http.StatusCreated, resource, *resource.Meta)
+}
+
+func (app *application) replaceSCIMGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var input scimGroupInput
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	app.updateSCIMGroup(w, r, func(group *scimGroupState) error {
+		return applySCIMGroupInput(group, input)
+	})
+}
+
+func (app *application) patchSCIMGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var input scim.PatchRequest
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	app.updateSCIMGroup(w, r, func(group *scimGroupState) error {
+		return applySCIMGroupPatch(group, input.Operations)
+	})
+}
+
+// updateSCIMGroup applies the changes of a PUT or PATCH request to the role of
+// the request, and responds with the result.
+func (app *application) updateSCIMGroup(w http.ResponseWriter, r *http.Request, apply func(group *scimGroupState) error) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusNotFound, "", "Resource not found"))
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	var (
+		resource   scimGroup
+		deliveries []database.WebhookDelivery
+	)
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		role, err := q.GetRole(ctx, database.GetRoleParams{
+			ID:             id,
+			OrganizationID: contextGetOrganization(r).ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		current, err := app.loadSCIMGroup(ctx, q, role)
+		if err != nil {
+			return err
+		}
+
+		err = checkSCIMPrecondition(r, current.Meta.Version)
+		if err != nil {
+			return err
+		}
+
+		before := scimGroupState{displayName: role.DisplayName, members: map[uuid.UUID]bool{}}
+
+		for _, member := range current.Members {
+			before.members[uuid.MustParse(member.Value)] = true
+		}
+
+		after := scimGroupState{displayName: before.displayName, members: make(map[uuid.UUID]bool, len(before.members))}
+
+		for id := range before.members {
+			after.members[id] = true
+		}
+
+		err = apply(&after)
+		if err != nil {
+			return err
+		}
+
+		granted, revoked, err := saveSCIMGroup(ctx, q, role, before, after)
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishSCIMGroupChanges(ctx, q, role, granted, revoked)
+		if err != nil {
+			return err
+		}
+
+		role.DisplayName = after.displayName
+
+		resource, err = app.loadSCIMGroup(ctx, q, role)
+		return err
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	app.deliverWebhooks(r, deliveries)
+
+	app.writeSCIMResource(w, r, http.StatusOK, resource, *resource.Meta)
+}
+
+func (app *application) deleteSCIMGroupHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusNotFound, "", "Resource not found"))
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	var deliveries []database.WebhookDelivery
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		role, err := q.GetRole(ctx, database.GetRoleParams{
+			ID:             id,
+			OrganizationID: contextGetOrganization(r).ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		current, err := app.loadSCIMGroup(ctx, q, role)
+		if err != nil {
+			return err
+		}
+
+		err = checkSCIMPrecondition(r, current.Meta.Version)
+		if err != nil {
+			return err
+		}
+
+		// The members and permissions of the role are kept, so restoring it
+		// gives them back, but they're ignored while it's deleted.
+		var revoked []uuid.UUID
+
+		for _, member := range current.Members {
+			revoked = append(revoked, uuid.MustParse(member.Value))
+		}
+
+		_, err = q.DeleteRole(ctx, database.DeleteRoleParams{
+			ID:             id,
+			OrganizationID: contextGetOrganization(r).ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishSCIMGroupChanges(ctx, q, role, nil, revoked)
+		return err
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	app.deliverWebhooks(r, deliveries)
+
+	w.WriteHeader(http.StatusNoContent)
+}
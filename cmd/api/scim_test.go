@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brGuirra/uai/internal/apikey"
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// setTestSCIMToken gives the organization a new SCIM token, and returns it.
+func setTestSCIMToken(t *testing.T, organization *database.Organization) string {
+	t.Helper()
+
+	generated, err := apikey.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	organization.ScimTokenID = pgtype.Text{String: generated.ID, Valid: true}
+	organization.ScimHashedToken = pgtype.Text{String: generated.Hash, Valid: true}
+
+	return generated.Key
+}
+
+func TestRequireSCIMToken(t *testing.T) {
+	app, store := newTestApplication(t)
+
+	other := database.Organization{ID: uuid.New(), Slug: "acme", Name: "Acme"}
+	otherToken := setTestSCIMToken(t, &other)
+	store.otherOrganizations = append(store.otherOrganizations, other)
+
+	send := func(token string) int {
+		r := newTestRequest(store, http.MethodGet, scimPath+"/Users", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		rr := httptest.NewRecorder()
+		app.requireSCIMToken(next).ServeHTTP(rr, r)
+
+		return rr.Code
+	}
+
+	if status := send(otherToken); status != http.StatusNotFound {
+		t.Errorf("without a token of the organization: got status %d; want %d", status, http.StatusNotFound)
+	}
+
+	token := setTestSCIMToken(t, &store.organization)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"Token of the organization", token, http.StatusOK},
+		{"Token of another organization", otherToken, http.StatusUnauthorized},
+		{"Tampered token", token + "A", http.StatusUnauthorized},
+		{"Not a token", "t0ken", http.StatusUnauthorized},
+		{"No token", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := send(tt.token); status != tt.status {
+				t.Errorf("got status %d; want %d", status, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,651 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/password"
+	"github.com/brGuirra/uai/internal/request"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/scim"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// scimUser is the SCIM representation of an employee. The userName is the
+// email address of the employee, and the groups are their roles.
+type scimUser struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id"`
+	UserName    string          `json:"userName"`
+	Name        scimName        `json:"name"`
+	DisplayName string          `json:"displayName"`
+	Emails      []scimEmail     `json:"emails"`
+	Active      bool            `json:"active"`
+	Groups      []scimReference `json:"groups"`
+	Meta        *scim.Meta      `json:"meta,omitempty"`
+}
+
+// scimUserInput holds the attributes of a user that can be set by POST and
+// PUT requests. Emails are read-only, as the userName is the email address.
+// The password is write-only, and left unchanged when empty.
+type scimUserInput struct {
+	UserName    string   `json:"userName"`
+	Name        scimName `json:"name"`
+	DisplayName string   `json:"displayName"`
+	Active      *bool    `json:"active"`
+	Password    string   `json:"password"`
+}
+
+// loadSCIMUser returns the SCIM representation of the employee, with its
+// version.
+func (app *application) loadSCIMUser(ctx context.Context, q database.Querier, user database.User) (scimUser, error) {
+	roles, err := q.GetRolesForUser(ctx, database.GetRolesForUserParams{
+		UserID:         user.ID,
+		OrganizationID: user.OrganizationID,
+	})
+	if err != nil {
+		return scimUser{}, err
+	}
+
+	resource := scimUser{
+		Schemas:     []string{scim.UserSchema},
+		ID:          user.ID.String(),
+		UserName:    user.Email,
+		Name:        scimName{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []scimEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      user.Status == employeeStatusActive,
+		Groups:      make([]scimReference, 0, len(roles)),
+	}
+
+	for _, role := range roles {
+		resource.Groups = append(resource.Groups, scimReference{
+			Value:   role.ID.String(),
+			Display: role.DisplayName,
+			Ref:     app.scimURL("/Groups/" + role.ID.String()),
+		})
+	}
+
+	etag, err := scim.ETag(resource)
+	if err != nil {
+		return scimUser{}, err
+	}
+
+	resource.Meta = &scim.Meta{
+		ResourceType: "User",
+		Location:     app.scimURL("/Users/" + resource.ID),
+		Version:      etag,
+	}
+
+	return resource, nil
+}
+
+// setSCIMUserAttribute sets an attribute of the employee from its lowercase
+// SCIM name. The attributes that aren't stored are ignored, as identity
+// providers send many of them. name collects the parts of the name sent
+// separately, and password the new password, which is only hashed once it's
+// checked against the password policy.
+func setSCIMUserAttribute(user *database.User, name *scimName, password *string, attribute string, value json.RawMessage) error {
+	switch attribute {
+	case "username", "displayname", "name.formatted", "name.givenname", "name.familyname", "password":
+		var s string
+
+		err := json.Unmarshal(value, &s)
+		if err != nil {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, attribute+" must be a string")
+		}
+
+		switch attribute {
+		case "username":
+			user.Email = s
+		case "displayname", "name.formatted":
+			name.Formatted = s
+		case "name.givenname":
+			name.GivenName = s
+		case "name.familyname":
+			name.FamilyName = s
+		case "password":
+			*password = s
+		}
+
+	case "active":
+		var active bool
+
+		// Some identity providers send booleans as strings.
+		err := json.Unmarshal(value, &active)
+		if err != nil {
+			var s string
+			if json.Unmarshal(value, &s) != nil {
+				return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "active must be a boolean")
+			}
+
+			active, err = strconv.ParseBool(strings.ToLower(s))
+			if err != nil {
+				return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "active must be a boolean")
+			}
+		}
+
+		user.Status = employeeStatusDeactivated
+		if active {
+			user.Status = employeeStatusActive
+		}
+	}
+
+	return nil
+}
+
+// applySCIMUserInput sets the attributes of the employee from the body of a
+// POST or PUT request. active is only changed when present.
+func applySCIMUserInput(user *database.User, input scimUserInput) error {
+	user.Email = input.UserName
+
+	name := input.Name
+	if input.DisplayName != "" {
+		name.Formatted = input.DisplayName
+	}
+
+	user.Name = scimFullName(name, user.Email)
+
+	if input.Active != nil {
+		user.Status = employeeStatusDeactivated
+		if *input.Active {
+			user.Status = employeeStatusActive
+		}
+	}
+
+	return validateSCIMUser(*user)
+}
+
+// applySCIMUserPatch applies the operations of a PATCH request to the
+// employee. password is set to the new password, if any.
+func applySCIMUserPatch(user *database.User, password *string, operations []scim.PatchOperation) error {
+	var name scimName
+
+	for _, op := range operations {
+		var attributes map[string]json.RawMessage
+
+		if op.Path == "" {
+			if op.Op == "remove" {
+				return scim.NewError(http.StatusBadRequest, scim.ErrNoTarget, "remove operations must have a path")
+			}
+
+			var err error
+
+			attributes, err = op.Attributes()
+			if err != nil {
+				return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, err.Error())
+			}
+		} else {
+			path, err := scim.ParsePath(op.Path)
+			if err != nil {
+				return scim.NewError(http.StatusBadRequest, scim.ErrInvalidPath, err.Error())
+			}
+
+			attribute := path.Attribute
+			if path.SubAttribute != "" {
+				attribute += "." + path.SubAttribute
+			}
+
+			if op.Op == "remove" {
+				if validator.In(attribute, "username", "active") {
+					return scim.NewError(http.StatusBadRequest, scim.ErrMutability, attribute+" can't be removed")
+				}
+				continue
+			}
+
+			attributes = map[string]json.RawMessage{attribute: op.Value}
+		}
+
+		for attribute, value := range attributes {
+			err := setSCIMUserAttribute(user, &name, password, attribute, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if name != (scimName{}) {
+		user.Name = scimFullName(name, user.Name)
+	}
+
+	return validateSCIMUser(*user)
+}
+
+// scimFullName returns the name of an employee from the parts of a SCIM name,
+// or fallback when they are empty.
+func scimFullName(name scimName, fallback string) string {
+	if name.Formatted != "" {
+		return name.Formatted
+	}
+
+	if full := strings.TrimSpace(name.GivenName + " " + name.FamilyName); full != "" {
+		return full
+	}
+
+	return fallback
+}
+
+func validateSCIMUser(user database.User) error {
+	if !validator.Matches(user.Email, validator.RgxEmail) {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "userName must be a valid email address")
+	}
+
+	if strings.TrimSpace(user.Name) == "" {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "The name must not be empty")
+	}
+
+	return nil
+}
+
+// scimPasswordHash returns the hash of a password set by the identity
+// provider for the employee, once checked against the password policy. The
+// employees signing in with the directory have no password of their own.
+func (app *application) scimPasswordHash(ctx context.Context, q *database.Queries, user database.User, plaintextPassword string) (pgtype.Text, error) {
+	if user.ID != uuid.Nil && app.ldap != nil && app.config.ldap.authentication {
+		_, err := q.GetLDAPAccount(ctx, database.GetLDAPAccountParams{
+			UserID:         user.ID,
+			OrganizationID: user.OrganizationID,
+		})
+
+		switch {
+		case err == nil:
+			return pgtype.Text{}, scim.NewError(http.StatusBadRequest, scim.ErrMutability, "The password is managed by the directory")
+		case !errors.Is(err, pgx.ErrNoRows):
+			return pgtype.Text{}, err
+		}
+	}
+
+	violations := app.passwordPolicy.Check(plaintextPassword, user.Name, user.Email)
+	if len(violations) > 0 {
+		messages := make([]string, len(violations))
+		for i, violation := range violations {
+			messages[i] = violation.Message
+		}
+
+		return pgtype.Text{}, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, strings.Join(messages, "; "))
+	}
+
+	hashedPassword, err := password.Hash(plaintextPassword)
+	if err != nil {
+		return pgtype.Text{}, err
+	}
+
+	return pgtype.Text{String: hashedPassword, Valid: true}, nil
+}
+
+// saveSCIMUser stores the changes made to an employee, checking the new email
+// address isn't used by another employee, deleted or not.
+func saveSCIMUser(ctx context.Context, q *database.Queries, before, after database.User) error {
+	if !strings.EqualFold(before.Email, after.Email) {
+		exists, err := q.UserEmailExists(ctx, database.UserEmailExistsParams{
+			Email:          after.Email,
+			OrganizationID: after.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "userName is already in use")
+		}
+	}
+
+	return q.UpdateUser(ctx, database.UpdateUserParams{
+		ID:             after.ID,
+		Name:           after.Name,
+		Email:          after.Email,
+		HashedPassword: after.HashedPassword,
+		Status:         after.Status,
+		OrganizationID: after.OrganizationID,
+	})
+}
+
+func (app *application) listSCIMUsersHandler(w http.ResponseWriter, r *http.Request) {
+	var email pgtype.Text
+
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		f, err := scim.ParseFilter(filter)
+		if err != nil || !validator.In(f.Attribute, "username", "emails", "emails.value") {
+			app.scimError(w, r, scim.NewError(http.StatusBadRequest, scim.ErrInvalidFilter, "Users can only be filtered with userName eq or emails eq"))
+			return
+		}
+
+		email = pgtype.Text{String: f.Value, Valid: true}
+	}
+
+	startIndex, count := scim.Pagination(r)
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	organizationID := contextGetOrganization(r).ID
+
+	total, err := app.store.CountUsers(ctx, database.CountUsersParams{
+		Email:          email,
+		OrganizationID: organizationID,
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	users, err := app.store.ListUsers(ctx, database.ListUsersParams{
+		Email:          email,
+		OrganizationID: organizationID,
+		Limit:          int32(count),
+		Offset:         int32(startIndex - 1),
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	resources := make([]any, 0, len(users))
+
+	for _, user := range users {
+		resource, err := app.loadSCIMUser(ctx, app.store, user)
+		if err != nil {
+			app.scimFailed(w, r, err)
+			return
+		}
+
+		resources = append(resources, resource)
+	}
+
+	err = response.SCIMJSON(w, http.StatusOK, scim.NewListResponse(total, startIndex, resources), nil)
+	if err != nil {
+		app.scimFailed(w, r, err)
+	}
+}
+
+func (app *application) showSCIMUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusNotFound, "", "Resource not found"))
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	user, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	resource, err := app.loadSCIMUser(ctx, app.store, user)
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	app.writeSCIMResource(w, r, http.StatusOK, resource, *resource.Meta)
+}
+
+func (app *application) createSCIMUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input scimUserInput
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	user := database.User{Status: employeeStatusActive, OrganizationID: contextGetOrganization(r).ID}
+
+	err = applySCIMUserInput(&user, input)
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	var (
+		resource   scimUser
+		deliveries []database.WebhookDelivery
+	)
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		exists, err := q.UserEmailExists(ctx, database.UserEmailExistsParams{
+			Email:          user.Email,
+			OrganizationID: user.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "userName is already in use")
+		}
+
+		if input.Password != "" {
+			user.HashedPassword, err = app.scimPasswordHash(ctx, q, user, input.Password)
+			if err != nil {
+				return err
+			}
+		}
+
+		user, err = q.CreateUser(ctx, database.CreateUserParams{
+			Name:           user.Name,
+			Email:          user.Email,
+			Status:         user.Status,
+			HashedPassword: user.HashedPassword,
+			OrganizationID: user.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishEvent(ctx, q, user.OrganizationID, eventEmployeeCreated, employeeEventData(user))
+		if err != nil {
+			return err
+		}
+
+		resource, err = app.loadSCIMUser(ctx, q, user)
+		return err
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	app.deliverWebhooks(r, deliveries)
+
+	app.writeSCIMResource(w, r, http.StatusCreated, resource, *resource.Meta)
+}
+
+func (app *application) replaceSCIMUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input scimUserInput
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	app.updateSCIMUser(w, r, func(user *database.User, password *string) error {
+		*password = input.Password
+		return applySCIMUserInput(user, input)
+	})
+}
+
+func (app *application) patchSCIMUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input scim.PatchRequest
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	err = input.Validate()
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, err.Error()))
+		return
+	}
+
+	app.updateSCIMUser(w, r, func(user *database.User, password *string) error {
+		return applySCIMUserPatch(user, password, input.Operations)
+	})
+}
+
+// updateSCIMUser applies the changes of a PUT or PATCH request to the employee
+// of the request, and responds with the result. apply sets the new password,
+// if any.
+func (app *application) updateSCIMUser(w http.ResponseWriter, r *http.Request, apply func(user *database.User, password *string) error) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusNotFound, "", "Resource not found"))
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	var (
+		resource   scimUser
+		deliveries []database.WebhookDelivery
+	)
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		before, err := q.GetUser(ctx, database.GetUserParams{
+			ID:             id,
+			OrganizationID: contextGetOrganization(r).ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		current, err := app.loadSCIMUser(ctx, q, before)
+		if err != nil {
+			return err
+		}
+
+		err = checkSCIMPrecondition(r, current.Meta.Version)
+		if err != nil {
+			return err
+		}
+
+		after := before
+
+		var plaintextPassword string
+
+		err = apply(&after, &plaintextPassword)
+		if err != nil {
+			return err
+		}
+
+		if plaintextPassword != "" {
+			after.HashedPassword, err = app.scimPasswordHash(ctx, q, after, plaintextPassword)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Terminated employees can't be reactivated by the identity
+		// provider.
+		if before.Status == employeeStatusTerminated {
+			after.Status = before.Status
+		}
+
+		err = saveSCIMUser(ctx, q, before, after)
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishEmployeeStatusChange(ctx, q, before, after)
+		if err != nil {
+			return err
+		}
+
+		resource, err = app.loadSCIMUser(ctx, q, after)
+		return err
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	app.deliverWebhooks(r, deliveries)
+
+	app.writeSCIMResource(w, r, http.StatusOK, resource, *resource.Meta)
+}
+
+func (app *application) deleteSCIMUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.scimError(w, r, scim.NewError(http.StatusNotFound, "", "Resource not found"))
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	var deliveries []database.WebhookDelivery
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		user, err := q.GetUser(ctx, database.GetUserParams{
+			ID:             id,
+			OrganizationID: contextGetOrganization(r).ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		current, err := app.loadSCIMUser(ctx, q, user)
+		if err != nil {
+			return err
+		}
+
+		err = checkSCIMPrecondition(r, current.Meta.Version)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.DeleteUser(ctx, database.DeleteUserParams{
+			ID:             id,
+			OrganizationID: contextGetOrganization(r).ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishEvent(ctx, q, user.OrganizationID, eventEmployeeDeleted, employeeEventData(user))
+		return err
+	})
+	if err != nil {
+		app.scimFailed(w, r, err)
+		return
+	}
+
+	app.deliverWebhooks(r, deliveries)
+
+	w.WriteHeader(http.StatusNoContent)
+}
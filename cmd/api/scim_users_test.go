@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/brGuirra/uai/internal/password"
+)
+
+func TestSCIMDeactivationRevokesTokens(t *testing.T) {
+	app, store := newTestApplication(t)
+
+	employee := newTestEmployee(store, "Ada Lovelace", "ada@example.com")
+	token := issueTestToken(t, app, store, employee)
+
+	if status := authenticateTestRequest(app, store, token); status != http.StatusOK {
+		t.Fatalf("before deactivation: got status %d; want %d", status, http.StatusOK)
+	}
+
+	// The PATCH request identity providers send when a user is unassigned.
+	body := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "value": {"active": false}}]
+	}`
+
+	rr := serveTestSCIMRequest(t, app, store, http.MethodPatch, "/Users/"+employee.ID.String(), body)
+	app.wg.Wait()
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d and body %s; want %d", rr.Code, rr.Body, http.StatusOK)
+	}
+
+	if len(store.transactions) != 1 || !slices.Contains(store.transactions[0], "UpdateUser") {
+		t.Fatalf("got queries %v; want the employee updated in a transaction", store.transactions)
+	}
+
+	if status := store.employees[employee.ID].Status; status != employeeStatusDeactivated {
+		t.Fatalf("got status %q; want %q", status, employeeStatusDeactivated)
+	}
+
+	if len(store.events) != 1 || store.events[0].Type != eventEmployeeDeactivated {
+		t.Errorf("got events %v; want one %s event", store.events, eventEmployeeDeactivated)
+	}
+
+	if status := authenticateTestRequest(app, store, token); status != http.StatusUnauthorized {
+		t.Errorf("after deactivation: got status %d; want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestSCIMPasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		status   int
+	}{
+		{name: "compliant", password: "x7#Kp2!vQz9@Lm", status: http.StatusOK},
+		{name: "too short", password: "x7#Kp2!v", status: http.StatusBadRequest},
+		{name: "personal info", password: "Lovelace#2024!x", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, store := newTestApplication(t)
+			app.passwordPolicy = password.Policy{MinLength: 12}
+
+			employee := newTestEmployee(store, "Ada Lovelace", "ada@example.com")
+
+			body := `{
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{"op": "replace", "path": "password", "value": "` + tt.password + `"}]
+			}`
+
+			rr := serveTestSCIMRequest(t, app, store, http.MethodPatch, "/Users/"+employee.ID.String(), body)
+			app.wg.Wait()
+
+			if rr.Code != tt.status {
+				t.Fatalf("got status %d and body %s; want %d", rr.Code, rr.Body, tt.status)
+			}
+
+			stored := store.employees[employee.ID].HashedPassword
+
+			matches, err := password.Matches(tt.password, stored.String)
+			if err != nil && stored.Valid {
+				t.Fatal(err)
+			}
+
+			if matches != (tt.status == http.StatusOK) {
+				t.Errorf("got password stored %t; want %t", matches, tt.status == http.StatusOK)
+			}
+
+			if strings.Contains(rr.Body.String(), tt.password) {
+				t.Errorf("the body %s holds the password", rr.Body)
+			}
+		})
+	}
+}
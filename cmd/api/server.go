@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -20,6 +21,12 @@ const (
 )
 
 func (app *application) serveHTTP() error {
+	// baseCtx is cancelled as soon as the shutdown starts, which ends the
+	// long-lived requests (such as event streams) that would otherwise keep
+	// srv.Shutdown waiting.
+	baseCtx, cancelBaseCtx := context.WithCancel(context.Background())
+	defer cancelBaseCtx()
+
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.config.httpPort),
 		Handler:      app.routes(),
@@ -27,6 +34,74 @@ func (app *application) serveHTTP() error {
 		IdleTimeout:  defaultIdleTimeout,
 		ReadTimeout:  defaultReadTimeout,
 		WriteTimeout: defaultWriteTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+	}
+
+	srv.RegisterOnShutdown(cancelBaseCtx)
+
+	var metricsSrv *http.Server
+
+	if app.config.metricsPort != 0 {
+		metricsSrv = &http.Server{
+			Addr:         fmt.Sprintf(":%d", app.config.metricsPort),
+			Handler:      app.metricsRoutes(),
+			ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelWarn),
+			IdleTimeout:  defaultIdleTimeout,
+			ReadTimeout:  defaultReadTimeout,
+			WriteTimeout: defaultWriteTimeout,
+		}
+
+		// Listen right away, so a port already in use stops the application
+		// from starting.
+		ln, err := net.Listen("tcp", metricsSrv.Addr)
+		if err != nil {
+			return err
+		}
+
+		app.logger.Info("starting metrics server", slog.Group("server", "addr", metricsSrv.Addr))
+
+		go func() {
+			err := metricsSrv.Serve(ln)
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error(err.Error(), slog.Group("server", "addr", metricsSrv.Addr))
+			}
+		}()
+	}
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.listenForEvents(baseCtx)
+	}()
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.runOffboarding(baseCtx)
+	}()
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.runWebhookRetries(baseCtx)
+	}()
+
+	if app.config.deletion.retention > 0 {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			app.runPurge(baseCtx)
+		}()
+	}
+
+	if app.ldap != nil && app.config.ldap.syncInterval > 0 {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			app.runLDAPSync(baseCtx)
+		}()
 	}
 
 	shutdownErrorChan := make(chan error)
@@ -36,10 +111,23 @@ func (app *application) serveHTTP() error {
 		signal.Notify(quitChan, syscall.SIGINT, syscall.SIGTERM)
 		<-quitChan
 
+		// Fail the readiness probe for a while before shutting down, so load
+		// balancers stop routing new requests to this instance first.
+		app.shuttingDown.Store(true)
+		app.logger.Info("draining server", slog.Group("server", "addr", srv.Addr, "delay", app.config.shutdownDelay.String()))
+		time.Sleep(app.config.shutdownDelay)
+
 		ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownPeriod)
 		defer cancel()
 
-		shutdownErrorChan <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+
+		// The metrics are served until the very end of the shutdown.
+		if metricsSrv != nil {
+			err = errors.Join(err, metricsSrv.Shutdown(ctx))
+		}
+
+		shutdownErrorChan <- err
 	}()
 
 	app.logger.Info("starting server", slog.Group("server", "addr", srv.Addr))
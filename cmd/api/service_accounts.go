@@ -0,0 +1,637 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/brGuirra/uai/internal/apikey"
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/request"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var errInvalidAPIKey = errors.New("invalid API key")
+
+// serviceAccountPrincipal is the service account a request was authenticated
+// as, with the permissions of the API key it used.
+type serviceAccountPrincipal struct {
+	ID          uuid.UUID
+	KeyID       uuid.UUID
+	Permissions []string
+}
+
+type serviceAccountResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Permissions []string   `json:"permissions"`
+	Active      bool       `json:"active"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	DisabledAt  *time.Time `json:"disabledAt,omitempty"`
+}
+
+func newServiceAccountResponse(account database.ServiceAccount, permissions []string) serviceAccountResponse {
+	res := serviceAccountResponse{
+		ID:          account.ID,
+		Name:        account.Name,
+		Description: account.Description,
+		Permissions: permissions,
+		Active:      !account.DisabledAt.Valid,
+		CreatedAt:   account.CreatedAt.Time,
+	}
+
+	if account.DisabledAt.Valid {
+		res.DisabledAt = &account.DisabledAt.Time
+	}
+
+	return res
+}
+
+type apiKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	KeyID      string     `json:"keyId"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+func newAPIKeyResponse(key database.ApiKey) apiKeyResponse {
+	res := apiKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		KeyID:     key.KeyID,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt.Time,
+	}
+
+	if key.ExpiresAt.Valid {
+		res.ExpiresAt = &key.ExpiresAt.Time
+	}
+
+	if key.LastUsedAt.Valid {
+		res.LastUsedAt = &key.LastUsedAt.Time
+	}
+
+	if key.RevokedAt.Valid {
+		res.RevokedAt = &key.RevokedAt.Time
+	}
+
+	return res
+}
+
+// authenticateAPIKey returns the service account of the API key, which is
+// rejected with errInvalidAPIKey when it is unknown, revoked or expired, or
+// when its service account is disabled. The permissions of the account are
+// narrowed to the scopes of the key, when it has any. Keys are only valid for
+// the organization of their service account.
+func (app *application) authenticateAPIKey(r *http.Request, keyID, key string) (*serviceAccountPrincipal, error) {
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	row, err := app.store.GetAPIKeyByKeyID(ctx, database.GetAPIKeyByKeyIDParams{
+		KeyID:          keyID,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errInvalidAPIKey
+		}
+
+		return nil, err
+	}
+
+	if !apikey.Matches(key, row.HashedKey) {
+		return nil, errInvalidAPIKey
+	}
+
+	if row.RevokedAt.Valid || row.ServiceAccountDisabledAt.Valid {
+		return nil, errInvalidAPIKey
+	}
+
+	if row.ExpiresAt.Valid && !time.Now().UTC().Before(row.ExpiresAt.Time) {
+		return nil, errInvalidAPIKey
+	}
+
+	permissions, err := app.store.GetPermissionsForServiceAccount(ctx, database.GetPermissionsForServiceAccountParams{
+		ServiceAccountID: row.ServiceAccountID,
+		OrganizationID:   row.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(row.Scopes) > 0 {
+		permissions = slices.DeleteFunc(permissions, func(permission string) bool {
+			return !validator.In(permission, row.Scopes...)
+		})
+	}
+
+	err = app.store.TouchAPIKey(ctx, database.TouchAPIKeyParams{
+		ID:             row.ID,
+		OrganizationID: row.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &serviceAccountPrincipal{
+		ID:          row.ServiceAccountID,
+		KeyID:       row.ID,
+		Permissions: permissions,
+	}, nil
+}
+
+// checkServiceAccountPermissions validates the permissions granted to a
+// service account. The admin permission can't be granted, so that API keys
+// can't manage employees, roles or other service accounts.
+func (app *application) checkServiceAccountPermissions(r *http.Request, v *validator.Validator, permissions []string) error {
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	existing, err := app.store.GetPermissions(ctx)
+	if err != nil {
+		return err
+	}
+
+	v.CheckField(validator.AllIn(permissions, existing...), "Permissions", "permission_invalid", "Invalid permission")
+	v.CheckField(!validator.In("admin", permissions...), "Permissions", "permission_not_allowed", "Service accounts can't be granted the admin permission")
+	v.CheckField(validator.NoDuplicates(permissions), "Permissions", "permissions_duplicated", "Permissions must not contain duplicates")
+
+	return nil
+}
+
+func (app *application) listServiceAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	accounts, err := app.store.GetServiceAccounts(ctx, contextGetOrganization(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := []serviceAccountResponse{}
+	for _, account := range accounts {
+		permissions, err := app.store.GetPermissionsForServiceAccount(ctx, database.GetPermissionsForServiceAccountParams{
+			ServiceAccountID: account.ID,
+			OrganizationID:   account.OrganizationID,
+		})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data = append(data, newServiceAccountResponse(account, permissions))
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"serviceAccounts": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string              `json:"name"`
+		Description string              `json:"description"`
+		Permissions []string            `json:"permissions"`
+		Validator   validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Validator.CheckField(validator.NotBlank(input.Name), "Name", "name_required", "Name is required")
+	input.Validator.CheckField(validator.MaxRunes(input.Name, 100), "Name", "name_too_long", "Name must not be more than 100 characters long")
+
+	err = app.checkServiceAccountPermissions(r, &input.Validator, input.Permissions)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	var account database.ServiceAccount
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		account, err = q.CreateServiceAccount(ctx, database.CreateServiceAccountParams{
+			Name:           input.Name,
+			Description:    input.Description,
+			CreatedBy:      contextGetAuthenticatedUser(r).ID,
+			OrganizationID: contextGetOrganization(r).ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.AddServiceAccountPermissions(ctx, database.AddServiceAccountPermissionsParams{
+			ServiceAccountID: account.ID,
+			OrganizationID:   account.OrganizationID,
+			Permissions:      input.Permissions,
+		})
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			input.Validator.AddFieldError("Name", "name_taken", "Name is already in use")
+			app.failedValidation(w, r, input.Validator)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	slices.Sort(input.Permissions)
+
+	err = response.JSON(w, http.StatusCreated, map[string]any{"serviceAccount": newServiceAccountResponse(account, input.Permissions)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) showServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	account, err := app.store.GetServiceAccountByID(ctx, database.GetServiceAccountByIDParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	permissions, err := app.store.GetPermissionsForServiceAccount(ctx, database.GetPermissionsForServiceAccountParams{
+		ServiceAccountID: account.ID,
+		OrganizationID:   account.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"serviceAccount": newServiceAccountResponse(account, permissions)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) updateServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	account, err := app.store.GetServiceAccountByID(ctx, database.GetServiceAccountByIDParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name        *string             `json:"name"`
+		Description *string             `json:"description"`
+		Permissions []string            `json:"permissions"`
+		Active      *bool               `json:"active"`
+		Validator   validator.Validator `json:"-"`
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	params := database.UpdateServiceAccountParams{
+		ID:             account.ID,
+		Name:           account.Name,
+		Description:    account.Description,
+		Active:         !account.DisabledAt.Valid,
+		OrganizationID: account.OrganizationID,
+	}
+
+	if input.Name != nil {
+		params.Name = *input.Name
+	}
+
+	if input.Description != nil {
+		params.Description = *input.Description
+	}
+
+	if input.Active != nil {
+		params.Active = *input.Active
+	}
+
+	input.Validator.CheckField(validator.NotBlank(params.Name), "Name", "name_required", "Name is required")
+	input.Validator.CheckField(validator.MaxRunes(params.Name, 100), "Name", "name_too_long", "Name must not be more than 100 characters long")
+
+	if input.Permissions != nil {
+		err = app.checkServiceAccountPermissions(r, &input.Validator, input.Permissions)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	var permissions []string
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		account, err = q.UpdateServiceAccount(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		if input.Permissions != nil {
+			err = q.DeleteServiceAccountPermissions(ctx, database.DeleteServiceAccountPermissionsParams{
+				ServiceAccountID: account.ID,
+				OrganizationID:   account.OrganizationID,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = q.AddServiceAccountPermissions(ctx, database.AddServiceAccountPermissionsParams{
+				ServiceAccountID: account.ID,
+				OrganizationID:   account.OrganizationID,
+				Permissions:      input.Permissions,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		permissions, err = q.GetPermissionsForServiceAccount(ctx, database.GetPermissionsForServiceAccountParams{
+			ServiceAccountID: account.ID,
+			OrganizationID:   account.OrganizationID,
+		})
+		return err
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			input.Validator.AddFieldError("Name", "name_taken", "Name is already in use")
+			app.failedValidation(w, r, input.Validator)
+			return
+		}
+
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"serviceAccount": newServiceAccountResponse(account, permissions)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	rows, err := app.store.DeleteServiceAccount(ctx, database.DeleteServiceAccountParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if rows == 0 {
+		app.notFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	_, err = app.store.GetServiceAccountByID(ctx, database.GetServiceAccountByIDParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	keys, err := app.store.GetAPIKeysForServiceAccount(ctx, database.GetAPIKeysForServiceAccountParams{
+		ServiceAccountID: id,
+		OrganizationID:   contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := []apiKeyResponse{}
+	for _, key := range keys {
+		data = append(data, newAPIKeyResponse(key))
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"apiKeys": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	account, err := app.store.GetServiceAccountByID(ctx, database.GetServiceAccountByIDParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name      string              `json:"name"`
+		Scopes    []string            `json:"scopes"`
+		ExpiresAt *time.Time          `json:"expiresAt"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	permissions, err := app.store.GetPermissionsForServiceAccount(ctx, database.GetPermissionsForServiceAccountParams{
+		ServiceAccountID: account.ID,
+		OrganizationID:   account.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	input.Validator.CheckField(validator.NotBlank(input.Name), "Name", "name_required", "Name is required")
+	input.Validator.CheckField(validator.MaxRunes(input.Name, 100), "Name", "name_too_long", "Name must not be more than 100 characters long")
+	input.Validator.CheckField(validator.AllIn(input.Scopes, permissions...), "Scopes", "scope_invalid", "Scopes must be permissions of the service account")
+	input.Validator.CheckField(validator.NoDuplicates(input.Scopes), "Scopes", "scopes_duplicated", "Scopes must not contain duplicates")
+	input.Validator.CheckField(input.ExpiresAt == nil || input.ExpiresAt.After(time.Now()), "ExpiresAt", "expires_at_invalid", "Must be in the future")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	generated, err := apikey.New()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	params := database.CreateAPIKeyParams{
+		ServiceAccountID: account.ID,
+		Name:             input.Name,
+		KeyID:            generated.ID,
+		HashedKey:        generated.Hash,
+		Scopes:           input.Scopes,
+		OrganizationID:   account.OrganizationID,
+	}
+
+	if params.Scopes == nil {
+		params.Scopes = []string{}
+	}
+
+	if input.ExpiresAt != nil {
+		params.ExpiresAt = pgtype.Timestamp{Time: input.ExpiresAt.UTC(), Valid: true}
+	}
+
+	key, err := app.store.CreateAPIKey(ctx, params)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The key is only disclosed once, when it is created.
+	data := newAPIKeyResponse(key)
+	data.Key = generated.Key
+
+	err = response.JSON(w, http.StatusCreated, map[string]any{"apiKey": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	keyID, err := readUUIDParam(r, "keyId")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	rows, err := app.store.RevokeAPIKey(ctx, database.RevokeAPIKeyParams{
+		ID:               keyID,
+		ServiceAccountID: id,
+		OrganizationID:   contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if rows == 0 {
+		app.notFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/sso"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pascaldekloe/jwt"
+)
+
+const (
+	// ssoCookieName is the cookie keeping the authentication request while
+	// the employee signs in with the provider.
+	ssoCookieName = "uai_sso"
+	ssoCookiePath = "/api/v1/sso"
+
+	// ssoRequestAudience is appended to the base URL to make the audience of
+	// the tokens held by the SSO cookie, so they can't be used as
+	// authentication tokens.
+	ssoRequestAudience = "/sso"
+	ssoRequestExpiry   = 10 * time.Minute
+)
+
+// ssoLoginHandler starts a sign-in with the OpenID Connect provider, keeping
+// the state, nonce and PKCE verifier of the request in a signed cookie and
+// redirecting the employee to the provider.
+func (app *application) ssoLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if app.sso == nil {
+		app.notFound(w, r)
+		return
+	}
+
+	req, err := sso.NewAuthRequest()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var claims jwt.Claims
+
+	expiry := time.Now().Add(ssoRequestExpiry)
+	claims.Issued = jwt.NewNumericTime(time.Now())
+	claims.NotBefore = jwt.NewNumericTime(time.Now())
+	claims.Expires = jwt.NewNumericTime(expiry)
+
+	claims.Issuer = app.config.baseURL
+	claims.Audiences = []string{app.config.baseURL + ssoRequestAudience}
+
+	claims.Set = map[string]any{
+		"state":           req.State,
+		"nonce":           req.Nonce,
+		"verifier":        req.Verifier,
+		organizationClaim: contextGetOrganization(r).ID.String(),
+	}
+
+	jwtBytes, err := claims.HMACSign(jwt.HS256, []byte(app.config.jwt.secretKey))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 10*time.Second)
+	defer cancel()
+
+	url, err := app.sso.AuthCodeURL(ctx, req)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, app.ssoCookie(string(jwtBytes), int(ssoRequestExpiry.Seconds())))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// ssoCallbackHandler completes a sign-in with the OpenID Connect provider. The
+// employee is found by the email of the ID token, or created when
+// just-in-time provisioning is enabled, and gets an authentication token, or a
+// two-factor challenge when they enabled it and the provider didn't report a
+// second factor.
+func (app *application) ssoCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if app.sso == nil {
+		app.notFound(w, r)
+		return
+	}
+
+	http.SetCookie(w, app.ssoCookie("", -1))
+
+	query := r.URL.Query()
+
+	if query.Get("error") != "" {
+		app.ssoFailed(w, r, errors.New("sso: provider returned "+query.Get("error")))
+		return
+	}
+
+	cookie, err := r.Cookie(ssoCookieName)
+	if err != nil {
+		app.ssoFailed(w, r, err)
+		return
+	}
+
+	req, claims, ok := app.parseSSORequest(cookie.Value)
+	if !ok {
+		app.ssoFailed(w, r, errors.New("sso: invalid or expired request cookie"))
+		return
+	}
+
+	// The sign-in completes in the organization it was started from.
+	r, err = app.contextSetTokenOrganization(r, claims)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnknownOrganization):
+			app.ssoFailed(w, r, err)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 10*time.Second)
+	defer cancel()
+
+	identity, err := app.sso.Exchange(ctx, req, query.Get("state"), query.Get("code"))
+	if err != nil {
+		app.ssoFailed(w, r, err)
+		return
+	}
+
+	organizationID := contextGetOrganization(r).ID
+
+	employee, err := app.store.GetUserByEmail(ctx, database.GetUserByEmailParams{
+		Email:          identity.Email,
+		OrganizationID: organizationID,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if employee.Email == "" {
+		if !app.config.sso.provisioning {
+			app.ssoAccountNotFound(w, r)
+			return
+		}
+
+		// Deleted employees, left out above, can't sign in until they're
+		// restored, nor be provisioned again while they keep their email
+		// address.
+		deleted, err := app.store.UserEmailExists(ctx, database.UserEmailExistsParams{
+			Email:          identity.Email,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if deleted {
+			app.ssoAccountNotFound(w, r)
+			return
+		}
+
+		var deliveries []database.WebhookDelivery
+
+		employee, deliveries, err = app.provisionEmployee(ctx, identity, organizationID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.deliverWebhooks(r, deliveries)
+	}
+
+	// Deactivated and terminated employees keep their account at the
+	// provider, which doesn't know the status of the employee.
+	if employee.Status != employeeStatusActive {
+		app.accountInactive(w, r)
+		return
+	}
+
+	methods := []string{authMethodFederated}
+	if validator.In(authMethodMFA, identity.AuthMethods...) || validator.In(authMethodOTP, identity.AuthMethods...) {
+		methods = append(methods, authMethodMFA)
+	}
+
+	enabled, err := app.twoFactorEnabled(ctx, employee)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if enabled && !validator.In(authMethodMFA, methods...) {
+		app.writeTwoFactorChallenge(w, r, employee, methods...)
+		return
+	}
+
+	err = app.recordSuccessfulLogin(ctx, r, employee)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeAuthenticationToken(w, r, employee, methods...)
+}
+
+// provisionEmployee creates an active employee with the default role for a
+// user the provider signed in, on their first sign-in. It returns the webhook
+// deliveries of the employee created event, to make once it's done.
+func (app *application) provisionEmployee(ctx context.Context, identity sso.Identity, organizationID uuid.UUID) (database.User, []database.WebhookDelivery, error) {
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	var (
+		employee   database.User
+		deliveries []database.WebhookDelivery
+	)
+
+	err := app.store.ExecTx(ctx, func(q *database.Queries) error {
+		var err error
+
+		employee, err = q.CreateUser(ctx, database.CreateUserParams{
+			Name:           name,
+			Email:          identity.Email,
+			Status:         "active",
+			HashedPassword: pgtype.Text{},
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		deliveries, err = publishEvent(ctx, q, organizationID, eventEmployeeCreated, employeeEventData(employee))
+		if err != nil {
+			return err
+		}
+
+		if app.config.sso.defaultRole == "" {
+			return nil
+		}
+
+		role, err := q.GetRoleByDisplayName(ctx, database.GetRoleByDisplayNameParams{
+			DisplayName:    app.config.sso.defaultRole,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		grantor, err := systemGrantor(ctx, q, organizationID)
+		if err != nil {
+			return err
+		}
+
+		return q.AddRoleMembers(ctx, database.AddRoleMembersParams{
+			RoleID:         role.ID,
+			Grantor:        grantor,
+			OrganizationID: organizationID,
+			UserIds:        []uuid.UUID{employee.ID},
+		})
+	})
+
+	return employee, deliveries, err
+}
+
+// parseSSORequest returns the authentication request kept in a token made by
+// ssoLoginHandler, and the claims holding the organization it was made for.
+func (app *application) parseSSORequest(token string) (sso.AuthRequest, map[string]any, bool) {
+	claims, err := jwt.HMACCheck([]byte(token), []byte(app.config.jwt.secretKey))
+	if err != nil {
+		return sso.AuthRequest{}, nil, false
+	}
+
+	if !claims.Valid(time.Now()) || claims.Issuer != app.config.baseURL || !claims.AcceptAudience(app.config.baseURL+ssoRequestAudience) {
+		return sso.AuthRequest{}, nil, false
+	}
+
+	state, _ := claims.String("state")
+	nonce, _ := claims.String("nonce")
+	verifier, _ := claims.String("verifier")
+
+	if state == "" || nonce == "" || verifier == "" {
+		return sso.AuthRequest{}, nil, false
+	}
+
+	return sso.AuthRequest{State: state, Nonce: nonce, Verifier: verifier}, claims.Set, true
+}
+
+// ssoCookie returns the SSO cookie with the given value, deleted when maxAge is
+// negative.
+func (app *application) ssoCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     ssoCookieName,
+		Value:    value,
+		Path:     ssoCookiePath,
+		MaxAge:   maxAge,
+		Secure:   strings.HasPrefix(app.config.baseURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/sso"
+	"github.com/google/uuid"
+	"github.com/pascaldekloe/jwt"
+)
+
+const (
+	testSSOClientID     = "uai"
+	testSSOClientSecret = "s3cr3t"
+	testSSORedirectURL  = "http://localhost:4000/api/v1/sso/callback"
+)
+
+// testIssuer is an OpenID Connect provider signing in a single user with the
+// authorization code flow. The ID tokens it issues hold email, and
+// email_verified unless it is nil.
+type testIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	email         string
+	emailVerified *bool
+
+	// nonce replaces the nonce of the authentication request in the ID
+	// tokens when it isn't empty.
+	nonce string
+
+	mu             sync.Mutex
+	authorizations map[string]url.Values
+}
+
+func newTestIssuer(t *testing.T, email string) *testIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified := true
+
+	issuer := &testIssuer{
+		t:              t,
+		key:            key,
+		email:          email,
+		emailVerified:  &verified,
+		authorizations: map[string]url.Values{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("GET /keys", issuer.keys)
+	mux.HandleFunc("GET /authorize", issuer.authorize)
+	mux.HandleFunc("POST /token", issuer.token)
+
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+func (i *testIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                i.server.URL,
+		"authorization_endpoint":                i.server.URL + "/authorize",
+		"token_endpoint":                        i.server.URL + "/token",
+		"jwks_uri":                              i.server.URL + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (i *testIssuer) keys(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize signs the user in right away, redirecting them back with a code
+// bound to the authentication request.
+func (i *testIssuer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		i.t.Errorf("got code challenge %q with method %q; want an S256 challenge", query.Get("code_challenge"), query.Get("code_challenge_method"))
+	}
+
+	if query.Get("state") == "" || query.Get("nonce") == "" {
+		i.t.Errorf("got state %q and nonce %q; want both", query.Get("state"), query.Get("nonce"))
+	}
+
+	code := uuid.NewString()
+
+	i.mu.Lock()
+	i.authorizations[code] = query
+	i.mu.Unlock()
+
+	redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// token trades a code for an ID token, when the PKCE verifier matches the
+// challenge the code was issued for.
+func (i *testIssuer) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	if clientID == "" {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+
+	i.mu.Lock()
+	authorization, ok := i.authorizations[r.PostFormValue("code")]
+	delete(i.authorizations, r.PostFormValue("code"))
+	i.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	if !ok || clientID != testSSOClientID || clientSecret != testSSOClientSecret || challenge != authorization.Get("code_challenge") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	var claims jwt.Claims
+
+	claims.Issuer = i.server.URL
+	claims.Subject = "ada"
+	claims.Audiences = []string{testSSOClientID}
+	claims.Issued = jwt.NewNumericTime(time.Now())
+	claims.Expires = jwt.NewNumericTime(time.Now().Add(time.Minute))
+
+	nonce := authorization.Get("nonce")
+	if i.nonce != "" {
+		nonce = i.nonce
+	}
+
+	claims.Set = map[string]any{"nonce": nonce, "email": i.email, "name": "Ada Lovelace"}
+	if i.emailVerified != nil {
+		claims.Set["email_verified"] = *i.emailVerified
+	}
+
+	idToken, err := claims.RSASign(jwt.RS256, i.key)
+	if err != nil {
+		i.t.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     string(idToken),
+	})
+}
+
+// testSSOLogin starts a sign-in, and returns the cookie it set and the
+// redirection of the issuer back to the callback.
+func testSSOLogin(t *testing.T, app *application, store *testStore) (*http.Cookie, *url.URL) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	app.ssoLoginHandler(rr, newTestRequest(store, http.MethodGet, "/api/v1/sso/login", nil))
+
+	if rr.Code != http.StatusFound {
+		t.Fatalf("login: got status %d; want %d", rr.Code, http.StatusFound)
+	}
+
+	var cookie *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == ssoCookieName {
+			cookie = c
+		}
+	}
+
+	if cookie == nil {
+		t.Fatal("login: no SSO cookie set")
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	callback, err := res.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cookie, callback
+}
+
+// testSSOCallback completes a sign-in with the cookie and the query of the
+// redirection back to the callback.
+func testSSOCallback(app *application, store *testStore, cookie *http.Cookie, query url.Values) *httptest.ResponseRecorder {
+	r := newTestRequest(store, http.MethodGet, "/api/v1/sso/callback?"+query.Encode(), nil)
+	r.AddCookie(cookie)
+
+	rr := httptest.NewRecorder()
+	app.ssoCallbackHandler(rr, r)
+
+	return rr
+}
+
+func newTestSSOApplication(t *testing.T, email string) (*application, *testStore, *testIssuer) {
+	t.Helper()
+
+	app, store := newTestApplication(t)
+	issuer := newTestIssuer(t, email)
+
+	app.sso = sso.NewProvider(sso.Config{
+		IssuerURL:    issuer.server.URL,
+		ClientID:     testSSOClientID,
+		ClientSecret: testSSOClientSecret,
+		RedirectURL:  testSSORedirectURL,
+	})
+
+	return app, store, issuer
+}
+
+func TestSSOProvisionsEmployees(t *testing.T) {
+	app, store, _ := newTestSSOApplication(t, "Ada.Lovelace@Example.com")
+
+	admin := newTestEmployee(store, "Root", "root@example.com")
+	store.admin = admin.ID
+
+	role := database.Role{ID: uuid.New(), DisplayName: "employee", OrganizationID: store.organization.ID}
+	store.roles = append(store.roles, role)
+
+	app.config.sso.provisioning = true
+	app.config.sso.defaultRole = role.DisplayName
+
+	cookie, callback := testSSOLogin(t, app, store)
+
+	rr := testSSOCallback(app, store, cookie, callback.Query())
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "authenticationToken") {
+		t.Fatalf("got status %d and body %s; want %d with a token", rr.Code, rr.Body, http.StatusOK)
+	}
+
+	employee, err := store.GetUserByEmail(context.Background(), database.GetUserByEmailParams{
+		Email:          "ada.lovelace@example.com",
+		OrganizationID: store.organization.ID,
+	})
+	if err != nil {
+		t.Fatalf("employee not provisioned: %v", err)
+	}
+
+	if employee.Email != "ada.lovelace@example.com" || employee.Status != employeeStatusActive {
+		t.Errorf("got email %q and status %q; want the lower-cased email and %q", employee.Email, employee.Status, employeeStatusActive)
+	}
+
+	if len(store.grants) != 1 {
+		t.Fatalf("got %d grants; want 1", len(store.grants))
+	}
+
+	grant := store.grants[0]
+	if grant.UserID != employee.ID || grant.RoleID != role.ID || grant.Grantor != admin.ID {
+		t.Errorf("got grant %+v; want %s granted to the employee by %s", grant, role.DisplayName, admin.Name)
+	}
+
+	if len(store.events) != 1 || store.events[0].Type != eventEmployeeCreated {
+		t.Errorf("got events %v; want a single %s event", store.events, eventEmployeeCreated)
+	}
+
+	// Signing in again finds the employee, whatever the case of the email.
+	cookie, callback = testSSOLogin(t, app, store)
+
+	rr = testSSOCallback(app, store, cookie, callback.Query())
+	if rr.Code != http.StatusOK {
+		t.Fatalf("signing in again: got status %d and body %s; want %d", rr.Code, rr.Body, http.StatusOK)
+	}
+
+	if len(store.employees) != 2 {
+		t.Errorf("got %d employees; want 2", len(store.employees))
+	}
+}
+
+func TestSSOMatchesEmailRegardlessOfCase(t *testing.T) {
+	app, store, _ := newTestSSOApplication(t, "GRACE@example.com")
+
+	newTestEmployee(store, "Grace Hopper", "grace@Example.com")
+
+	cookie, callback := testSSOLogin(t, app, store)
+
+	rr := testSSOCallback(app, store, cookie, callback.Query())
+	if rr.Code != http.StatusOK {
+		t.Errorf("got status %d and body %s; want %d", rr.Code, rr.Body, http.StatusOK)
+	}
+}
+
+func TestSSOCallbackFailures(t *testing.T) {
+	unverified := false
+
+	tests := []struct {
+		name string
+		// setup changes the issuer and returns the cookie and query of the
+		// callback.
+		setup      func(t *testing.T, app *application, store *testStore, issuer *testIssuer) (*http.Cookie, url.Values)
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name: "state mismatch",
+			setup: func(t *testing.T, app *application, store *testStore, issuer *testIssuer) (*http.Cookie, url.Values) {
+				cookie, callback := testSSOLogin(t, app, store)
+
+				query := callback.Query()
+				query.Set("state", "forged")
+
+				return cookie, query
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "sso_failed",
+		},
+		{
+			// A code issued for another request, as injected by an
+			// attacker, fails the PKCE check of the provider.
+			name: "code of another request",
+			setup: func(t *testing.T, app *application, store *testStore, issuer *testIssuer) (*http.Cookie, url.Values) {
+				_, stolen := testSSOLogin(t, app, store)
+				cookie, callback := testSSOLogin(t, app, store)
+
+				query := callback.Query()
+				query.Set("code", stolen.Query().Get("code"))
+
+				return cookie, query
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "sso_failed",
+		},
+		{
+			name: "nonce mismatch",
+			setup: func(t *testing.T, app *application, store *testStore, issuer *testIssuer) (*http.Cookie, url.Values) {
+				issuer.nonce = "replayed"
+
+				cookie, callback := testSSOLogin(t, app, store)
+
+				return cookie, callback.Query()
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "sso_failed",
+		},
+		{
+			name: "unverified email",
+			setup: func(t *testing.T, app *application, store *testStore, issuer *testIssuer) (*http.Cookie, url.Values) {
+				issuer.emailVerified = &unverified
+
+				cookie, callback := testSSOLogin(t, app, store)
+
+				return cookie, callback.Query()
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "sso_failed",
+		},
+		{
+			name: "no email_verified claim",
+			setup: func(t *testing.T, app *application, store *testStore, issuer *testIssuer) (*http.Cookie, url.Values) {
+				issuer.emailVerified = nil
+
+				cookie, callback := testSSOLogin(t, app, store)
+
+				return cookie, callback.Query()
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "sso_failed",
+		},
+		{
+			name: "provisioning disabled",
+			setup: func(t *testing.T, app *application, store *testStore, issuer *testIssuer) (*http.Cookie, url.Values) {
+				issuer.email = "unknown@example.com"
+
+				cookie, callback := testSSOLogin(t, app, store)
+
+				return cookie, callback.Query()
+			},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "sso_account_not_found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, store, issuer := newTestSSOApplication(t, "ada@example.com")
+
+			newTestEmployee(store, "Ada Lovelace", "ada@example.com")
+
+			cookie, query := tt.setup(t, app, store, issuer)
+
+			rr := testSSOCallback(app, store, cookie, query)
+			if rr.Code != tt.wantStatus || !strings.Contains(rr.Body.String(), tt.wantCode) {
+				t.Errorf("got status %d and body %s; want %d with %s", rr.Code, rr.Body, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,959 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brGuirra/uai/internal/apikey"
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/smtp"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// testStore is a database.Store holding the employees of a single
+// organization in memory. The queries it doesn't implement panic, through the
+// nil embedded Store. Transactions run their queries with testDB, and aren't
+// rolled back when they fail.
+type testStore struct {
+	database.Store
+	organization database.Organization
+	employees    map[uuid.UUID]database.User
+
+	// otherOrganizations are the organizations other than the one of the
+	// requests.
+	otherOrganizations []database.Organization
+
+	// ldapAccounts maps the employees synchronized from the directory to the
+	// DN of their directory user.
+	ldapAccounts map[uuid.UUID]string
+
+	// events are the events published, in order.
+	events []database.Event
+
+	// roles are the roles of the organization, and grants the roles held by
+	// its employees.
+	roles  []database.Role
+	grants []database.UsersRole
+
+	// admin is the employee holding the admin permission, recorded as the
+	// grantor of the roles no employee grants.
+	admin uuid.UUID
+
+	// offboardings are keyed by employee, and the ones in locked are being
+	// completed by another instance. revokedRoles are the archived grants.
+	offboardings map[uuid.UUID]database.Offboarding
+	locked       map[uuid.UUID]bool
+	revokedRoles []database.UsersRole
+
+	// erasures are the employees whose personal data was erased.
+	erasures []database.Erasure
+
+	// transactions are the names of the queries run by each transaction.
+	transactions [][]string
+}
+
+func (s *testStore) ExecTx(ctx context.Context, fn func(*database.Queries) error) error {
+	s.transactions = append(s.transactions, nil)
+
+	return fn(database.New(&testDB{store: s, tx: len(s.transactions) - 1}))
+}
+
+func (s *testStore) DeactivateMissingLDAPUsers(ctx context.Context, arg database.DeactivateMissingLDAPUsersParams) ([]database.User, error) {
+	var deactivated []database.User
+
+	for id, dn := range s.ldapAccounts {
+		employee := s.employees[id]
+		if employee.Status != employeeStatusActive || slices.Contains(arg.Dns, dn) {
+			continue
+		}
+
+		employee.Status = employeeStatusDeactivated
+		s.employees[id] = employee
+
+		deactivated = append(deactivated, database.User(employee))
+	}
+
+	return deactivated, nil
+}
+
+func (s *testStore) CountUnusedRecoveryCodes(ctx context.Context, arg database.CountUnusedRecoveryCodesParams) (int64, error) {
+	return 0, nil
+}
+
+func (s *testStore) EmployeeRequiresTwoFactor(ctx context.Context, arg database.EmployeeRequiresTwoFactorParams) (bool, error) {
+	return false, nil
+}
+
+func (s *testStore) GetAccountLockout(ctx context.Context, arg database.GetAccountLockoutParams) (database.AccountLockout, error) {
+	return database.AccountLockout{}, pgx.ErrNoRows
+}
+
+func (s *testStore) GetAccountLockRemaining(ctx context.Context, arg database.GetAccountLockRemainingParams) (float64, error) {
+	return 0, pgx.ErrNoRows
+}
+
+func (s *testStore) GetOrganization(ctx context.Context, id uuid.UUID) (database.Organization, error) {
+	if id != s.organization.ID {
+		return database.Organization{}, pgx.ErrNoRows
+	}
+
+	return s.organization, nil
+}
+
+func (s *testStore) GetOrganizationBySlug(ctx context.Context, slug string) (database.Organization, error) {
+	for _, organization := range append([]database.Organization{s.organization}, s.otherOrganizations...) {
+		if organization.Slug == slug {
+			return organization, nil
+		}
+	}
+
+	return database.Organization{}, pgx.ErrNoRows
+}
+
+func (s *testStore) GetOrganizationBySCIMTokenID(ctx context.Context, scimTokenID pgtype.Text) (database.Organization, error) {
+	for _, organization := range append([]database.Organization{s.organization}, s.otherOrganizations...) {
+		if organization.ScimTokenID == scimTokenID {
+			return organization, nil
+		}
+	}
+
+	return database.Organization{}, pgx.ErrNoRows
+}
+
+func (s *testStore) GetErasure(ctx context.Context, arg database.GetErasureParams) (database.Erasure, error) {
+	for _, erasure := range s.erasures {
+		if erasure.UserID == arg.UserID && erasure.OrganizationID == arg.OrganizationID {
+			return erasure, nil
+		}
+	}
+
+	return database.Erasure{}, pgx.ErrNoRows
+}
+
+// GetEventsForEmployee returns the events whose data holds the ID of the
+// employee.
+func (s *testStore) GetEventsForEmployee(ctx context.Context, arg database.GetEventsForEmployeeParams) ([]database.Event, error) {
+	var events []database.Event
+
+	for _, evt := range s.events {
+		var data struct {
+			ID string `json:"id"`
+		}
+
+		err := json.Unmarshal(evt.Data, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		if data.ID == arg.EmployeeID && evt.OrganizationID == arg.OrganizationID {
+			events = append(events, evt)
+		}
+	}
+
+	return events, nil
+}
+
+func (s *testStore) GetImpersonationSessionsForEmployee(ctx context.Context, arg database.GetImpersonationSessionsForEmployeeParams) ([]database.ImpersonationSession, error) {
+	return nil, nil
+}
+
+func (s *testStore) GetKnownLoginsForUser(ctx context.Context, arg database.GetKnownLoginsForUserParams) ([]database.KnownLogin, error) {
+	return nil, nil
+}
+
+func (s *testStore) GetLDAPAccount(ctx context.Context, arg database.GetLDAPAccountParams) (database.LdapAccount, error) {
+	dn, ok := s.ldapAccounts[arg.UserID]
+	if !ok {
+		return database.LdapAccount{}, pgx.ErrNoRows
+	}
+
+	return database.LdapAccount{UserID: arg.UserID, Dn: dn, OrganizationID: arg.OrganizationID}, nil
+}
+
+func (s *testStore) GetOffboarding(ctx context.Context, arg database.GetOffboardingParams) (database.Offboarding, error) {
+	offboarding, ok := s.offboardings[arg.UserID]
+	if !ok || offboarding.OrganizationID != arg.OrganizationID {
+		return database.Offboarding{}, pgx.ErrNoRows
+	}
+
+	return offboarding, nil
+}
+
+// GetPermissionsForEmployee gives the admin permission to the admin of the
+// store, and none to the other employees.
+func (s *testStore) GetPermissionsForEmployee(ctx context.Context, arg database.GetPermissionsForEmployeeParams) ([]string, error) {
+	if arg.UserID != s.admin || arg.OrganizationID != s.organization.ID {
+		return nil, nil
+	}
+
+	return []string{"admin"}, nil
+}
+
+func (s *testStore) GetRevokedRolesForUser(ctx context.Context, arg database.GetRevokedRolesForUserParams) ([]database.GetRevokedRolesForUserRow, error) {
+	var rows []database.GetRevokedRolesForUserRow
+
+	for _, grant := range s.revokedRoles {
+		if grant.UserID != arg.UserID || grant.OrganizationID != arg.OrganizationID {
+			continue
+		}
+
+		rows = append(rows, database.GetRevokedRolesForUserRow{
+			ID:          grant.RoleID,
+			DisplayName: s.roleName(grant.RoleID),
+			Grantor:     grant.Grantor,
+			GrantedAt:   grant.GrantedAt,
+			RevokedAt:   pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+		})
+	}
+
+	return rows, nil
+}
+
+func (s *testStore) GetRoleGrantsByGrantor(ctx context.Context, arg database.GetRoleGrantsByGrantorParams) ([]database.GetRoleGrantsByGrantorRow, error) {
+	var rows []database.GetRoleGrantsByGrantorRow
+
+	for _, grant := range s.grants {
+		if grant.Grantor == arg.Grantor && grant.OrganizationID == arg.OrganizationID {
+			rows = append(rows, database.GetRoleGrantsByGrantorRow{UserID: grant.UserID, RoleID: grant.RoleID, GrantedAt: grant.GrantedAt})
+		}
+	}
+
+	return rows, nil
+}
+
+func (s *testStore) GetRoleGrantsForUser(ctx context.Context, arg database.GetRoleGrantsForUserParams) ([]database.GetRoleGrantsForUserRow, error) {
+	var rows []database.GetRoleGrantsForUserRow
+
+	for _, grant := range s.grants {
+		if grant.UserID != arg.UserID || grant.OrganizationID != arg.OrganizationID {
+			continue
+		}
+
+		rows = append(rows, database.GetRoleGrantsForUserRow{
+			ID:          grant.RoleID,
+			DisplayName: s.roleName(grant.RoleID),
+			Grantor:     grant.Grantor,
+			GrantedAt:   grant.GrantedAt,
+		})
+	}
+
+	return rows, nil
+}
+
+func (s *testStore) GetServiceAccountsCreatedBy(ctx context.Context, arg database.GetServiceAccountsCreatedByParams) ([]database.ServiceAccount, error) {
+	return nil, nil
+}
+
+func (s *testStore) GetUser(ctx context.Context, arg database.GetUserParams) (database.User, error) {
+	employee, ok := s.employees[arg.ID]
+	if !ok || employee.OrganizationID != arg.OrganizationID || employee.DeletedAt.Valid {
+		return database.User{}, pgx.ErrNoRows
+	}
+
+	return employee, nil
+}
+
+func (s *testStore) GetUserByEmail(ctx context.Context, arg database.GetUserByEmailParams) (database.User, error) {
+	for _, employee := range s.employees {
+		if strings.EqualFold(employee.Email, arg.Email) && employee.OrganizationID == arg.OrganizationID && !employee.DeletedAt.Valid {
+			return employee, nil
+		}
+	}
+
+	return database.User{}, pgx.ErrNoRows
+}
+
+func (s *testStore) UserEmailExists(ctx context.Context, arg database.UserEmailExistsParams) (bool, error) {
+	for _, employee := range s.employees {
+		if strings.EqualFold(employee.Email, arg.Email) && employee.OrganizationID == arg.OrganizationID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *testStore) ListPurgeableUsers(ctx context.Context, arg database.ListPurgeableUsersParams) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	for id, employee := range s.employees {
+		if employee.DeletedAt.Valid && employee.DeletedAt.Time.Before(arg.DeletedBefore.Time) && employee.OrganizationID == arg.OrganizationID {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *testStore) ListPurgeableRoles(ctx context.Context, arg database.ListPurgeableRolesParams) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	for _, role := range s.roles {
+		if role.DeletedAt.Valid && role.DeletedAt.Time.Before(arg.DeletedBefore.Time) && role.OrganizationID == arg.OrganizationID {
+			ids = append(ids, role.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *testStore) GetTOTPSecret(ctx context.Context, arg database.GetTOTPSecretParams) (database.TotpSecret, error) {
+	return database.TotpSecret{}, pgx.ErrNoRows
+}
+
+func (s *testStore) DeleteAccountLockout(ctx context.Context, arg database.DeleteAccountLockoutParams) (int64, error) {
+	return 0, nil
+}
+
+func (s *testStore) RecordKnownLogin(ctx context.Context, arg database.RecordKnownLoginParams) (database.RecordKnownLoginRow, error) {
+	return database.RecordKnownLoginRow{New: true}, nil
+}
+
+func (s *testStore) GetWebhooksCreatedBy(ctx context.Context, arg database.GetWebhooksCreatedByParams) ([]database.Webhook, error) {
+	return nil, nil
+}
+
+// roleName returns the display name of the role.
+func (s *testStore) roleName(id uuid.UUID) string {
+	for _, role := range s.roles {
+		if role.ID == id {
+			return role.DisplayName
+		}
+	}
+
+	return ""
+}
+
+// testQueries run the queries of transactions against a testStore, given the
+// arguments of the generated method in order. They return the rows of the
+// query: structs for the queries returning several columns.
+var testQueries = map[string]func(s *testStore, args []any) ([]any, error){
+	"AddRoleMembers": func(s *testStore, args []any) ([]any, error) {
+		var rows []any
+
+		for _, id := range args[3].([]uuid.UUID) {
+			grant := database.UsersRole{
+				UserID:         id,
+				RoleID:         args[0].(uuid.UUID),
+				Grantor:        args[1].(uuid.UUID),
+				GrantedAt:      pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+				OrganizationID: args[2].(uuid.UUID),
+			}
+
+			s.grants = append(s.grants, grant)
+			rows = append(rows, grant)
+		}
+
+		return rows, nil
+	},
+	"ArchiveRolesForUser": func(s *testStore, args []any) ([]any, error) {
+		for _, grant := range s.grants {
+			if grant.UserID == args[0].(uuid.UUID) && grant.OrganizationID == args[1].(uuid.UUID) {
+				s.revokedRoles = append(s.revokedRoles, grant)
+			}
+		}
+
+		return nil, nil
+	},
+	"CompleteOffboarding": func(s *testStore, args []any) ([]any, error) {
+		offboarding, ok := s.offboardings[args[0].(uuid.UUID)]
+		if ok {
+			offboarding.CompletedAt = pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}
+			s.offboardings[offboarding.UserID] = offboarding
+		}
+
+		return nil, nil
+	},
+	"CreateEvent": func(s *testStore, args []any) ([]any, error) {
+		evt := database.Event{
+			ID:             uuid.New(),
+			Sequence:       int64(len(s.events) + 1),
+			Type:           args[0].(string),
+			Data:           args[1].([]byte),
+			CreatedAt:      pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+			OrganizationID: args[2].(uuid.UUID),
+		}
+
+		s.events = append(s.events, evt)
+
+		return []any{evt}, nil
+	},
+	"CreateErasure": func(s *testStore, args []any) ([]any, error) {
+		erasure := database.Erasure{
+			UserID:         args[0].(uuid.UUID),
+			ErasedBy:       args[1].(uuid.UUID),
+			ErasedAt:       pgtype.Timestamp{Time: time.Now().UTC(), Valid: true},
+			OrganizationID: args[2].(uuid.UUID),
+		}
+
+		s.erasures = append(s.erasures, erasure)
+
+		return []any{erasure}, nil
+	},
+	"CreateUser": func(s *testStore, args []any) ([]any, error) {
+		employee := database.User{
+			ID:             uuid.New(),
+			Name:           args[0].(string),
+			Email:          args[1].(string),
+			Status:         args[2].(string),
+			HashedPassword: args[3].(pgtype.Text),
+			OrganizationID: args[4].(uuid.UUID),
+		}
+
+		s.employees[employee.ID] = employee
+
+		return []any{employee}, nil
+	},
+	"DeactivateMissingLDAPUsers": func(s *testStore, args []any) ([]any, error) {
+		users, err := s.DeactivateMissingLDAPUsers(context.Background(), database.DeactivateMissingLDAPUsersParams{
+			Dns:            args[0].([]string),
+			OrganizationID: args[1].(uuid.UUID),
+		})
+
+		return testRows(users), err
+	},
+	"DeleteAccountLockout": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"DeleteKnownLoginsForUser": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"DeleteLDAPAccount": func(s *testStore, args []any) ([]any, error) {
+		delete(s.ldapAccounts, args[0].(uuid.UUID))
+
+		return nil, nil
+	},
+	"DeleteRecoveryCodes": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"DeleteRoleMembers": func(s *testStore, args []any) ([]any, error) {
+		s.grants = slices.DeleteFunc(s.grants, func(grant database.UsersRole) bool {
+			return grant.RoleID == args[0].(uuid.UUID) && grant.OrganizationID == args[1].(uuid.UUID)
+		})
+
+		return nil, nil
+	},
+	"DeleteRolePermissions": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"DeleteRolesForUser": func(s *testStore, args []any) ([]any, error) {
+		s.grants = slices.DeleteFunc(s.grants, func(grant database.UsersRole) bool {
+			return grant.UserID == args[0].(uuid.UUID) && grant.OrganizationID == args[1].(uuid.UUID)
+		})
+
+		return nil, nil
+	},
+	"DeleteTOTPSecret": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"EndImpersonationSessionsForEmployee": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"GetActiveWebhooksForEvent": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"GetRoleByDisplayName": func(s *testStore, args []any) ([]any, error) {
+		for _, role := range s.roles {
+			if role.DisplayName == args[0].(string) && role.OrganizationID == args[1].(uuid.UUID) && !role.DeletedAt.Valid {
+				return []any{role}, nil
+			}
+		}
+
+		return nil, nil
+	},
+	"GetRolesForUser": func(s *testStore, args []any) ([]any, error) {
+		var rows []any
+
+		for _, grant := range s.grants {
+			if grant.UserID != args[0].(uuid.UUID) || grant.OrganizationID != args[1].(uuid.UUID) {
+				continue
+			}
+
+			for _, role := range s.roles {
+				if role.ID == grant.RoleID && !role.DeletedAt.Valid {
+					rows = append(rows, database.GetRolesForUserRow{ID: role.ID, DisplayName: role.DisplayName})
+				}
+			}
+		}
+
+		return rows, nil
+	},
+	"GetSystemGrantor": func(s *testStore, args []any) ([]any, error) {
+		if s.admin == uuid.Nil || args[0].(uuid.UUID) != s.organization.ID {
+			return nil, nil
+		}
+
+		return []any{s.admin}, nil
+	},
+	"GetUser": func(s *testStore, args []any) ([]any, error) {
+		employee, err := s.GetUser(context.Background(), database.GetUserParams{
+			ID:             args[0].(uuid.UUID),
+			OrganizationID: args[1].(uuid.UUID),
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		return []any{employee}, err
+	},
+	"LockDueOffboarding": func(s *testStore, args []any) ([]any, error) {
+		offboarding, ok := s.offboardings[args[0].(uuid.UUID)]
+		if !ok || offboarding.CompletedAt.Valid || offboarding.OrganizationID != args[1].(uuid.UUID) || s.locked[offboarding.UserID] {
+			return nil, nil
+		}
+
+		return []any{offboarding}, nil
+	},
+	"LockEvents": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"PseudonymizeEvents": func(s *testStore, args []any) ([]any, error) {
+		var rows []any
+
+		for i, evt := range s.events {
+			var data map[string]any
+
+			err := json.Unmarshal(evt.Data, &data)
+			if err != nil {
+				return nil, err
+			}
+
+			_, hasEmail := data["email"]
+			if data["id"] != args[2].(string) || !hasEmail || evt.OrganizationID != args[3].(uuid.UUID) {
+				continue
+			}
+
+			data["name"] = args[0].(string)
+			data["email"] = args[1].(string)
+
+			s.events[i].Data, err = json.Marshal(data)
+			if err != nil {
+				return nil, err
+			}
+
+			rows = append(rows, s.events[i])
+		}
+
+		return rows, nil
+	},
+	"PseudonymizeWebhookDeliveries": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"PurgeRole": func(s *testStore, args []any) ([]any, error) {
+		id := args[0].(uuid.UUID)
+
+		for _, revoked := range s.revokedRoles {
+			if revoked.RoleID == id {
+				return nil, nil
+			}
+		}
+
+		var rows []any
+
+		s.roles = slices.DeleteFunc(s.roles, func(role database.Role) bool {
+			purged := role.ID == id && role.OrganizationID == args[1].(uuid.UUID) && role.DeletedAt.Valid
+			if purged {
+				rows = append(rows, role)
+			}
+
+			return purged
+		})
+
+		return rows, nil
+	},
+	"PurgeUser": func(s *testStore, args []any) ([]any, error) {
+		id := args[0].(uuid.UUID)
+
+		for _, grant := range append(slices.Clone(s.grants), s.revokedRoles...) {
+			if grant.Grantor == id || grant.UserID == id {
+				return nil, &pgconn.PgError{Code: "23503"}
+			}
+		}
+
+		employee, ok := s.employees[id]
+		if !ok || employee.OrganizationID != args[1].(uuid.UUID) || !employee.DeletedAt.Valid {
+			return nil, nil
+		}
+
+		delete(s.employees, id)
+
+		return []any{employee}, nil
+	},
+	"ReassignServiceAccounts": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"ReassignWebhooks": func(s *testStore, args []any) ([]any, error) {
+		return nil, nil
+	},
+	"UpdateUser": func(s *testStore, args []any) ([]any, error) {
+		employee, ok := s.employees[args[0].(uuid.UUID)]
+		if !ok || employee.OrganizationID != args[5].(uuid.UUID) {
+			return nil, nil
+		}
+
+		employee.Name = args[1].(string)
+		employee.Email = args[2].(string)
+		employee.HashedPassword = args[3].(pgtype.Text)
+		employee.Status = args[4].(string)
+		s.employees[employee.ID] = employee
+
+		return []any{employee}, nil
+	},
+	"UpdateUserProfile": func(s *testStore, args []any) ([]any, error) {
+		employee, ok := s.employees[args[0].(uuid.UUID)]
+		if !ok || employee.OrganizationID != args[2].(uuid.UUID) {
+			return nil, nil
+		}
+
+		employee.Profile = args[1].([]byte)
+		s.employees[employee.ID] = employee
+
+		return nil, nil
+	},
+}
+
+func testRows[T any](values []T) []any {
+	rows := make([]any, len(values))
+	for i, v := range values {
+		rows[i] = v
+	}
+
+	return rows
+}
+
+// testDB is the database.DBTX of the transactions of a testStore. It runs the
+// queries named in testQueries, and fails the others. The queries are
+// recorded in the transactions of the store, at index tx.
+type testDB struct {
+	store *testStore
+	tx    int
+}
+
+func (db *testDB) run(sql string, args []any) ([]any, error) {
+	// The generated queries start with "-- name: <name> :<command>".
+	fields := strings.Fields(sql)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("testDB: unnamed query %q", sql)
+	}
+
+	query, ok := testQueries[fields[2]]
+	if !ok {
+		return nil, fmt.Errorf("testDB: unsupported query %s", fields[2])
+	}
+
+	db.store.transactions[db.tx] = append(db.store.transactions[db.tx], fields[2])
+
+	return query(db.store, args)
+}
+
+func (db *testDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	rows, err := db.run(sql, args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+
+	return pgconn.NewCommandTag(fmt.Sprintf("UPDATE %d", len(rows))), nil
+}
+
+func (db *testDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := db.run(sql, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &testResult{rows: rows, next: -1}, nil
+}
+
+func (db *testDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	rows, err := db.run(sql, args)
+
+	return &testResult{rows: rows, next: -1, err: err}
+}
+
+// testResult is the pgx.Rows and pgx.Row of a query run by testDB.
+type testResult struct {
+	pgx.Rows
+	rows []any
+	next int
+	err  error
+}
+
+func (r *testResult) Next() bool {
+	r.next++
+
+	return r.next < len(r.rows)
+}
+
+func (r *testResult) Err() error {
+	return r.err
+}
+
+func (r *testResult) Close() {}
+
+// Scan scans the current row, or the first one when the result is scanned as
+// a pgx.Row. The fields of a struct row are scanned in order, as the
+// generated code scans the columns of a table into its model.
+func (r *testResult) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	if r.next < 0 {
+		r.next = 0
+	}
+
+	if r.next >= len(r.rows) {
+		return pgx.ErrNoRows
+	}
+
+	row := reflect.ValueOf(r.rows[r.next])
+
+	if len(dest) == 1 {
+		reflect.ValueOf(dest[0]).Elem().Set(row)
+		return nil
+	}
+
+	for i := range dest {
+		reflect.ValueOf(dest[i]).Elem().Set(row.Field(i))
+	}
+
+	return nil
+}
+
+func newTestApplication(t *testing.T) (*application, *testStore) {
+	t.Helper()
+
+	store := &testStore{
+		organization: database.Organization{ID: uuid.New(), Slug: "default", Name: "UAI"},
+		employees:    map[uuid.UUID]database.User{},
+		ldapAccounts: map[uuid.UUID]string{},
+		offboardings: map[uuid.UUID]database.Offboarding{},
+		locked:       map[uuid.UUID]bool{},
+	}
+
+	app := &application{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		store:  store,
+	}
+	app.config.baseURL = "http://localhost:4000"
+	app.config.tenancy.defaultOrganization = "default"
+	app.config.jwt.secretKey = "t3stt3stt3stt3stt3stt3stt3stt3st"
+	app.metrics = app.newMetrics()
+
+	return app, store
+}
+
+// newTestEmployee adds an active employee to the store.
+func newTestEmployee(store *testStore, name, email string) database.User {
+	employee := database.User{
+		ID:             uuid.New(),
+		Name:           name,
+		Email:          email,
+		Status:         employeeStatusActive,
+		OrganizationID: store.organization.ID,
+	}
+
+	store.employees[employee.ID] = employee
+
+	return employee
+}
+
+// testSMTPServer is an SMTP server accepting every email, keeping their
+// recipients.
+type testSMTPServer struct {
+	mu         sync.Mutex
+	recipients []string
+}
+
+// Recipients returns the recipients of the emails received so far.
+func (s *testSMTPServer) Recipients() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.recipients)
+}
+
+func (s *testSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			fmt.Fprint(conn, "250 localhost\r\n")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			address := strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>")
+
+			s.mu.Lock()
+			s.recipients = append(s.recipients, address)
+			s.mu.Unlock()
+
+			fmt.Fprint(conn, "250 OK\r\n")
+		case command == "DATA":
+			fmt.Fprint(conn, "354 End data with <CR><LF>.<CR><LF>\r\n")
+
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				if line == ".\r\n" {
+					break
+				}
+			}
+
+			fmt.Fprint(conn, "250 OK\r\n")
+		case command == "QUIT":
+			fmt.Fprint(conn, "221 Bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "250 OK\r\n")
+		}
+	}
+}
+
+// newTestMailer returns a mailer delivering to server, which listens until
+// the test ends.
+func newTestMailer(t *testing.T, server *testSMTPServer) *smtp.Mailer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go server.serve(conn)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+
+	mailer, err := smtp.NewMailer(addr.IP.String(), addr.Port, "", "", "UAI <no-reply@example.com>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mailer
+}
+
+// newTestRequest returns a request to the organization of the store.
+func newTestRequest(store *testStore, method, target string, body io.Reader) *http.Request {
+	return contextSetOrganization(httptest.NewRequest(method, target, body), &store.organization)
+}
+
+// serveTestRequest sends a request through the routes of the application,
+// authenticated with token when it isn't empty.
+func serveTestRequest(app *application, method, target, token string, body io.Reader) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, r)
+
+	return rr
+}
+
+// serveTestSCIMRequest sends a SCIM request through the routes of the
+// application, with a SCIM token of the organization.
+func serveTestSCIMRequest(t *testing.T, app *application, store *testStore, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	token, err := apikey.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.organization.ScimTokenID = pgtype.Text{String: token.ID, Valid: true}
+	store.organization.ScimHashedToken = pgtype.Text{String: token.Hash, Valid: true}
+
+	r := httptest.NewRequest(method, scimPath+path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token.Key)
+	r.Header.Set("Content-Type", "application/scim+json")
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, r)
+
+	return rr
+}
+
+// issueTestToken returns an authentication token for the employee, as
+// handed out by a password sign-in.
+func issueTestToken(t *testing.T, app *application, store *testStore, employee database.User) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	app.writeAuthenticationToken(rr, newTestRequest(store, http.MethodPost, "/api/v1/authentication-tokens", nil), employee, authMethodPassword)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("issuing a token: got status %d; want %d", rr.Code, http.StatusOK)
+	}
+
+	var data struct {
+		AuthenticationToken string `json:"authenticationToken"`
+	}
+
+	err := json.NewDecoder(rr.Body).Decode(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data.AuthenticationToken
+}
+
+// authenticateTestRequest sends a request with the token through the
+// authenticate middleware, and returns the status of the response.
+func authenticateTestRequest(app *application, store *testStore, token string) int {
+	r := newTestRequest(store, http.MethodGet, "/api/v1/employees/me", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contextGetAuthenticatedUser(r) == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rr := httptest.NewRecorder()
+	app.authenticate(next).ServeHTTP(rr, r)
+
+	return rr.Code
+}
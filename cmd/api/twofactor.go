@@ -0,0 +1,729 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/request"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/twofactor"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pascaldekloe/jwt"
+)
+
+// Authentication methods recorded in the amr claim of the authentication
+// tokens, as registered by RFC 8176, but for fed which marks the sign-ins
+// through the OpenID Connect provider.
+const (
+	authMethodPassword  = "pwd"
+	authMethodOTP       = "otp"
+	authMethodMFA       = "mfa"
+	authMethodFederated = "fed"
+)
+
+const (
+	totpIssuer = "UAI"
+
+	// twoFactorChallengeAudience is appended to the base URL to make the
+	// audience of the tokens proving the password step, so they can't be used
+	// as authentication tokens.
+	twoFactorChallengeAudience = "/two-factor"
+	twoFactorChallengeExpiry   = 5 * time.Minute
+)
+
+// twoFactorEnabled reports whether the employee confirmed a TOTP secret.
+func (app *application) twoFactorEnabled(ctx context.Context, employee database.User) (bool, error) {
+	secret, err := app.store.GetTOTPSecret(ctx, database.GetTOTPSecretParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return secret.ConfirmedAt.Valid, nil
+}
+
+// writeTwoFactorChallenge responds to the first sign-in step of an employee
+// with two-factor authentication enabled. The token returned must be sent
+// along with a code to get an authentication token. methods are the
+// authentication methods of the first step.
+func (app *application) writeTwoFactorChallenge(w http.ResponseWriter, r *http.Request, employee database.User, methods ...string) {
+	var claims jwt.Claims
+
+	claims.Subject = employee.ID.String()
+
+	expiry := time.Now().Add(twoFactorChallengeExpiry)
+	claims.Issued = jwt.NewNumericTime(time.Now())
+	claims.NotBefore = jwt.NewNumericTime(time.Now())
+	claims.Expires = jwt.NewNumericTime(expiry)
+
+	claims.Issuer = app.config.baseURL
+	claims.Audiences = []string{app.config.baseURL + twoFactorChallengeAudience}
+
+	claims.Set = map[string]any{"amr": methods, organizationClaim: employee.OrganizationID.String()}
+
+	jwtBytes, err := claims.HMACSign(jwt.HS256, []byte(app.config.jwt.secretKey))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := map[string]any{
+		"twoFactorRequired":    true,
+		"twoFactorToken":       string(jwtBytes),
+		"twoFactorTokenExpiry": expiry.Format(time.RFC3339),
+	}
+
+	err = response.JSON(w, http.StatusOK, data)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// parseTwoFactorChallenge returns the employee a token made by
+// writeTwoFactorChallenge was issued to, the authentication methods of their
+// first sign-in step and the claims holding their organization.
+func (app *application) parseTwoFactorChallenge(token string) (uuid.UUID, []string, map[string]any, bool) {
+	claims, err := jwt.HMACCheck([]byte(token), []byte(app.config.jwt.secretKey))
+	if err != nil {
+		return uuid.Nil, nil, nil, false
+	}
+
+	if !claims.Valid(time.Now()) || claims.Issuer != app.config.baseURL || !claims.AcceptAudience(app.config.baseURL+twoFactorChallengeAudience) {
+		return uuid.Nil, nil, nil, false
+	}
+
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, nil, nil, false
+	}
+
+	return id, authMethods(claims), claims.Set, true
+}
+
+// verifySecondFactor checks a TOTP code, or else a recovery code, of the
+// employee. A code is accepted once only.
+func (app *application) verifySecondFactor(ctx context.Context, employee database.User, code string) (bool, error) {
+	if !twofactor.IsTOTPCode(code) {
+		n, err := app.store.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:         employee.ID,
+			HashedCode:     twofactor.HashRecoveryCode(code),
+			OrganizationID: employee.OrganizationID,
+		})
+
+		return n == 1, err
+	}
+
+	secret, err := app.store.GetTOTPSecret(ctx, database.GetTOTPSecretParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if !secret.ConfirmedAt.Valid {
+		return false, nil
+	}
+
+	plaintext, err := app.openTOTPSecret(ctx, secret)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := twofactor.Validate(plaintext, code, secret.LastUsedStep, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	// Only one of concurrent requests with the same code succeeds.
+	n, err := app.store.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		UserID:         employee.ID,
+		LastUsedStep:   step,
+		OrganizationID: employee.OrganizationID,
+	})
+
+	return n == 1, err
+}
+
+// openTOTPSecret decrypts a stored TOTP secret. A secret stored in plaintext,
+// before the secrets were encrypted, is encrypted in its place.
+func (app *application) openTOTPSecret(ctx context.Context, secret database.TotpSecret) (string, error) {
+	plaintext, sealed, err := app.totpSecrets.Open(secret.Secret, secret.UserID)
+	if err != nil || sealed {
+		return plaintext, err
+	}
+
+	stored, err := app.totpSecrets.Seal(plaintext, secret.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	err = app.store.UpdateTOTPSecret(ctx, database.UpdateTOTPSecretParams{
+		UserID:         secret.UserID,
+		Secret:         stored,
+		OrganizationID: secret.OrganizationID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// checkSecondFactor verifies the code the authenticated employee sent to
+// change their two-factor settings, and responds when it isn't accepted. Like
+// a sign-in, it's refused while their account is locked and a wrong code
+// counts towards the lockout, so codes can't be guessed this way either.
+func (app *application) checkSecondFactor(w http.ResponseWriter, r *http.Request, employee database.User, code string, v *validator.Validator) bool {
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	locked, err := app.accountLockRemaining(ctx, employee)
+	if err != nil {
+		app.serverError(w, r, err)
+		return false
+	}
+
+	if locked > 0 {
+		app.accountLocked(w, r, locked)
+		return false
+	}
+
+	valid, err := app.verifySecondFactor(ctx, employee, code)
+	if err != nil {
+		app.serverError(w, r, err)
+		return false
+	}
+
+	if !valid {
+		err = app.recordFailedLogin(ctx, r, employee)
+		if err != nil {
+			app.serverError(w, r, err)
+			return false
+		}
+
+		v.AddFieldError("Code", "code_incorrect", "Code is incorrect")
+		app.failedValidation(w, r, *v)
+		return false
+	}
+
+	return true
+}
+
+// replaceRecoveryCodes stores a new set of recovery codes for the employee in
+// place of the previous one, and returns them.
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, employee database.User) ([]string, error) {
+	codes, hashes, err := twofactor.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.DeleteRecoveryCodes(ctx, database.DeleteRecoveryCodesParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.CreateRecoveryCodes(ctx, database.CreateRecoveryCodesParams{
+		UserID:         employee.ID,
+		HashedCodes:    hashes,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (app *application) verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TwoFactorToken string              `json:"twoFactorToken"`
+		Code           string              `json:"code"`
+		Validator      validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	employeeID, methods, claims, ok := app.parseTwoFactorChallenge(input.TwoFactorToken)
+	if ok {
+		r, err = app.contextSetTokenOrganization(r, claims)
+		if err != nil && !errors.Is(err, errUnknownOrganization) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		ok = err == nil
+	}
+
+	input.Validator.CheckField(ok, "TwoFactorToken", "two_factor_token_invalid", "Two-factor token is invalid or expired")
+	input.Validator.CheckField(input.Code != "", "Code", "code_required", "Code is required")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	// The employee may have been deleted since the challenge was issued.
+	employee, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             employeeID,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			input.Validator.AddFieldError("TwoFactorToken", "two_factor_token_invalid", "Two-factor token is invalid or expired")
+			app.failedValidation(w, r, input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Or deactivated or terminated.
+	if employee.Status != employeeStatusActive {
+		app.accountInactive(w, r)
+		return
+	}
+
+	locked, err := app.accountLockRemaining(ctx, employee)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if locked > 0 {
+		app.accountLocked(w, r, locked)
+		return
+	}
+
+	valid, err := app.verifySecondFactor(ctx, employee, input.Code)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !valid {
+		err = app.recordFailedLogin(ctx, r, employee)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		input.Validator.AddFieldError("Code", "code_incorrect", "Code is incorrect")
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	err = app.recordSuccessfulLogin(ctx, r, employee)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.writeAuthenticationToken(w, r, employee, append(methods, authMethodOTP)...)
+}
+
+func (app *application) showTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	employee := contextGetAuthenticatedUser(r)
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	enabled, err := app.twoFactorEnabled(ctx, *employee)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	required, err := app.store.EmployeeRequiresTwoFactor(ctx, database.EmployeeRequiresTwoFactorParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	recoveryCodes, err := app.store.CountUnusedRecoveryCodes(ctx, database.CountUnusedRecoveryCodesParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := map[string]any{
+		"enabled":           enabled,
+		"required":          required,
+		"recoveryCodesLeft": recoveryCodes,
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"twoFactor": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// enrollTOTPHandler generates a new TOTP secret for the authenticated employee.
+// It only takes effect once confirmed with a code, and replaces any secret
+// pending confirmation.
+func (app *application) enrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	employee := contextGetAuthenticatedUser(r)
+
+	enrollment, err := twofactor.Generate(totpIssuer, employee.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	sealed, err := app.totpSecrets.Seal(enrollment.Secret, employee.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	n, err := app.store.CreatePendingTOTPSecret(ctx, database.CreatePendingTOTPSecretParams{
+		UserID:         employee.ID,
+		Secret:         sealed,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if n == 0 {
+		app.twoFactorAlreadyEnabled(w, r)
+		return
+	}
+
+	data := map[string]string{
+		"secret": enrollment.Secret,
+		"uri":    enrollment.URI,
+		"qrCode": enrollment.QRCode,
+	}
+
+	err = response.JSON(w, http.StatusCreated, map[string]any{"totp": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// confirmTOTPHandler enables two-factor authentication once the employee proves
+// their authenticator app works, and returns the recovery codes. They are
+// only disclosed this once.
+func (app *application) confirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code      string              `json:"code"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	employee := contextGetAuthenticatedUser(r)
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	secret, err := app.store.GetTOTPSecret(ctx, database.GetTOTPSecretParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if secret.ConfirmedAt.Valid {
+		app.twoFactorAlreadyEnabled(w, r)
+		return
+	}
+
+	plaintext, err := app.openTOTPSecret(ctx, secret)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	step, ok := twofactor.Validate(plaintext, input.Code, 0, time.Now())
+
+	input.Validator.CheckField(ok, "Code", "code_incorrect", "Code is incorrect")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	var codes []string
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		n, err := q.ConfirmTOTPSecret(ctx, database.ConfirmTOTPSecretParams{
+			UserID:         employee.ID,
+			LastUsedStep:   step,
+			OrganizationID: employee.OrganizationID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return errTwoFactorAlreadyEnabled
+		}
+
+		codes, err = replaceRecoveryCodes(ctx, q, *employee)
+
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errTwoFactorAlreadyEnabled):
+			app.twoFactorAlreadyEnabled(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"recoveryCodes": codes})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+var errTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+// disableTOTPHandler turns two-factor authentication off for the authenticated
+// employee, who must provide a current code or a recovery code.
+func (app *application) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code      string              `json:"code"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	employee := contextGetAuthenticatedUser(r)
+
+	if !app.checkSecondFactor(w, r, *employee, input.Code, &input.Validator) {
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		return deleteTwoFactor(ctx, q, *employee)
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code      string              `json:"code"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	employee := contextGetAuthenticatedUser(r)
+
+	if !app.checkSecondFactor(w, r, *employee, input.Code, &input.Validator) {
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	var codes []string
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		codes, err = replaceRecoveryCodes(ctx, q, *employee)
+		return err
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"recoveryCodes": codes})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// resetTwoFactorHandler lets an admin turn two-factor authentication off for an
+// employee who lost their authenticator app and recovery codes.
+func (app *application) resetTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	employee, err := app.store.GetUser(ctx, database.GetUserParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		return deleteTwoFactor(ctx, q, employee)
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deleteTwoFactor(ctx context.Context, q *database.Queries, employee database.User) error {
+	_, err := q.DeleteTOTPSecret(ctx, database.DeleteTOTPSecretParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.DeleteRecoveryCodes(ctx, database.DeleteRecoveryCodesParams{
+		UserID:         employee.ID,
+		OrganizationID: employee.OrganizationID,
+	})
+}
+
+func (app *application) showTwoFactorPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	roles, err := app.store.GetTwoFactorRoles(ctx, contextGetOrganization(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"policy": map[string]any{"roles": roles}})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// updateTwoFactorPolicyHandler replaces the set of roles whose holders must use
+// two-factor authentication.
+func (app *application) updateTwoFactorPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Roles     []uuid.UUID         `json:"roles"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	input.Validator.CheckField(input.Roles != nil, "Roles", "roles_required", "Roles is required")
+	input.Validator.CheckField(validator.NoDuplicates(input.Roles), "Roles", "roles_duplicated", "Roles must not contain duplicates")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	organizationID := contextGetOrganization(r).ID
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		err := q.DeleteTwoFactorRoles(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+
+		return q.AddTwoFactorRoles(ctx, database.AddTwoFactorRolesParams{
+			RoleIds:        input.Roles,
+			OrganizationID: organizationID,
+		})
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+
+		switch {
+		case errors.As(err, &pgErr) && pgErr.Code == "23503":
+			input.Validator.AddFieldError("Roles", "role_not_found", "Roles must exist")
+			app.failedValidation(w, r, input.Validator)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"policy": map[string]any{"roles": input.Roles}})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,581 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+	"github.com/brGuirra/uai/internal/request"
+	"github.com/brGuirra/uai/internal/response"
+	"github.com/brGuirra/uai/internal/validator"
+	"github.com/brGuirra/uai/internal/webhook"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// maxWebhookFailures is the number of consecutive events an endpoint may
+	// fail to receive before it is disabled.
+	maxWebhookFailures = 10
+
+	// webhookRetryInterval is how often the delivery attempts left due are
+	// looked for.
+	webhookRetryInterval  = 15 * time.Second
+	webhookRetryBatchSize = 100
+
+	// webhookAttemptLease is how long an instance holds a delivery attempt it
+	// makes. It outlasts the timeout of the attempt.
+	webhookAttemptLease = time.Minute
+)
+
+var errWebhookDisabled = errors.New("webhook disabled")
+
+type webhookResponse struct {
+	ID                  uuid.UUID  `json:"id"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Secret              string     `json:"secret,omitempty"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int32      `json:"consecutiveFailures"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	DisabledAt          *time.Time `json:"disabledAt,omitempty"`
+}
+
+func newWebhookResponse(hook database.Webhook) webhookResponse {
+	res := webhookResponse{
+		ID:                  hook.ID,
+		URL:                 hook.Url,
+		Events:              hook.EventTypes,
+		Active:              hook.Active,
+		ConsecutiveFailures: hook.ConsecutiveFailures,
+		CreatedAt:           hook.CreatedAt.Time,
+	}
+
+	if hook.DisabledAt.Valid {
+		res.DisabledAt = &hook.DisabledAt.Time
+	}
+
+	return res
+}
+
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	hooks, err := app.store.GetWebhooks(ctx, contextGetOrganization(r).ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := []webhookResponse{}
+	for _, hook := range hooks {
+		data = append(data, newWebhookResponse(hook))
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"webhooks": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL       string              `json:"url"`
+		Events    []string            `json:"events"`
+		Secret    string              `json:"secret"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	err := request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	checkWebhookURL(ctx, &input.Validator, input.URL)
+	input.Validator.CheckField(len(input.Events) > 0, "Events", "events_required", "At least one event is required")
+	input.Validator.CheckField(validator.AllIn(input.Events, eventTypes...), "Events", "event_type_invalid", "Invalid event type")
+	input.Validator.CheckField(validator.NoDuplicates(input.Events), "Events", "events_duplicated", "Events must not contain duplicates")
+	input.Validator.CheckField(input.Secret == "" || validator.MinRunes(input.Secret, 16), "Secret", "secret_too_short", "Secret must be at least 16 characters long")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	if input.Secret == "" {
+		input.Secret, err = generateWebhookSecret()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	hook, err := app.store.CreateWebhook(ctx, database.CreateWebhookParams{
+		Url:            input.URL,
+		EventTypes:     input.Events,
+		Secret:         input.Secret,
+		CreatedBy:      contextGetAuthenticatedUser(r).ID,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The secret is only disclosed once, when the subscription is created.
+	data := newWebhookResponse(hook)
+	data.Secret = hook.Secret
+
+	err = response.JSON(w, http.StatusCreated, map[string]any{"webhook": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) showWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	hook, err := app.store.GetWebhookByID(ctx, database.GetWebhookByIDParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"webhook": newWebhookResponse(hook)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	hook, err := app.store.GetWebhookByID(ctx, database.GetWebhookByIDParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		URL       *string             `json:"url"`
+		Events    []string            `json:"events"`
+		Active    *bool               `json:"active"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	err = request.DecodeJSON(w, r, &input)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if input.URL != nil {
+		hook.Url = *input.URL
+		checkWebhookURL(ctx, &input.Validator, hook.Url)
+	}
+
+	if input.Events != nil {
+		hook.EventTypes = input.Events
+	}
+
+	if input.Active != nil {
+		hook.Active = *input.Active
+	}
+
+	input.Validator.CheckField(len(hook.EventTypes) > 0, "Events", "events_required", "At least one event is required")
+	input.Validator.CheckField(validator.AllIn(hook.EventTypes, eventTypes...), "Events", "event_type_invalid", "Invalid event type")
+	input.Validator.CheckField(validator.NoDuplicates(hook.EventTypes), "Events", "events_duplicated", "Events must not contain duplicates")
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	hook, err = app.store.UpdateWebhook(ctx, database.UpdateWebhookParams{
+		ID:             hook.ID,
+		Url:            hook.Url,
+		EventTypes:     hook.EventTypes,
+		Active:         hook.Active,
+		OrganizationID: hook.OrganizationID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"webhook": newWebhookResponse(hook)})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	rows, err := app.store.DeleteWebhook(ctx, database.DeleteWebhookParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if rows == 0 {
+		app.notFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readUUIDParam(r, "id")
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	ctx, cancel := detachedContext(r, 5*time.Second)
+	defer cancel()
+
+	_, err = app.store.GetWebhookByID(ctx, database.GetWebhookByIDParams{
+		ID:             id,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.notFound(w, r)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	deliveries, err := app.store.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{
+		WebhookID:      id,
+		Limit:          100,
+		OrganizationID: contextGetOrganization(r).ID,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	type deliveryResponse struct {
+		ID            uuid.UUID       `json:"id"`
+		EventID       uuid.UUID       `json:"eventId"`
+		Event         string          `json:"event"`
+		Payload       json.RawMessage `json:"payload"`
+		Attempt       int32           `json:"attempt"`
+		StatusCode    *int32          `json:"statusCode,omitempty"`
+		Error         string          `json:"error,omitempty"`
+		Succeeded     bool            `json:"succeeded"`
+		DurationMs    int32           `json:"durationMs"`
+		DeliveredAt   time.Time       `json:"deliveredAt"`
+		NextAttemptAt *time.Time      `json:"nextAttemptAt,omitempty"`
+	}
+
+	data := []deliveryResponse{}
+	for _, d := range deliveries {
+		res := deliveryResponse{
+			ID:          d.ID,
+			EventID:     d.EventID,
+			Event:       d.EventType,
+			Payload:     d.Payload,
+			Attempt:     d.Attempt,
+			Error:       d.Error.String,
+			Succeeded:   d.Succeeded,
+			DurationMs:  d.DurationMs,
+			DeliveredAt: d.DeliveredAt.Time,
+		}
+
+		if d.StatusCode.Valid {
+			res.StatusCode = &d.StatusCode.Int32
+		}
+
+		// The attempt is still to make.
+		if d.NextAttemptAt.Valid {
+			res.NextAttemptAt = &d.NextAttemptAt.Time
+		}
+
+		data = append(data, res)
+	}
+
+	err = response.JSON(w, http.StatusOK, map[string]any{"deliveries": data})
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// scheduleWebhooks records the first delivery attempt of evt to every active
+// subscription of its type, held by this instance as it makes them right away
+// with deliverWebhooks. Recording them with the event means none is lost if
+// the instance stops before making them.
+func scheduleWebhooks(ctx context.Context, q *database.Queries, evt event) ([]database.WebhookDelivery, error) {
+	hooks, err := q.GetActiveWebhooksForEvent(ctx, database.GetActiveWebhooksForEventParams{
+		EventType:      evt.Type,
+		OrganizationID: evt.OrganizationID,
+	})
+	if err != nil || len(hooks) == 0 {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return nil, err
+	}
+
+	lockedUntil := pgtype.Timestamp{Time: time.Now().UTC().Add(webhookAttemptLease), Valid: true}
+
+	var deliveries []database.WebhookDelivery
+	for _, hook := range hooks {
+		delivery, err := q.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			WebhookID:      hook.ID,
+			EventID:        evt.ID,
+			EventType:      evt.Type,
+			Payload:        payload,
+			Attempt:        1,
+			NextAttemptAt:  lockedUntil,
+			OrganizationID: evt.OrganizationID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// deliverWebhooks makes the given delivery attempts. Each is made in its own
+// background task so a slow receiver does not hold back the others.
+func (app *application) deliverWebhooks(r *http.Request, deliveries []database.WebhookDelivery) {
+	for _, delivery := range deliveries {
+		app.backgroundTask(r, func(ctx context.Context) error {
+			return app.attemptWebhookDelivery(ctx, r, delivery)
+		})
+	}
+}
+
+// attemptWebhookDelivery makes a delivery attempt this instance holds and
+// records its outcome. A failed attempt schedules the next one, with the same
+// transaction, until the attempts are exhausted and the failure counts towards
+// disabling the subscription. The attempts to a subscription disabled since
+// they were scheduled are recorded as failed, without being made.
+func (app *application) attemptWebhookDelivery(ctx context.Context, r *http.Request, delivery database.WebhookDelivery) error {
+	hook, err := app.store.GetWebhookByID(ctx, database.GetWebhookByIDParams{
+		ID:             delivery.WebhookID,
+		OrganizationID: delivery.OrganizationID,
+	})
+	if err != nil {
+		// The deliveries of a deleted subscription are deleted with it.
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+
+		return err
+	}
+
+	attempt := webhook.Attempt{Number: int(delivery.Attempt), Err: errWebhookDisabled}
+	if hook.Active {
+		attempt = app.webhooks.Deliver(ctx, hook.Url, hook.Secret, delivery.EventType, delivery.EventID.String(), delivery.Payload, int(delivery.Attempt))
+	}
+
+	params := database.CompleteWebhookDeliveryParams{
+		ID:             delivery.ID,
+		Succeeded:      attempt.Err == nil,
+		DurationMs:     int32(attempt.Duration.Milliseconds()),
+		OrganizationID: delivery.OrganizationID,
+	}
+
+	if attempt.StatusCode != 0 {
+		params.StatusCode = pgtype.Int4{Int32: int32(attempt.StatusCode), Valid: true}
+	}
+
+	if attempt.Err != nil {
+		params.Error = pgtype.Text{String: attempt.Err.Error(), Valid: true}
+	}
+
+	retry := hook.Active && attempt.Err != nil && attempt.Number < webhook.MaxAttempts
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err = app.store.ExecTx(ctx, func(q *database.Queries) error {
+		err := q.CompleteWebhookDelivery(ctx, params)
+		if err != nil || !retry {
+			return err
+		}
+
+		_, err = q.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			WebhookID:      delivery.WebhookID,
+			EventID:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Payload:        delivery.Payload,
+			Attempt:        delivery.Attempt + 1,
+			NextAttemptAt:  pgtype.Timestamp{Time: time.Now().UTC().Add(webhook.Backoff(attempt.Number)), Valid: true},
+			OrganizationID: delivery.OrganizationID,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !hook.Active, retry:
+		return nil
+	case attempt.Err == nil:
+		if hook.ConsecutiveFailures > 0 {
+			return app.store.ResetWebhookFailures(ctx, database.ResetWebhookFailuresParams{
+				ID:             hook.ID,
+				OrganizationID: hook.OrganizationID,
+			})
+		}
+
+		return nil
+	}
+
+	active, err := app.store.RecordWebhookFailure(ctx, database.RecordWebhookFailureParams{
+		MaxFailures:    maxWebhookFailures,
+		ID:             hook.ID,
+		OrganizationID: hook.OrganizationID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !active {
+		app.logger.WarnContext(ctx, "webhook disabled after repeated failures", slog.Group("webhook", "id", hook.ID, "url", hook.Url))
+	}
+
+	return nil
+}
+
+// runWebhookRetries makes the delivery attempts left due, the retries of the
+// failed ones and the ones held by an instance that stopped before making
+// them, until ctx is cancelled.
+func (app *application) runWebhookRetries(ctx context.Context) {
+	app.runOrganizationJob(ctx, "webhook retries", webhookRetryInterval, app.retryWebhooks)
+}
+
+// retryWebhooks claims the due delivery attempts of the organization and makes
+// them. The ones another instance claimed first are skipped.
+func (app *application) retryWebhooks(ctx context.Context, r *http.Request, organizationID uuid.UUID) error {
+	now := pgtype.Timestamp{Time: time.Now().UTC(), Valid: true}
+
+	ids, err := app.store.GetDueWebhookDeliveries(ctx, database.GetDueWebhookDeliveriesParams{
+		Now:            now,
+		OrganizationID: organizationID,
+		Limit:          webhookRetryBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []database.WebhookDelivery
+	for _, id := range ids {
+		delivery, err := app.store.ClaimWebhookDelivery(ctx, database.ClaimWebhookDeliveryParams{
+			LockedUntil:    pgtype.Timestamp{Time: now.Time.Add(webhookAttemptLease), Valid: true},
+			ID:             id,
+			Now:            now,
+			OrganizationID: organizationID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+
+			return err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	app.deliverWebhooks(r, deliveries)
+
+	return nil
+}
+
+// checkWebhookURL checks the endpoint URL of a subscription, including that
+// its host isn't on the network of the application.
+func checkWebhookURL(ctx context.Context, v *validator.Validator, url string) {
+	if !validator.IsURL(url) {
+		v.AddFieldError("URL", "url_invalid", "Must be a valid URL")
+		return
+	}
+
+	err := webhook.CheckURL(ctx, url)
+	switch {
+	case err == nil:
+	case errors.Is(err, webhook.ErrUnsupportedScheme):
+		v.AddFieldError("URL", "url_invalid", "Must be an http or https URL")
+	case errors.Is(err, webhook.ErrForbiddenAddress):
+		v.AddFieldError("URL", "url_not_allowed", "Must not point to a private, loopback or link-local address")
+	default:
+		v.AddFieldError("URL", "url_unresolvable", "Host could not be resolved")
+	}
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,91 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// Prefix starts every API key, so they can be told apart from JWTs and found
+// by secret scanners.
+const Prefix = "uai_"
+
+// The random bytes of the ID and secret of keys, the ID being 8 characters
+// long once encoded.
+const (
+	idBytes     = 5
+	secretBytes = 32
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Key is a new API key. Only its ID and hash are stored, the key itself is
+// shown once to its creator.
+type Key struct {
+	// Key is written as "uai_<id>_<secret>".
+	Key string
+
+	// ID identifies the key, and is shown along with it so a key can be
+	// recognized without disclosing it.
+	ID   string
+	Hash string
+}
+
+// New generates a random API key.
+func New() (Key, error) {
+	id, err := randomString(idBytes)
+	if err != nil {
+		return Key{}, err
+	}
+
+	secret, err := randomString(secretBytes)
+	if err != nil {
+		return Key{}, err
+	}
+
+	key := Prefix + id + "_" + secret
+
+	return Key{Key: key, ID: id, Hash: Hash(key)}, nil
+}
+
+// Parse returns the ID of the key, or false when it isn't an API key.
+func Parse(key string) (id string, ok bool) {
+	rest, ok := strings.CutPrefix(key, Prefix)
+	if !ok {
+		return "", false
+	}
+
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(id) != encoding.EncodedLen(idBytes) || secret == "" {
+		return "", false
+	}
+
+	return id, true
+}
+
+// Hash returns the hash stored for a key. The keys have enough entropy for a
+// plain SHA-256 to be safe.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Matches reports whether the key has the given hash, in constant time.
+func Matches(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(Hash(key)), []byte(hash)) == 1
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(encoding.EncodeToString(b)), nil
+}
@@ -11,6 +11,7 @@ import (
 
 	database "github.com/brGuirra/uai/internal/database/sqlc"
 	"github.com/brGuirra/uai/internal/password"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -23,10 +24,12 @@ type config struct {
 		email    string
 		password string
 	}
-	roles []string
+	roles        []string
+	organization string
 }
 
-// run initializes the database creating the default roles and the root user.
+// run initializes the database creating the default roles and the root user
+// of an organization.
 func run() error {
 	var cfg config
 
@@ -36,6 +39,8 @@ func run() error {
 	flag.StringVar(&cfg.rootUser.email, "root-email", "root@example.com", "The email of the root user")
 	flag.StringVar(&cfg.rootUser.password, "root-password", "root", "The password of the root user")
 
+	flag.StringVar(&cfg.organization, "organization", "default", "The slug of the organization to initialize")
+
 	flag.Func("roles", "The roles to be created, separated by spaces", func(val string) error {
 		cfg.roles = strings.Fields(val)
 		return nil
@@ -51,48 +56,59 @@ func run() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	organization, err := store.GetOrganizationBySlug(ctx, cfg.organization)
+	if err != nil {
+		return err
+	}
+
+	ctx = database.WithOrganization(ctx, organization.ID)
+
 	err = store.ExecTx(ctx, func(q *database.Queries) error {
 		hashedPassword, err := password.Hash(cfg.rootUser.password)
 		if err != nil {
 			return err
 		}
 
-		employee, err := q.CreateEmployee(ctx, database.CreateEmployeeParams{
+		employee, err := q.CreateUser(ctx, database.CreateUserParams{
 			Name:  cfg.rootUser.name,
 			Email: cfg.rootUser.email,
 			HashedPassword: pgtype.Text{
 				String: hashedPassword,
 				Valid:  true,
 			},
-			Status: "active",
+			Status:         "active",
+			OrganizationID: organization.ID,
 		})
 		if err != nil {
 			return err
 		}
 
-		_, err = q.CreateRoles(ctx, cfg.roles)
-		if err != nil {
-			return err
+		for _, name := range cfg.roles {
+			_, err = q.CreateRole(ctx, database.CreateRoleParams{
+				DisplayName:    name,
+				OrganizationID: organization.ID,
+			})
+			if err != nil {
+				return err
+			}
 		}
 
-		roles, err := q.GetRoles(ctx)
+		roles, err := q.GetRoles(ctx, organization.ID)
 		if err != nil {
 			return err
 		}
 
-		var args []database.AddRolesForEmployeeParams
-
+		// The root user is recorded as the grantor of their own roles.
 		for _, r := range roles {
-			args = append(args, database.AddRolesForEmployeeParams{
-				EmployeeID: employee.ID,
-				RoleID:     r.ID,
-				Grantor:    employee.ID,
+			err = q.AddRoleMembers(ctx, database.AddRoleMembersParams{
+				RoleID:         r.ID,
+				Grantor:        employee.ID,
+				OrganizationID: organization.ID,
+				UserIds:        []uuid.UUID{employee.ID},
 			})
-		}
-
-		_, err = q.AddRolesForEmployee(ctx, args)
-		if err != nil {
-			return err
+			if err != nil {
+				return err
+			}
 		}
 
 		return nil
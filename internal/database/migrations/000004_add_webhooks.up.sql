@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS "webhooks" (
+    "id" uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    "url" varchar NOT NULL,
+    "event_types" varchar [] NOT NULL,
+    "secret" varchar NOT NULL,
+    "active" boolean NOT NULL DEFAULT (true),
+    "consecutive_failures" integer NOT NULL DEFAULT (0),
+    "created_by" uuid NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (now()),
+    "disabled_at" timestamp DEFAULT NULL
+);
+
+-- A delivery attempt is recorded before it is made, due at "next_attempt_at",
+-- which is cleared once the attempt is made. Any instance picks up the attempts
+-- left due, so retries outlive the instance that scheduled them.
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    "webhook_id" uuid NOT NULL,
+    "event_id" uuid NOT NULL,
+    "event_type" varchar NOT NULL,
+    "payload" jsonb NOT NULL,
+    "attempt" integer NOT NULL,
+    "status_code" integer DEFAULT NULL,
+    "error" varchar DEFAULT NULL,
+    "succeeded" boolean NOT NULL,
+    "duration_ms" integer NOT NULL,
+    "delivered_at" timestamp NOT NULL DEFAULT (now()),
+    "next_attempt_at" timestamp DEFAULT NULL
+);
+
+ALTER TABLE "webhooks" ADD CONSTRAINT "webhook_creator" FOREIGN KEY (
+    "created_by"
+) REFERENCES "users" ("id");
+
+ALTER TABLE "webhook_deliveries" ADD CONSTRAINT "delivery_webhook" FOREIGN KEY (
+    "webhook_id"
+) REFERENCES "webhooks" ("id") ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_webhook_id_idx"
+ON "webhook_deliveries" ("webhook_id", "delivered_at");
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "events" (
+    "id" uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    "sequence" bigserial UNIQUE NOT NULL,
+    "type" varchar NOT NULL,
+    "data" jsonb NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (now())
+);
+
+CREATE OR REPLACE FUNCTION "notify_event"() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('events', NEW."sequence"::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "events_notify"
+AFTER INSERT ON "events"
+FOR EACH ROW EXECUTE FUNCTION "notify_event"();
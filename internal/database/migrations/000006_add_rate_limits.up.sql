@@ -0,0 +1,11 @@
+-- Token buckets of the Postgres rate limit store. The table is unlogged since
+-- losing the buckets on a crash only resets the limits.
+CREATE UNLOGGED TABLE IF NOT EXISTS "rate_limits" (
+    "key" varchar PRIMARY KEY,
+    "tokens" double precision NOT NULL,
+    "allowed" boolean NOT NULL,
+    "updated_at" timestamp NOT NULL DEFAULT (now()),
+    "full_at" timestamp NOT NULL
+);
+
+CREATE INDEX ON "rate_limits" ("full_at");
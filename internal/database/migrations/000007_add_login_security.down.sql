@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "known_logins";
+
+DROP TABLE IF EXISTS "account_lockouts";
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS "account_lockouts" (
+    "user_id" uuid PRIMARY KEY,
+    "failed_attempts" integer NOT NULL DEFAULT 0,
+    "lockouts" integer NOT NULL DEFAULT 0,
+    "locked_until" timestamp DEFAULT NULL,
+    "updated_at" timestamp NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE IF NOT EXISTS "known_logins" (
+    "user_id" uuid NOT NULL,
+    "ip" varchar NOT NULL,
+    "user_agent" varchar NOT NULL,
+    "first_seen_at" timestamp NOT NULL DEFAULT (now()),
+    "last_seen_at" timestamp NOT NULL DEFAULT (now()),
+    PRIMARY KEY ("user_id", "ip", "user_agent")
+);
+
+ALTER TABLE "account_lockouts" ADD CONSTRAINT "lockout_user" FOREIGN KEY (
+    "user_id"
+) REFERENCES "users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "known_logins" ADD CONSTRAINT "known_login_user" FOREIGN KEY (
+    "user_id"
+) REFERENCES "users" ("id") ON DELETE CASCADE;
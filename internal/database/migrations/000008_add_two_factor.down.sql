@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "two_factor_roles";
+
+DROP TABLE IF EXISTS "recovery_codes";
+
+DROP TABLE IF EXISTS "totp_secrets";
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS "totp_secrets" (
+    "user_id" uuid PRIMARY KEY,
+    "secret" varchar NOT NULL,
+    "last_used_step" bigint NOT NULL DEFAULT 0,
+    "confirmed_at" timestamp DEFAULT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "user_id" uuid NOT NULL,
+    "hashed_code" varchar NOT NULL,
+    "used_at" timestamp DEFAULT NULL,
+    PRIMARY KEY ("user_id", "hashed_code")
+);
+
+-- Roles whose holders must use two-factor authentication.
+CREATE TABLE IF NOT EXISTS "two_factor_roles" (
+    "role_id" uuid PRIMARY KEY
+);
+
+ALTER TABLE "totp_secrets" ADD CONSTRAINT "totp_secret_user" FOREIGN KEY (
+    "user_id"
+) REFERENCES "users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "recovery_codes" ADD CONSTRAINT "recovery_code_user" FOREIGN KEY (
+    "user_id"
+) REFERENCES "users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "two_factor_roles" ADD CONSTRAINT "two_factor_role" FOREIGN KEY (
+    "role_id"
+) REFERENCES "roles" ("id") ON DELETE CASCADE;
@@ -0,0 +1,10 @@
+-- Employees synchronized from an LDAP directory, with the DN of their entry.
+CREATE TABLE IF NOT EXISTS "ldap_accounts" (
+    "user_id" uuid PRIMARY KEY,
+    "dn" varchar UNIQUE NOT NULL,
+    "synced_at" timestamp NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "ldap_accounts" ADD CONSTRAINT "ldap_account_user" FOREIGN KEY (
+    "user_id"
+) REFERENCES "users" ("id") ON DELETE CASCADE;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "api_keys";
+
+DROP TABLE IF EXISTS "service_accounts_permissions";
+
+DROP TABLE IF EXISTS "service_accounts";
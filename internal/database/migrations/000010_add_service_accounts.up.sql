@@ -0,0 +1,46 @@
+-- Non-human principals, such as integrations, authenticating with API keys.
+CREATE TABLE IF NOT EXISTS "service_accounts" (
+    "id" uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    "name" varchar UNIQUE NOT NULL,
+    "description" varchar NOT NULL DEFAULT '',
+    "created_by" uuid NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (now()),
+    "disabled_at" timestamp DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS "service_accounts_permissions" (
+    "service_account_id" uuid NOT NULL,
+    "permission_id" uuid NOT NULL,
+    PRIMARY KEY ("service_account_id", "permission_id")
+);
+
+-- Only the hash of a key is stored. Scopes restrict the key to some of the
+-- permissions of its service account, all of them when empty.
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    "service_account_id" uuid NOT NULL,
+    "name" varchar NOT NULL,
+    "key_id" varchar UNIQUE NOT NULL,
+    "hashed_key" varchar NOT NULL,
+    "scopes" varchar [] NOT NULL DEFAULT ('{}'),
+    "expires_at" timestamp DEFAULT NULL,
+    "last_used_at" timestamp DEFAULT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (now()),
+    "revoked_at" timestamp DEFAULT NULL
+);
+
+ALTER TABLE "service_accounts" ADD CONSTRAINT "service_account_creator" FOREIGN KEY (
+    "created_by"
+) REFERENCES "users" ("id");
+
+ALTER TABLE "service_accounts_permissions" ADD CONSTRAINT "service_account_permission_account" FOREIGN KEY (
+    "service_account_id"
+) REFERENCES "service_accounts" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "service_accounts_permissions" ADD CONSTRAINT "service_account_permission" FOREIGN KEY (
+    "permission_id"
+) REFERENCES "permissions" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "api_keys" ADD CONSTRAINT "api_key_service_account" FOREIGN KEY (
+    "service_account_id"
+) REFERENCES "service_accounts" ("id") ON DELETE CASCADE;
@@ -0,0 +1,21 @@
+-- Admins acting as another employee. Sessions are kept once ended, as the
+-- audit trail of who impersonated whom, when and why.
+CREATE TABLE IF NOT EXISTS "impersonation_sessions" (
+    "id" uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    "actor_id" uuid NOT NULL,
+    "subject_id" uuid NOT NULL,
+    "reason" varchar NOT NULL,
+    "started_at" timestamp NOT NULL DEFAULT (now()),
+    "expires_at" timestamp NOT NULL,
+    "ended_at" timestamp DEFAULT NULL
+);
+
+CREATE INDEX ON "impersonation_sessions" ("started_at");
+
+ALTER TABLE "impersonation_sessions" ADD CONSTRAINT "impersonation_actor" FOREIGN KEY (
+    "actor_id"
+) REFERENCES "users" ("id");
+
+ALTER TABLE "impersonation_sessions" ADD CONSTRAINT "impersonation_subject" FOREIGN KEY (
+    "subject_id"
+) REFERENCES "users" ("id");
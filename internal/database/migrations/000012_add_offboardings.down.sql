@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "revoked_roles";
+
+DROP TABLE IF EXISTS "offboardings";
@@ -0,0 +1,47 @@
+-- Employees scheduled to leave on their termination date, when their items are
+-- reassigned to their manager. Completed offboardings are kept as a record.
+CREATE TABLE IF NOT EXISTS "offboardings" (
+    "user_id" uuid PRIMARY KEY,
+    "manager_id" uuid NOT NULL,
+    "terminates_at" timestamp NOT NULL,
+    "requested_by" uuid NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (now()),
+    "completed_at" timestamp DEFAULT NULL
+);
+
+CREATE INDEX ON "offboardings" ("terminates_at");
+
+-- Role grants removed from employees, so their history isn't lost.
+CREATE TABLE IF NOT EXISTS "revoked_roles" (
+    "user_id" uuid NOT NULL,
+    "role_id" uuid NOT NULL,
+    "grantor" uuid NOT NULL,
+    "granted_at" timestamp NOT NULL,
+    "revoked_at" timestamp NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "revoked_roles" ("user_id");
+
+ALTER TABLE "offboardings" ADD CONSTRAINT "offboarding_user" FOREIGN KEY (
+    "user_id"
+) REFERENCES "users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "offboardings" ADD CONSTRAINT "offboarding_manager" FOREIGN KEY (
+    "manager_id"
+) REFERENCES "users" ("id");
+
+ALTER TABLE "offboardings" ADD CONSTRAINT "offboarding_requester" FOREIGN KEY (
+    "requested_by"
+) REFERENCES "users" ("id");
+
+ALTER TABLE "revoked_roles" ADD CONSTRAINT "revoked_role_user" FOREIGN KEY (
+    "user_id"
+) REFERENCES "users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "revoked_roles" ADD CONSTRAINT "revoked_role_role" FOREIGN KEY (
+    "role_id"
+) REFERENCES "roles" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "revoked_roles" ADD CONSTRAINT "revoked_role_grantor" FOREIGN KEY (
+    "grantor"
+) REFERENCES "users" ("id");
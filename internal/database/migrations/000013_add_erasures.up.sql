@@ -0,0 +1,15 @@
+-- Employees whose personal data was erased on their request. The employee is
+-- kept, pseudonymized, so the records referencing them stay valid.
+CREATE TABLE IF NOT EXISTS "erasures" (
+    "user_id" uuid PRIMARY KEY,
+    "erased_by" uuid NOT NULL,
+    "erased_at" timestamp NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "erasures" ADD CONSTRAINT "erasure_user" FOREIGN KEY (
+    "user_id"
+) REFERENCES "users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "erasures" ADD CONSTRAINT "erasure_requester" FOREIGN KEY (
+    "erased_by"
+) REFERENCES "users" ("id");
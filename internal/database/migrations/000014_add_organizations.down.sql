@@ -0,0 +1,115 @@
+CREATE OR REPLACE FUNCTION "notify_event"() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('events', NEW."sequence"::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    "reference" record;
+BEGIN
+    FOR "reference" IN
+        SELECT
+            c."conname",
+            c."conrelid"::regclass AS "tenant_table",
+            a."attname" AS "column",
+            c."confrelid"::regclass AS "referenced_table",
+            fa."attname" AS "referenced_column",
+            c."confdeltype" = 'c' AS "cascade"
+        FROM "pg_constraint" AS c
+        INNER JOIN "pg_attribute" AS a
+            ON c."conrelid" = a."attrelid" AND c."conkey"[1] = a."attnum"
+        INNER JOIN "pg_attribute" AS fa
+            ON c."confrelid" = fa."attrelid" AND c."confkey"[1] = fa."attnum"
+        INNER JOIN "pg_attribute" AS o
+            ON c."conrelid" = o."attrelid" AND c."conkey"[2] = o."attnum"
+        WHERE
+            c."contype" = 'f'
+            AND cardinality(c."conkey") = 2
+            AND o."attname" = 'organization_id'
+    LOOP
+        EXECUTE format(
+            'ALTER TABLE %s DROP CONSTRAINT %I, ADD CONSTRAINT %I FOREIGN KEY (%I) REFERENCES %s (%I)%s',
+            "reference"."tenant_table",
+            "reference"."conname",
+            "reference"."conname",
+            "reference"."column",
+            "reference"."referenced_table",
+            "reference"."referenced_column",
+            CASE WHEN "reference"."cascade" THEN ' ON DELETE CASCADE' ELSE '' END
+        );
+    END LOOP;
+
+    FOR "reference" IN
+        SELECT
+            c."conname",
+            c."conrelid"::regclass AS "tenant_table"
+        FROM "pg_constraint" AS c
+        INNER JOIN "pg_attribute" AS o
+            ON c."conrelid" = o."attrelid" AND c."conkey"[2] = o."attnum"
+        WHERE
+            c."contype" = 'u'
+            AND cardinality(c."conkey") = 2
+            AND o."attname" = 'organization_id'
+    LOOP
+        EXECUTE format(
+            'ALTER TABLE %s DROP CONSTRAINT %I',
+            "reference"."tenant_table",
+            "reference"."conname"
+        );
+    END LOOP;
+END;
+$$;
+
+DROP INDEX IF EXISTS "webhook_deliveries_next_attempt_at_idx";
+
+ALTER TABLE "ldap_accounts" DROP CONSTRAINT "ldap_accounts_organization_dn_key";
+
+ALTER TABLE "ldap_accounts" ADD CONSTRAINT "ldap_accounts_dn_key" UNIQUE ("dn");
+
+ALTER TABLE "service_accounts" DROP CONSTRAINT "service_accounts_organization_name_key";
+
+ALTER TABLE "service_accounts" ADD CONSTRAINT "service_accounts_name_key" UNIQUE ("name");
+
+ALTER TABLE "users" DROP CONSTRAINT "users_organization_email_key";
+
+ALTER TABLE "users" ADD CONSTRAINT "users_email_key" UNIQUE ("email");
+
+DO $$
+DECLARE
+    "tenant_table" varchar;
+BEGIN
+    FOREACH "tenant_table" IN ARRAY ARRAY[
+        'users',
+        'roles',
+        'users_roles',
+        'roles_permissions',
+        'webhooks',
+        'webhook_deliveries',
+        'events',
+        'account_lockouts',
+        'known_logins',
+        'totp_secrets',
+        'recovery_codes',
+        'two_factor_roles',
+        'ldap_accounts',
+        'service_accounts',
+        'service_accounts_permissions',
+        'api_keys',
+        'impersonation_sessions',
+        'offboardings',
+        'revoked_roles',
+        'erasures'
+    ] LOOP
+        EXECUTE format('DROP POLICY IF EXISTS "tenant_isolation" ON %I', "tenant_table");
+        EXECUTE format('ALTER TABLE %I NO FORCE ROW LEVEL SECURITY', "tenant_table");
+        EXECUTE format('ALTER TABLE %I DISABLE ROW LEVEL SECURITY', "tenant_table");
+        EXECUTE format('ALTER TABLE %I DROP COLUMN IF EXISTS "organization_id"', "tenant_table");
+    END LOOP;
+END;
+$$;
+
+DROP FUNCTION IF EXISTS "current_organization_id";
+
+DROP TABLE IF EXISTS "organizations";
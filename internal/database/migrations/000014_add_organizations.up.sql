@@ -0,0 +1,262 @@
+-- Tenants of the application. Every other table, except the permissions and
+-- the rate limits, belongs to an organization. The slug is the subdomain the
+-- organization is reached at.
+CREATE TABLE IF NOT EXISTS "organizations" (
+    "id" uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    "slug" varchar UNIQUE NOT NULL,
+    "name" varchar NOT NULL,
+    "email_sender" varchar DEFAULT NULL,
+    "brand_name" varchar DEFAULT NULL,
+    "brand_color" varchar DEFAULT NULL,
+    "logo_url" varchar DEFAULT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (now())
+);
+
+-- The existing data becomes the data of the default organization.
+INSERT INTO "organizations" ("slug", "name") VALUES ('default', 'UAI');
+
+-- The organization of the requests a connection runs, set by the API when it
+-- acquires the connection. NULL when there is none, so no row is visible.
+CREATE OR REPLACE FUNCTION "current_organization_id"() RETURNS uuid AS $$
+    SELECT nullif(current_setting('app.organization_id', TRUE), '')::uuid;
+$$ LANGUAGE sql STABLE;
+
+-- The columns are added in plain statements rather than in the loop below,
+-- so sqlc sees them.
+ALTER TABLE "users" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "roles" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "users_roles" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "roles_permissions" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "webhooks" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "webhook_deliveries" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "events" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "account_lockouts" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "known_logins" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "totp_secrets" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "recovery_codes" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "two_factor_roles" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "ldap_accounts" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "service_accounts" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "service_accounts_permissions" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "api_keys" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "impersonation_sessions" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "offboardings" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "revoked_roles" ADD COLUMN "organization_id" uuid;
+
+ALTER TABLE "erasures" ADD COLUMN "organization_id" uuid;
+
+-- Every query is scoped by organization. The policies are a second line of
+-- defence, hiding the rows of the other organizations from a query that isn't.
+-- They are forced so they apply to the owner of the tables too.
+DO $$
+DECLARE
+    "tenant_table" varchar;
+BEGIN
+    FOREACH "tenant_table" IN ARRAY ARRAY[
+        'users',
+        'roles',
+        'users_roles',
+        'roles_permissions',
+        'webhooks',
+        'webhook_deliveries',
+        'events',
+        'account_lockouts',
+        'known_logins',
+        'totp_secrets',
+        'recovery_codes',
+        'two_factor_roles',
+        'ldap_accounts',
+        'service_accounts',
+        'service_accounts_permissions',
+        'api_keys',
+        'impersonation_sessions',
+        'offboardings',
+        'revoked_roles',
+        'erasures'
+    ] LOOP
+        EXECUTE format(
+            'UPDATE %I SET "organization_id" = (SELECT "id" FROM "organizations" WHERE "slug" = %L)',
+            "tenant_table",
+            'default'
+        );
+        EXECUTE format(
+            'ALTER TABLE %I ADD CONSTRAINT %I FOREIGN KEY ("organization_id") REFERENCES "organizations" ("id") ON DELETE CASCADE',
+            "tenant_table",
+            "tenant_table" || '_organization'
+        );
+        EXECUTE format('CREATE INDEX ON %I ("organization_id")', "tenant_table");
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', "tenant_table");
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', "tenant_table");
+        EXECUTE format(
+            'CREATE POLICY "tenant_isolation" ON %I USING ("organization_id" = current_organization_id())',
+            "tenant_table"
+        );
+    END LOOP;
+END;
+$$;
+
+-- Every existing row belongs to the default organization by now.
+ALTER TABLE "users" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "roles" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "users_roles" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "roles_permissions" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "webhooks" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "webhook_deliveries" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "events" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "account_lockouts" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "known_logins" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "totp_secrets" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "recovery_codes" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "two_factor_roles" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "ldap_accounts" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "service_accounts" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "service_accounts_permissions" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "api_keys" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "impersonation_sessions" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "offboardings" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "revoked_roles" ALTER COLUMN "organization_id" SET NOT NULL;
+
+ALTER TABLE "erasures" ALTER COLUMN "organization_id" SET NOT NULL;
+
+-- The due webhook delivery attempts are looked up per organization.
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_next_attempt_at_idx"
+ON "webhook_deliveries" ("organization_id", "next_attempt_at")
+WHERE "next_attempt_at" IS NOT NULL;
+
+-- Email addresses, service account names and LDAP entries are unique within an
+-- organization only.
+ALTER TABLE "users" DROP CONSTRAINT "users_email_key";
+
+ALTER TABLE "users" ADD CONSTRAINT "users_organization_email_key" UNIQUE (
+    "organization_id", "email"
+);
+
+ALTER TABLE "service_accounts" DROP CONSTRAINT "service_accounts_name_key";
+
+ALTER TABLE "service_accounts" ADD CONSTRAINT "service_accounts_organization_name_key" UNIQUE (
+    "organization_id", "name"
+);
+
+ALTER TABLE "ldap_accounts" DROP CONSTRAINT "ldap_accounts_dn_key";
+
+ALTER TABLE "ldap_accounts" ADD CONSTRAINT "ldap_accounts_organization_dn_key" UNIQUE (
+    "organization_id", "dn"
+);
+
+-- References between the tables of the organizations can't cross them: a row
+-- pointing to another by its ID must belong to the same organization.
+DO $$
+DECLARE
+    "reference" record;
+BEGIN
+    FOR "reference" IN
+        SELECT DISTINCT
+            c."confrelid"::regclass AS "referenced_table",
+            fa."attname" AS "referenced_column"
+        FROM "pg_constraint" AS c
+        INNER JOIN "pg_attribute" AS fa
+            ON c."confrelid" = fa."attrelid" AND c."confkey"[1] = fa."attnum"
+        WHERE
+            c."contype" = 'f'
+            AND cardinality(c."conkey") = 1
+            AND c."confrelid" <> '"organizations"'::regclass
+            AND EXISTS (
+                SELECT FROM "pg_attribute"
+                WHERE "attrelid" = c."confrelid" AND "attname" = 'organization_id'
+            )
+            AND EXISTS (
+                SELECT FROM "pg_attribute"
+                WHERE "attrelid" = c."conrelid" AND "attname" = 'organization_id'
+            )
+    LOOP
+        EXECUTE format(
+            'ALTER TABLE %s ADD CONSTRAINT %I UNIQUE (%I, "organization_id")',
+            "reference"."referenced_table",
+            "reference"."referenced_table"::text || '_' || "reference"."referenced_column" || '_organization_key',
+            "reference"."referenced_column"
+        );
+    END LOOP;
+
+    FOR "reference" IN
+        SELECT
+            c."conname",
+            c."conrelid"::regclass AS "tenant_table",
+            a."attname" AS "column",
+            c."confrelid"::regclass AS "referenced_table",
+            fa."attname" AS "referenced_column",
+            c."confdeltype" = 'c' AS "cascade"
+        FROM "pg_constraint" AS c
+        INNER JOIN "pg_attribute" AS a
+            ON c."conrelid" = a."attrelid" AND c."conkey"[1] = a."attnum"
+        INNER JOIN "pg_attribute" AS fa
+            ON c."confrelid" = fa."attrelid" AND c."confkey"[1] = fa."attnum"
+        WHERE
+            c."contype" = 'f'
+            AND cardinality(c."conkey") = 1
+            AND c."confrelid" <> '"organizations"'::regclass
+            AND EXISTS (
+                SELECT FROM "pg_attribute"
+                WHERE "attrelid" = c."confrelid" AND "attname" = 'organization_id'
+            )
+            AND EXISTS (
+                SELECT FROM "pg_attribute"
+                WHERE "attrelid" = c."conrelid" AND "attname" = 'organization_id'
+            )
+    LOOP
+        EXECUTE format(
+            'ALTER TABLE %s DROP CONSTRAINT %I, ADD CONSTRAINT %I FOREIGN KEY (%I, "organization_id") REFERENCES %s (%I, "organization_id")%s',
+            "reference"."tenant_table",
+            "reference"."conname",
+            "reference"."conname",
+            "reference"."column",
+            "reference"."referenced_table",
+            "reference"."referenced_column",
+            CASE WHEN "reference"."cascade" THEN ' ON DELETE CASCADE' ELSE '' END
+        );
+    END LOOP;
+END;
+$$;
+
+-- The listeners need the organization to read the event under its policy.
+CREATE OR REPLACE FUNCTION "notify_event"() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('events', NEW."organization_id"::text || ':' || NEW."sequence"::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,10 @@
+-- Deleted employees and roles are kept, hidden, so the grants they made and
+-- the history referencing them stay valid. They can be restored until they're
+-- purged, once the retention period is over.
+ALTER TABLE "users" ADD COLUMN "deleted_at" timestamp DEFAULT NULL;
+
+ALTER TABLE "roles" ADD COLUMN "deleted_at" timestamp DEFAULT NULL;
+
+CREATE INDEX ON "users" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+
+CREATE INDEX ON "roles" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
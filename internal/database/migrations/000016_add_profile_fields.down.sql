@@ -0,0 +1,3 @@
+ALTER TABLE "users" DROP COLUMN IF EXISTS "profile";
+
+DROP TABLE IF EXISTS "profile_fields";
@@ -0,0 +1,35 @@
+-- Fields of the employee profiles defined by the admins of an organization,
+-- without a migration per field. The pattern validates the values of text
+-- fields, and only the holders of the permission see the field when set.
+CREATE TABLE IF NOT EXISTS "profile_fields" (
+    "id" uuid PRIMARY KEY DEFAULT (uuid_generate_v4()),
+    "key" varchar NOT NULL,
+    "label" varchar NOT NULL,
+    "type" varchar NOT NULL,
+    "required" boolean NOT NULL DEFAULT FALSE,
+    "pattern" varchar DEFAULT NULL,
+    "permission" varchar DEFAULT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (now()),
+    "organization_id" uuid NOT NULL
+);
+
+-- The values of the profile fields of the employee, by key.
+ALTER TABLE "users" ADD COLUMN "profile" jsonb NOT NULL DEFAULT ('{}');
+
+CREATE INDEX ON "users" USING gin ("profile" jsonb_path_ops);
+
+ALTER TABLE "profile_fields" ADD CONSTRAINT "profile_fields_organization_key_key" UNIQUE (
+    "organization_id", "key"
+);
+
+ALTER TABLE "profile_fields" ADD CONSTRAINT "profile_fields_organization" FOREIGN KEY (
+    "organization_id"
+) REFERENCES "organizations" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "profile_fields" ENABLE ROW LEVEL SECURITY;
+
+ALTER TABLE "profile_fields" FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY "tenant_isolation" ON "profile_fields" USING (
+    "organization_id" = current_organization_id()
+);
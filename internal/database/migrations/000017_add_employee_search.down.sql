@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS "users_employee_search_vector_idx";
+
+DROP INDEX IF EXISTS "users_unaccent_immutable_idx";
+
+DROP FUNCTION IF EXISTS "employee_search_vector"(varchar, varchar);
+
+DROP FUNCTION IF EXISTS "unaccent_immutable"(text);
+
+DROP TEXT SEARCH CONFIGURATION IF EXISTS "unaccented";
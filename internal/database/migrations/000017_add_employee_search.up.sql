@@ -0,0 +1,29 @@
+CREATE EXTENSION IF NOT EXISTS "unaccent"; -- noqa: L057
+
+CREATE EXTENSION IF NOT EXISTS "pg_trgm"; -- noqa: L057
+
+-- Like the simple configuration, without the accents, so "joao" matches
+-- "João". ts_headline highlights the words of the original text with it.
+CREATE TEXT SEARCH CONFIGURATION "unaccented" (COPY = simple);
+
+ALTER TEXT SEARCH CONFIGURATION "unaccented"
+ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+
+-- unaccent is only stable, as its dictionary could change, which prevents
+-- indexing it. The dictionary is fixed here to make it immutable.
+CREATE OR REPLACE FUNCTION "unaccent_immutable"(text) RETURNS text AS $$
+    SELECT public.unaccent('public.unaccent', $1);
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+
+-- The document the employee directory is searched in, names weighting more
+-- than email addresses.
+CREATE OR REPLACE FUNCTION "employee_search_vector"(
+    "name" varchar, "email" varchar
+) RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector('unaccented', "name"), 'A')
+        || setweight(to_tsvector('unaccented', "email"), 'B');
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+
+CREATE INDEX ON "users" USING gin (employee_search_vector("name", "email"));
+
+CREATE INDEX ON "users" USING gin (unaccent_immutable("name") gin_trgm_ops);
@@ -0,0 +1,5 @@
+ALTER TABLE "organizations" DROP COLUMN IF EXISTS "scim_token_created_at";
+
+ALTER TABLE "organizations" DROP COLUMN IF EXISTS "scim_hashed_token";
+
+ALTER TABLE "organizations" DROP COLUMN IF EXISTS "scim_token_id";
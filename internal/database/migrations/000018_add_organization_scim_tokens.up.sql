@@ -0,0 +1,8 @@
+-- The bearer token of the SCIM endpoints of the organization, stored like the
+-- API keys: its ID finds the organization it belongs to, and only its hash is
+-- kept. SCIM is disabled for the organizations without one.
+ALTER TABLE "organizations" ADD COLUMN "scim_token_id" varchar UNIQUE DEFAULT NULL;
+
+ALTER TABLE "organizations" ADD COLUMN "scim_hashed_token" varchar DEFAULT NULL;
+
+ALTER TABLE "organizations" ADD COLUMN "scim_token_created_at" timestamp DEFAULT NULL;
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"embed"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var Files embed.FS
+
+// LatestVersion returns the version of the newest migration, which is the
+// version the database schema is expected to be at.
+func LatestVersion() (int64, error) {
+	entries, err := Files.ReadDir(".")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+
+	for _, entry := range entries {
+		prefix, _, found := strings.Cut(entry.Name(), "_")
+		if !found {
+			continue
+		}
+
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		latest = max(latest, version)
+	}
+
+	return latest, nil
+}
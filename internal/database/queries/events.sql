@@ -0,0 +1,32 @@
+-- name: CreateEvent :one
+INSERT INTO
+"events" ("type", "data", "organization_id")
+VALUES
+($1, $2, $3)
+RETURNING *;
+
+-- name: GetEventBySequence :one
+SELECT *
+FROM "events"
+WHERE
+    "sequence" = $1
+    AND "organization_id" = $2;
+
+-- name: GetEventsAfterSequence :many
+SELECT *
+FROM "events"
+WHERE
+    "sequence" > $1
+    AND "organization_id" = $3
+ORDER BY "sequence"
+LIMIT $2;
+
+-- name: GetLatestEventSequence :one
+SELECT coalesce(max("sequence"), 0)::bigint AS "sequence"
+FROM "events"
+WHERE "organization_id" = $1;
+
+-- name: LockEvents :exec
+-- Serializes the events of the organization until the end of the transaction,
+-- so they take their sequences in the order they are committed in.
+SELECT pg_advisory_xact_lock(hashtextextended((@organization_id::uuid)::text, 0));
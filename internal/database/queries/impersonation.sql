@@ -0,0 +1,30 @@
+-- name: CreateImpersonationSession :one
+INSERT INTO
+"impersonation_sessions" ("actor_id", "subject_id", "reason", "expires_at", "organization_id")
+VALUES
+($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetActiveImpersonationSession :one
+SELECT *
+FROM "impersonation_sessions"
+WHERE
+    "id" = $1
+    AND "ended_at" IS NULL
+    AND "organization_id" = $2;
+
+-- name: EndImpersonationSession :one
+UPDATE "impersonation_sessions"
+SET "ended_at" = now()
+WHERE
+    "id" = $1
+    AND "ended_at" IS NULL
+    AND "organization_id" = $2
+RETURNING *;
+
+-- name: GetImpersonationSessions :many
+SELECT *
+FROM "impersonation_sessions"
+WHERE "organization_id" = $2
+ORDER BY "started_at" DESC
+LIMIT $1;
@@ -0,0 +1,37 @@
+-- name: GetLDAPAccount :one
+SELECT *
+FROM "ldap_accounts"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: GetLDAPAccountByDN :one
+SELECT *
+FROM "ldap_accounts"
+WHERE
+    "dn" = $1
+    AND "organization_id" = $2;
+
+-- name: UpsertLDAPAccount :exec
+INSERT INTO
+"ldap_accounts" ("user_id", "dn", "organization_id")
+VALUES
+($1, $2, $3)
+ON CONFLICT ("user_id") DO UPDATE
+SET
+    "dn" = excluded."dn",
+    "synced_at" = now();
+
+-- name: DeactivateMissingLDAPUsers :many
+-- Deactivates the synchronized employees whose entry is no longer in the
+-- directory.
+UPDATE "users"
+SET "status" = 'deactivated'
+FROM "ldap_accounts"
+WHERE
+    "users"."id" = "ldap_accounts"."user_id"
+    AND NOT ("ldap_accounts"."dn" = any(@dns::varchar[]))
+    AND "users"."status" NOT IN ('deactivated', 'terminated')
+    AND "users"."deleted_at" IS NULL
+    AND "users"."organization_id" = @organization_id
+RETURNING "users".*;
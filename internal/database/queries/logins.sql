@@ -0,0 +1,53 @@
+-- name: GetAccountLockRemaining :one
+SELECT coalesce(extract(EPOCH FROM "locked_until" - now()), 0)::float8 AS "seconds"
+FROM "account_lockouts"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: RecordFailedLogin :one
+INSERT INTO
+"account_lockouts" ("user_id", "failed_attempts", "organization_id")
+VALUES
+($1, 1, $2)
+ON CONFLICT ("user_id") DO UPDATE
+SET
+    "failed_attempts" = "account_lockouts"."failed_attempts" + 1,
+    "updated_at" = now()
+RETURNING *;
+
+-- name: LockAccount :exec
+UPDATE "account_lockouts"
+SET
+    "failed_attempts" = 0,
+    "lockouts" = "lockouts" + 1,
+    "locked_until" = now() + make_interval(secs => @cooldown_seconds::float8),
+    "updated_at" = now()
+WHERE
+    "user_id" = @user_id
+    AND "organization_id" = @organization_id;
+
+-- name: DeleteAccountLockout :execrows
+DELETE FROM "account_lockouts"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: RecordKnownLogin :one
+WITH "previous" AS (
+    SELECT count(*) AS "logins"
+    FROM "known_logins"
+    WHERE
+        "known_logins"."user_id" = $1
+        AND "known_logins"."organization_id" = $4
+)
+
+INSERT INTO
+"known_logins" ("user_id", "ip", "user_agent", "organization_id")
+VALUES
+($1, $2, $3, $4)
+ON CONFLICT ("user_id", "ip", "user_agent") DO UPDATE
+SET "last_seen_at" = now()
+RETURNING
+    ("xmax" = 0)::boolean AS "new",
+    (SELECT "logins" FROM "previous")::bigint AS "previous_logins";
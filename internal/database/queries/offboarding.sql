@@ -0,0 +1,100 @@
+-- name: ScheduleOffboarding :one
+-- Schedules the offboarding of the employee, or reschedules it when it
+-- isn't completed yet.
+INSERT INTO
+"offboardings" ("user_id", "manager_id", "terminates_at", "requested_by", "organization_id")
+VALUES
+($1, $2, $3, $4, $5)
+ON CONFLICT ("user_id") DO UPDATE
+SET
+    "manager_id" = excluded."manager_id",
+    "terminates_at" = excluded."terminates_at",
+    "requested_by" = excluded."requested_by",
+    "created_at" = now()
+WHERE "offboardings"."completed_at" IS NULL
+RETURNING *;
+
+-- name: GetOffboarding :one
+SELECT *
+FROM "offboardings"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: CancelOffboarding :execrows
+DELETE FROM "offboardings"
+WHERE
+    "user_id" = $1
+    AND "completed_at" IS NULL
+    AND "organization_id" = $2;
+
+-- name: GetDueOffboardings :many
+-- The offboardings of deleted employees, or to deleted managers, wait for them
+-- to be restored or for the offboarding to be rescheduled.
+SELECT "offboardings"."user_id"
+FROM "offboardings"
+INNER JOIN "users" AS "employees" ON "offboardings"."user_id" = "employees"."id"
+INNER JOIN "users" AS "managers" ON "offboardings"."manager_id" = "managers"."id"
+WHERE
+    "offboardings"."completed_at" IS NULL
+    AND "offboardings"."terminates_at" <= @now
+    AND "offboardings"."organization_id" = @organization_id
+    AND "employees"."deleted_at" IS NULL
+    AND "managers"."deleted_at" IS NULL
+ORDER BY "offboardings"."terminates_at"
+LIMIT sqlc.arg('limit');
+
+-- name: LockDueOffboarding :one
+-- Locks an offboarding still to complete, skipping it when another instance
+-- is completing it.
+SELECT *
+FROM "offboardings"
+WHERE
+    "user_id" = $1
+    AND "completed_at" IS NULL
+    AND "organization_id" = $2
+FOR UPDATE SKIP LOCKED;
+
+-- name: CompleteOffboarding :exec
+UPDATE "offboardings"
+SET "completed_at" = now()
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: ArchiveRolesForUser :exec
+-- Copies the role grants of the employee to the revoked roles, before they
+-- are deleted.
+INSERT INTO "revoked_roles" ("user_id", "role_id", "grantor", "granted_at", "organization_id")
+SELECT
+    "users_roles"."user_id",
+    "users_roles"."role_id",
+    "users_roles"."grantor",
+    "users_roles"."granted_at",
+    "users_roles"."organization_id"
+FROM "users_roles"
+WHERE
+    "users_roles"."user_id" = $1
+    AND "users_roles"."organization_id" = $2;
+
+-- name: ReassignWebhooks :execrows
+UPDATE "webhooks"
+SET "created_by" = @to_user_id
+WHERE
+    "created_by" = @from_user_id
+    AND "organization_id" = @organization_id;
+
+-- name: ReassignServiceAccounts :execrows
+UPDATE "service_accounts"
+SET "created_by" = @to_user_id
+WHERE
+    "created_by" = @from_user_id
+    AND "organization_id" = @organization_id;
+
+-- name: EndImpersonationSessionsForEmployee :exec
+UPDATE "impersonation_sessions"
+SET "ended_at" = now()
+WHERE
+    "ended_at" IS NULL
+    AND ("actor_id" = $1 OR "subject_id" = $1)
+    AND "organization_id" = $2;
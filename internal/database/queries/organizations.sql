@@ -0,0 +1,48 @@
+-- name: GetOrganizations :many
+SELECT *
+FROM "organizations"
+ORDER BY "slug";
+
+-- name: GetOrganization :one
+SELECT *
+FROM "organizations"
+WHERE "id" = $1;
+
+-- name: GetOrganizationBySlug :one
+SELECT *
+FROM "organizations"
+WHERE "slug" = $1;
+
+-- name: GetOrganizationBySCIMTokenID :one
+SELECT *
+FROM "organizations"
+WHERE "scim_token_id" = $1;
+
+-- name: SetOrganizationSCIMToken :one
+UPDATE "organizations"
+SET
+    "scim_token_id" = @scim_token_id,
+    "scim_hashed_token" = @scim_hashed_token,
+    "scim_token_created_at" = now()
+WHERE "id" = @id
+RETURNING *;
+
+-- name: DeleteOrganizationSCIMToken :one
+UPDATE "organizations"
+SET
+    "scim_token_id" = NULL,
+    "scim_hashed_token" = NULL,
+    "scim_token_created_at" = NULL
+WHERE "id" = $1
+RETURNING *;
+
+-- name: UpdateOrganization :one
+UPDATE "organizations"
+SET
+    "name" = @name,
+    "email_sender" = sqlc.narg('email_sender'),
+    "brand_name" = sqlc.narg('brand_name'),
+    "brand_color" = sqlc.narg('brand_color'),
+    "logo_url" = sqlc.narg('logo_url')
+WHERE "id" = @id
+RETURNING *;
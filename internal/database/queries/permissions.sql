@@ -0,0 +1,14 @@
+-- name: GetPermissionsForEmployee :many
+SELECT DISTINCT "permissions"."display_name"
+FROM "permissions"
+INNER JOIN
+    "roles_permissions"
+    ON "permissions"."id" = "roles_permissions"."permission_id"
+INNER JOIN
+    "users_roles"
+    ON "roles_permissions"."role_id" = "users_roles"."role_id"
+INNER JOIN "roles" ON "users_roles"."role_id" = "roles"."id"
+WHERE
+    "users_roles"."user_id" = $1
+    AND "users_roles"."organization_id" = $2
+    AND "roles"."deleted_at" IS NULL;
@@ -0,0 +1,135 @@
+-- name: GetRoleGrantsForUser :many
+SELECT
+    "roles"."id",
+    "roles"."display_name",
+    "users_roles"."grantor",
+    "users_roles"."granted_at"
+FROM "roles"
+INNER JOIN "users_roles" ON "roles"."id" = "users_roles"."role_id"
+WHERE
+    "users_roles"."user_id" = $1
+    AND "users_roles"."organization_id" = $2
+ORDER BY "users_roles"."granted_at";
+
+-- name: GetRevokedRolesForUser :many
+SELECT
+    "roles"."id",
+    "roles"."display_name",
+    "revoked_roles"."grantor",
+    "revoked_roles"."granted_at",
+    "revoked_roles"."revoked_at"
+FROM "roles"
+INNER JOIN "revoked_roles" ON "roles"."id" = "revoked_roles"."role_id"
+WHERE
+    "revoked_roles"."user_id" = $1
+    AND "revoked_roles"."organization_id" = $2
+ORDER BY "revoked_roles"."revoked_at";
+
+-- name: GetRoleGrantsByGrantor :many
+SELECT
+    "user_id",
+    "role_id",
+    "granted_at"
+FROM "users_roles"
+WHERE
+    "grantor" = $1
+    AND "organization_id" = $2
+ORDER BY "granted_at";
+
+-- name: GetKnownLoginsForUser :many
+SELECT *
+FROM "known_logins"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2
+ORDER BY "first_seen_at";
+
+-- name: GetAccountLockout :one
+SELECT *
+FROM "account_lockouts"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: GetImpersonationSessionsForEmployee :many
+SELECT *
+FROM "impersonation_sessions"
+WHERE
+    ("actor_id" = $1 OR "subject_id" = $1)
+    AND "organization_id" = $2
+ORDER BY "started_at";
+
+-- name: GetEventsForEmployee :many
+SELECT *
+FROM "events"
+WHERE
+    "data" ->> 'id' = @employee_id::text
+    AND "organization_id" = @organization_id
+ORDER BY "sequence";
+
+-- name: GetWebhooksCreatedBy :many
+SELECT *
+FROM "webhooks"
+WHERE
+    "created_by" = $1
+    AND "organization_id" = $2
+ORDER BY "created_at";
+
+-- name: GetServiceAccountsCreatedBy :many
+SELECT *
+FROM "service_accounts"
+WHERE
+    "created_by" = $1
+    AND "organization_id" = $2
+ORDER BY "created_at";
+
+-- name: GetErasure :one
+SELECT *
+FROM "erasures"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: CreateErasure :one
+INSERT INTO
+"erasures" ("user_id", "erased_by", "organization_id")
+VALUES
+($1, $2, $3)
+RETURNING *;
+
+-- name: DeleteKnownLoginsForUser :exec
+DELETE FROM "known_logins"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: DeleteLDAPAccount :exec
+DELETE FROM "ldap_accounts"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: PseudonymizeEvents :execrows
+-- Replaces the name and email address of the employee in the events about
+-- them.
+UPDATE "events"
+SET "data" = "data" || jsonb_build_object('name', @name::varchar, 'email', @email::varchar)
+WHERE
+    "data" ->> 'id' = @employee_id::text
+    AND "data" ? 'email'
+    AND "organization_id" = @organization_id;
+
+-- name: PseudonymizeWebhookDeliveries :execrows
+-- Replaces the name and email address of the employee in the payloads of the
+-- deliveries of events about them.
+UPDATE "webhook_deliveries"
+SET
+    "payload" = jsonb_set(
+        jsonb_set("payload", '{data,name}', to_jsonb(@name::varchar)),
+        '{data,email}',
+        to_jsonb(@email::varchar)
+    )
+WHERE
+    "payload" -> 'data' ->> 'id' = @employee_id::text
+    AND "payload" -> 'data' ? 'email'
+    AND "organization_id" = @organization_id;
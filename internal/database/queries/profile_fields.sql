@@ -0,0 +1,47 @@
+-- name: GetProfileFields :many
+SELECT *
+FROM "profile_fields"
+WHERE "organization_id" = $1
+ORDER BY "key";
+
+-- name: GetProfileField :one
+SELECT *
+FROM "profile_fields"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2;
+
+-- name: CreateProfileField :one
+INSERT INTO
+"profile_fields" (
+    "key", "label", "type", "required", "pattern", "permission", "organization_id"
+)
+VALUES
+($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: UpdateProfileField :one
+UPDATE "profile_fields"
+SET
+    "label" = $2,
+    "required" = $3,
+    "pattern" = $4,
+    "permission" = $5
+WHERE
+    "id" = $1
+    AND "organization_id" = $6
+RETURNING *;
+
+-- name: DeleteProfileField :execrows
+DELETE FROM "profile_fields"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2;
+
+-- name: RemoveProfileFieldValues :exec
+-- Removes the values of a deleted field from the profiles of the employees.
+UPDATE "users"
+SET "profile" = "profile" - @key::varchar
+WHERE
+    "profile" ? @key::varchar
+    AND "organization_id" = @organization_id;
@@ -0,0 +1,39 @@
+-- name: TakeRateLimitToken :one
+-- The burst and rate are bound once, in "bucket", as sqlc can't rewrite named
+-- parameters inside a row-valued SET.
+WITH "bucket" AS (
+    SELECT
+        @burst::float8 AS "burst",
+        @rate::float8 AS "rate"
+)
+INSERT INTO
+"rate_limits" ("key", "tokens", "allowed", "updated_at", "full_at")
+SELECT
+    @key,
+    "bucket"."burst" - 1,
+    TRUE,
+    now(),
+    now() + make_interval(secs => 1 / "bucket"."rate")
+FROM "bucket"
+ON CONFLICT ("key") DO UPDATE
+SET ("tokens", "allowed", "updated_at", "full_at") = (
+    SELECT
+        CASE WHEN "refill"."tokens" >= 1 THEN "refill"."tokens" - 1 ELSE "refill"."tokens" END,
+        "refill"."tokens" >= 1,
+        now(),
+        now() + make_interval(secs => (
+            "bucket"."burst" - "refill"."tokens" + CASE WHEN "refill"."tokens" >= 1 THEN 1 ELSE 0 END
+        ) / "bucket"."rate")
+    FROM "bucket"
+    CROSS JOIN LATERAL (
+        SELECT least(
+            "bucket"."burst",
+            "rate_limits"."tokens" + extract(EPOCH FROM now() - "rate_limits"."updated_at")::float8 * "bucket"."rate"
+        ) AS "tokens"
+    ) AS "refill"
+)
+RETURNING "tokens", "allowed";
+
+-- name: DeleteFullRateLimits :execrows
+DELETE FROM "rate_limits"
+WHERE "full_at" < now();
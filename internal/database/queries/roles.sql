@@ -0,0 +1,164 @@
+-- name: GetRoles :many
+SELECT
+    "id",
+    "display_name",
+    "description"
+FROM "roles"
+WHERE
+    "organization_id" = $1
+    AND "deleted_at" IS NULL;
+
+-- name: GetRoleByDisplayName :one
+SELECT *
+FROM "roles"
+WHERE
+    "display_name" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL;
+
+-- name: GetRole :one
+SELECT *
+FROM "roles"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL;
+
+-- name: ListRoles :many
+SELECT *
+FROM "roles"
+WHERE
+    (
+        sqlc.narg('display_name')::varchar IS NULL
+        OR "display_name" = sqlc.narg('display_name')
+    )
+    AND "organization_id" = @organization_id
+    AND "deleted_at" IS NULL
+ORDER BY "display_name"
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountRoles :one
+SELECT count(*)
+FROM "roles"
+WHERE
+    (
+        sqlc.narg('display_name')::varchar IS NULL
+        OR "display_name" = sqlc.narg('display_name')
+    )
+    AND "organization_id" = @organization_id
+    AND "deleted_at" IS NULL;
+
+-- name: CreateRole :one
+INSERT INTO "roles" ("display_name", "description", "organization_id")
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: UpdateRoleDisplayName :execrows
+UPDATE "roles"
+SET "display_name" = $2
+WHERE
+    "id" = $1
+    AND "organization_id" = $3
+    AND "deleted_at" IS NULL;
+
+-- name: DeleteRole :execrows
+UPDATE "roles"
+SET "deleted_at" = now()
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL;
+
+-- name: RestoreRole :one
+UPDATE "roles"
+SET "deleted_at" = NULL
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NOT NULL
+RETURNING *;
+
+-- name: ListPurgeableRoles :many
+SELECT "id"
+FROM "roles"
+WHERE
+    "deleted_at" < @deleted_before
+    AND "organization_id" = @organization_id
+ORDER BY "deleted_at";
+
+-- name: PurgeRole :execrows
+-- Roles revoked from employees are kept as long as their history is.
+DELETE FROM "roles"
+WHERE
+    "roles"."id" = $1
+    AND "roles"."organization_id" = $2
+    AND "roles"."deleted_at" IS NOT NULL
+    AND NOT EXISTS (
+        SELECT 1
+        FROM "revoked_roles"
+        WHERE "revoked_roles"."role_id" = "roles"."id"
+    );
+
+-- name: DeleteRolePermissions :exec
+DELETE FROM "roles_permissions"
+WHERE
+    "role_id" = $1
+    AND "organization_id" = $2;
+
+-- name: GetRoleMembers :many
+SELECT
+    "users"."id",
+    "users"."name"
+FROM "users"
+INNER JOIN "users_roles" ON "users"."id" = "users_roles"."user_id"
+WHERE
+    "users_roles"."role_id" = $1
+    AND "users_roles"."organization_id" = $2
+    AND "users"."deleted_at" IS NULL
+ORDER BY "users"."name";
+
+-- name: AddRoleMembers :exec
+INSERT INTO "users_roles" ("user_id", "role_id", "grantor", "organization_id")
+SELECT
+    "member",
+    @role_id,
+    @grantor,
+    @organization_id
+FROM unnest(@user_ids::uuid[]) AS "member"
+ON CONFLICT ("user_id", "role_id") DO NOTHING;
+
+-- name: GetSystemGrantor :one
+-- No employee grants the roles assigned by the identity provider, the
+-- directory or single sign-on. They are recorded as granted by the first
+-- employee of the organization to hold the admin permission still there,
+-- usually the root user.
+SELECT "users_roles"."user_id"
+FROM "users_roles"
+INNER JOIN
+    "roles_permissions"
+    ON "users_roles"."role_id" = "roles_permissions"."role_id"
+INNER JOIN
+    "permissions"
+    ON "roles_permissions"."permission_id" = "permissions"."id"
+INNER JOIN "roles" ON "users_roles"."role_id" = "roles"."id"
+INNER JOIN "users" ON "users_roles"."user_id" = "users"."id"
+WHERE
+    "permissions"."display_name" = 'admin'
+    AND "users_roles"."organization_id" = @organization_id
+    AND "roles"."deleted_at" IS NULL
+    AND "users"."deleted_at" IS NULL
+ORDER BY "users_roles"."granted_at", "users_roles"."user_id"
+LIMIT 1;
+
+-- name: RemoveRoleMembers :exec
+DELETE FROM "users_roles"
+WHERE
+    "role_id" = @role_id
+    AND "user_id" = any(@user_ids::uuid[])
+    AND "organization_id" = @organization_id;
+
+-- name: DeleteRoleMembers :exec
+DELETE FROM "users_roles"
+WHERE
+    "role_id" = $1
+    AND "organization_id" = $2;
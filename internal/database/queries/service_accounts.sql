@@ -0,0 +1,128 @@
+-- name: GetPermissions :many
+SELECT "display_name"
+FROM "permissions"
+ORDER BY "display_name";
+
+-- name: CreateServiceAccount :one
+INSERT INTO
+"service_accounts" ("name", "description", "created_by", "organization_id")
+VALUES
+($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetServiceAccounts :many
+SELECT *
+FROM "service_accounts"
+WHERE "organization_id" = $1
+ORDER BY "name";
+
+-- name: GetServiceAccountByID :one
+SELECT *
+FROM "service_accounts"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2;
+
+-- name: UpdateServiceAccount :one
+UPDATE "service_accounts"
+SET
+    "name" = @name,
+    "description" = @description,
+    "disabled_at" = CASE
+        WHEN @active::boolean THEN NULL
+        ELSE coalesce("disabled_at", now())
+    END
+WHERE
+    "id" = @id
+    AND "organization_id" = @organization_id
+RETURNING *;
+
+-- name: DeleteServiceAccount :execrows
+DELETE FROM "service_accounts"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2;
+
+-- name: GetPermissionsForServiceAccount :many
+SELECT "permissions"."display_name"
+FROM "permissions"
+INNER JOIN
+    "service_accounts_permissions"
+    ON "permissions"."id" = "service_accounts_permissions"."permission_id"
+WHERE
+    "service_accounts_permissions"."service_account_id" = $1
+    AND "service_accounts_permissions"."organization_id" = $2
+ORDER BY "permissions"."display_name";
+
+-- name: DeleteServiceAccountPermissions :exec
+DELETE FROM "service_accounts_permissions"
+WHERE
+    "service_account_id" = $1
+    AND "organization_id" = $2;
+
+-- name: AddServiceAccountPermissions :exec
+INSERT INTO
+"service_accounts_permissions" ("service_account_id", "permission_id", "organization_id")
+SELECT
+    @service_account_id,
+    "id",
+    @organization_id
+FROM "permissions"
+WHERE "display_name" = any(@permissions::varchar[]);
+
+-- name: CreateAPIKey :one
+INSERT INTO
+"api_keys" (
+    "service_account_id",
+    "name",
+    "key_id",
+    "hashed_key",
+    "scopes",
+    "expires_at",
+    "organization_id"
+)
+VALUES
+($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetAPIKeysForServiceAccount :many
+SELECT *
+FROM "api_keys"
+WHERE
+    "service_account_id" = $1
+    AND "organization_id" = $2
+ORDER BY "created_at";
+
+-- name: GetAPIKeyByKeyID :one
+SELECT
+    "api_keys".*,
+    "service_accounts"."disabled_at" AS "service_account_disabled_at"
+FROM "api_keys"
+INNER JOIN
+    "service_accounts"
+    ON "api_keys"."service_account_id" = "service_accounts"."id"
+WHERE
+    "api_keys"."key_id" = $1
+    AND "api_keys"."organization_id" = $2;
+
+-- name: TouchAPIKey :exec
+-- Records the use of a key at most once a minute, sparing a write per
+-- request.
+UPDATE "api_keys"
+SET "last_used_at" = now()
+WHERE
+    "id" = $1
+    AND (
+        "last_used_at" IS NULL
+        OR "last_used_at" < now() - INTERVAL '1 minute'
+    )
+    AND "organization_id" = $2;
+
+-- name: RevokeAPIKey :execrows
+UPDATE "api_keys"
+SET "revoked_at" = now()
+WHERE
+    "id" = $1
+    AND "service_account_id" = $2
+    AND "revoked_at" IS NULL
+    AND "organization_id" = $3;
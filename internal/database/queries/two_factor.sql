@@ -0,0 +1,110 @@
+-- name: GetTOTPSecret :one
+SELECT *
+FROM "totp_secrets"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: CreatePendingTOTPSecret :execrows
+INSERT INTO
+"totp_secrets" ("user_id", "secret", "organization_id")
+VALUES
+($1, $2, $3)
+ON CONFLICT ("user_id") DO UPDATE
+SET
+    "secret" = excluded."secret",
+    "last_used_step" = 0,
+    "created_at" = now()
+WHERE "totp_secrets"."confirmed_at" IS NULL;
+
+-- name: ConfirmTOTPSecret :execrows
+UPDATE "totp_secrets"
+SET
+    "confirmed_at" = now(),
+    "last_used_step" = $2
+WHERE
+    "user_id" = $1
+    AND "confirmed_at" IS NULL
+    AND "organization_id" = $3;
+
+-- name: UseTOTPStep :execrows
+UPDATE "totp_secrets"
+SET "last_used_step" = $2
+WHERE
+    "user_id" = $1
+    AND "last_used_step" < $2
+    AND "organization_id" = $3;
+
+-- name: UpdateTOTPSecret :exec
+-- Replaces a secret stored in plaintext with the same secret, encrypted.
+UPDATE "totp_secrets"
+SET "secret" = $2
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $3;
+
+-- name: DeleteTOTPSecret :execrows
+DELETE FROM "totp_secrets"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: CreateRecoveryCodes :exec
+INSERT INTO
+"recovery_codes" ("user_id", "hashed_code", "organization_id")
+SELECT
+    @user_id,
+    unnest(@hashed_codes::varchar[]),
+    @organization_id;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM "recovery_codes"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: UseRecoveryCode :execrows
+UPDATE "recovery_codes"
+SET "used_at" = now()
+WHERE
+    "user_id" = $1
+    AND "hashed_code" = $2
+    AND "used_at" IS NULL
+    AND "organization_id" = $3;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT count(*)
+FROM "recovery_codes"
+WHERE
+    "user_id" = $1
+    AND "used_at" IS NULL
+    AND "organization_id" = $2;
+
+-- name: EmployeeRequiresTwoFactor :one
+SELECT EXISTS (
+    SELECT 1
+    FROM "users_roles"
+    INNER JOIN "two_factor_roles" ON "users_roles"."role_id" = "two_factor_roles"."role_id"
+    INNER JOIN "roles" ON "users_roles"."role_id" = "roles"."id"
+    WHERE
+        "users_roles"."user_id" = $1
+        AND "users_roles"."organization_id" = $2
+        AND "roles"."deleted_at" IS NULL
+);
+
+-- name: GetTwoFactorRoles :many
+SELECT "role_id"
+FROM "two_factor_roles"
+WHERE "organization_id" = $1
+ORDER BY "role_id";
+
+-- name: DeleteTwoFactorRoles :exec
+DELETE FROM "two_factor_roles"
+WHERE "organization_id" = $1;
+
+-- name: AddTwoFactorRoles :exec
+INSERT INTO
+"two_factor_roles" ("role_id", "organization_id")
+SELECT
+    unnest(@role_ids::uuid[]),
+    @organization_id;
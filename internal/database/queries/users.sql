@@ -1,9 +1,9 @@
 -- name: CreateUser :one
 INSERT INTO
-"users" ("name", "email", "status", "hashed_password")
+"users" ("name", "email", "status", "hashed_password", "organization_id")
 VALUES
-($1, $2, $3, $4)
-RETURNING "id", "name", "email", "status";
+($1, $2, $3, $4, $5)
+RETURNING *;
 
 -- name: UpdateUser :exec
 UPDATE "users"
@@ -14,6 +14,7 @@ SET
     "status" = $5
 WHERE
     "id" = $1
+    AND "organization_id" = $6
 RETURNING "id", "name", "email", "status";
 
 -- name: GetUserByID :one
@@ -23,13 +24,182 @@ SELECT
     "status",
     "hashed_password"
 FROM "users"
-WHERE "id" = $1;
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL;
 
 -- name: GetUserByEmail :one
+-- Emails are compared regardless of case, like in UserEmailExists, as
+-- providers don't all keep the case of the address the employee was created
+-- with.
+SELECT *
+FROM "users"
+WHERE
+    lower("email") = lower(@email)
+    AND "organization_id" = @organization_id
+    AND "deleted_at" IS NULL;
+
+-- name: UpdateUserProfile :exec
+UPDATE "users"
+SET "profile" = $2
+WHERE
+    "id" = $1
+    AND "organization_id" = $3
+    AND "deleted_at" IS NULL;
+
+-- name: UpdateUserPassword :execrows
+UPDATE "users"
+SET "hashed_password" = sqlc.arg('new_hashed_password')
+WHERE
+    "id" = sqlc.arg('id')
+    AND "hashed_password" = sqlc.arg('old_hashed_password')
+    AND "organization_id" = sqlc.arg('organization_id')
+    AND "deleted_at" IS NULL;
+
+-- name: GetUser :one
+SELECT *
+FROM "users"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL;
+
+-- name: ListUsers :many
+SELECT *
+FROM "users"
+WHERE
+    (
+        sqlc.narg('email')::varchar IS NULL
+        OR lower("email") = lower(sqlc.narg('email'))
+    )
+    AND (
+        sqlc.narg('profile')::jsonb IS NULL
+        OR "profile" @> sqlc.narg('profile')
+    )
+    AND ("deleted_at" IS NOT NULL) = @deleted::boolean
+    AND "organization_id" = @organization_id
+ORDER BY "email"
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountUsers :one
+SELECT count(*)
+FROM "users"
+WHERE
+    (
+        sqlc.narg('email')::varchar IS NULL
+        OR lower("email") = lower(sqlc.narg('email'))
+    )
+    AND (
+        sqlc.narg('profile')::jsonb IS NULL
+        OR "profile" @> sqlc.narg('profile')
+    )
+    AND ("deleted_at" IS NOT NULL) = @deleted::boolean
+    AND "organization_id" = @organization_id;
+
+-- name: SearchUsers :many
+-- Finds the employees with a name or email address containing words starting
+-- with the words of the query, accents ignored, and the ones with a name
+-- similar to the query, to tolerate typos. The full-text matches rank first.
+-- The names and email addresses are escaped before the matches are
+-- highlighted, so the highlights are safe to render as HTML.
 SELECT
+    "id",
     "name",
     "email",
     "status",
-    "hashed_password"
+    "profile",
+    ts_rank(
+        employee_search_vector("name", "email"),
+        to_tsquery('unaccented', @query)
+    ) AS "rank",
+    word_similarity(
+        unaccent_immutable(@term), unaccent_immutable("name")
+    ) AS "similarity",
+    ts_headline(
+        'unaccented',
+        replace(replace(replace("name", '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        to_tsquery('unaccented', @query),
+        'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'
+    )::text AS "name_highlight",
+    ts_headline(
+        'unaccented',
+        replace(replace(replace("email", '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        to_tsquery('unaccented', @query),
+        'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'
+    )::text AS "email_highlight"
+FROM "users"
+WHERE
+    (
+        employee_search_vector("name", "email")
+        @@ to_tsquery('unaccented', @query)
+        OR unaccent_immutable(@term) <% unaccent_immutable("name")
+    )
+    AND ("status" = 'active' OR @inactive::boolean)
+    AND "deleted_at" IS NULL
+    AND "organization_id" = @organization_id
+ORDER BY "rank" DESC, "similarity" DESC, "name"
+LIMIT sqlc.arg('limit');
+
+-- name: UserEmailExists :one
+-- Deleted employees keep their email address until they're purged, so it's
+-- checked against them too.
+SELECT EXISTS (
+    SELECT 1
+    FROM "users"
+    WHERE
+        lower("email") = lower(@email)
+        AND "organization_id" = @organization_id
+);
+
+-- name: DeleteUser :execrows
+UPDATE "users"
+SET "deleted_at" = now()
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL;
+
+-- name: RestoreUser :one
+UPDATE "users"
+SET "deleted_at" = NULL
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NOT NULL
+RETURNING *;
+
+-- name: ListPurgeableUsers :many
+SELECT "id"
 FROM "users"
-WHERE "email" = $1;
+WHERE
+    "deleted_at" < @deleted_before
+    AND "organization_id" = @organization_id
+ORDER BY "deleted_at";
+
+-- name: PurgeUser :execrows
+-- Fails with a foreign key violation while other records reference the
+-- employee, such as the roles they granted.
+DELETE FROM "users"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NOT NULL;
+
+-- name: DeleteRolesForUser :exec
+DELETE FROM "users_roles"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2;
+
+-- name: GetRolesForUser :many
+SELECT
+    "roles"."id",
+    "roles"."display_name"
+FROM "roles"
+INNER JOIN "users_roles" ON "roles"."id" = "users_roles"."role_id"
+WHERE
+    "users_roles"."user_id" = $1
+    AND "users_roles"."organization_id" = $2
+    AND "roles"."deleted_at" IS NULL
+ORDER BY "roles"."display_name";
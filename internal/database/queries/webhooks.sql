@@ -0,0 +1,128 @@
+-- name: CreateWebhook :one
+INSERT INTO
+"webhooks" ("url", "event_types", "secret", "created_by", "organization_id")
+VALUES
+($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetWebhooks :many
+SELECT *
+FROM "webhooks"
+WHERE "organization_id" = $1
+ORDER BY "created_at";
+
+-- name: GetWebhookByID :one
+SELECT *
+FROM "webhooks"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2;
+
+-- name: GetActiveWebhooksForEvent :many
+SELECT *
+FROM "webhooks"
+WHERE
+    "active" = TRUE
+    AND @event_type::varchar = ANY("event_types")
+    AND "organization_id" = @organization_id;
+
+-- name: UpdateWebhook :one
+UPDATE "webhooks"
+SET
+    "url" = $2,
+    "event_types" = $3,
+    "active" = $4,
+    "consecutive_failures" = CASE WHEN $4 THEN 0 ELSE "consecutive_failures" END,
+    "disabled_at" = CASE WHEN $4 THEN NULL ELSE coalesce("disabled_at", now()) END
+WHERE
+    "id" = $1
+    AND "organization_id" = $5
+RETURNING *;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM "webhooks"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2;
+
+-- name: ResetWebhookFailures :exec
+UPDATE "webhooks"
+SET "consecutive_failures" = 0
+WHERE
+    "id" = $1
+    AND "organization_id" = $2;
+
+-- name: RecordWebhookFailure :one
+UPDATE "webhooks"
+SET
+    "consecutive_failures" = "consecutive_failures" + 1,
+    "active" = "consecutive_failures" + 1 < @max_failures::integer,
+    "disabled_at" = CASE
+        WHEN "consecutive_failures" + 1 < @max_failures::integer THEN NULL
+        ELSE now()
+    END
+WHERE
+    "id" = @id
+    AND "organization_id" = @organization_id
+RETURNING "active";
+
+-- name: CreateWebhookDelivery :one
+-- Records a delivery attempt still to make, due at next_attempt_at.
+INSERT INTO
+"webhook_deliveries" (
+    "webhook_id",
+    "event_id",
+    "event_type",
+    "payload",
+    "attempt",
+    "succeeded",
+    "duration_ms",
+    "next_attempt_at",
+    "organization_id"
+)
+VALUES
+($1, $2, $3, $4, $5, FALSE, 0, $6, $7)
+RETURNING *;
+
+-- name: GetDueWebhookDeliveries :many
+SELECT "id"
+FROM "webhook_deliveries"
+WHERE
+    "next_attempt_at" <= @now
+    AND "organization_id" = @organization_id
+ORDER BY "next_attempt_at"
+LIMIT sqlc.arg('limit');
+
+-- name: ClaimWebhookDelivery :one
+-- Holds a due delivery attempt until locked_until, while it is made. The
+-- attempt becomes due again past it, in case the instance making it stops
+-- before recording it. No row is returned when another instance holds it.
+UPDATE "webhook_deliveries"
+SET "next_attempt_at" = @locked_until
+WHERE
+    "id" = @id
+    AND "next_attempt_at" <= @now
+    AND "organization_id" = @organization_id
+RETURNING *;
+
+-- name: CompleteWebhookDelivery :exec
+UPDATE "webhook_deliveries"
+SET
+    "status_code" = $2,
+    "error" = $3,
+    "succeeded" = $4,
+    "duration_ms" = $5,
+    "delivered_at" = now(),
+    "next_attempt_at" = NULL
+WHERE
+    "id" = $1
+    AND "organization_id" = $6;
+
+-- name: GetWebhookDeliveries :many
+SELECT *
+FROM "webhook_deliveries"
+WHERE
+    "webhook_id" = $1
+    AND "organization_id" = $3
+ORDER BY "delivered_at" DESC
+LIMIT $2;
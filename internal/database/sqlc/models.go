@@ -9,34 +9,226 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AccountLockout struct {
+	UserID         uuid.UUID        `json:"user_id"`
+	FailedAttempts int32            `json:"failed_attempts"`
+	Lockouts       int32            `json:"lockouts"`
+	LockedUntil    pgtype.Timestamp `json:"locked_until"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type ApiKey struct {
+	ID               uuid.UUID        `json:"id"`
+	ServiceAccountID uuid.UUID        `json:"service_account_id"`
+	Name             string           `json:"name"`
+	KeyID            string           `json:"key_id"`
+	HashedKey        string           `json:"hashed_key"`
+	Scopes           []string         `json:"scopes"`
+	ExpiresAt        pgtype.Timestamp `json:"expires_at"`
+	LastUsedAt       pgtype.Timestamp `json:"last_used_at"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	RevokedAt        pgtype.Timestamp `json:"revoked_at"`
+	OrganizationID   uuid.UUID        `json:"organization_id"`
+}
+
+type Erasure struct {
+	UserID         uuid.UUID        `json:"user_id"`
+	ErasedBy       uuid.UUID        `json:"erased_by"`
+	ErasedAt       pgtype.Timestamp `json:"erased_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type Event struct {
+	ID             uuid.UUID        `json:"id"`
+	Sequence       int64            `json:"sequence"`
+	Type           string           `json:"type"`
+	Data           []byte           `json:"data"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type ImpersonationSession struct {
+	ID             uuid.UUID        `json:"id"`
+	ActorID        uuid.UUID        `json:"actor_id"`
+	SubjectID      uuid.UUID        `json:"subject_id"`
+	Reason         string           `json:"reason"`
+	StartedAt      pgtype.Timestamp `json:"started_at"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+	EndedAt        pgtype.Timestamp `json:"ended_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type KnownLogin struct {
+	UserID         uuid.UUID        `json:"user_id"`
+	Ip             string           `json:"ip"`
+	UserAgent      string           `json:"user_agent"`
+	FirstSeenAt    pgtype.Timestamp `json:"first_seen_at"`
+	LastSeenAt     pgtype.Timestamp `json:"last_seen_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type LdapAccount struct {
+	UserID         uuid.UUID        `json:"user_id"`
+	Dn             string           `json:"dn"`
+	SyncedAt       pgtype.Timestamp `json:"synced_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type Offboarding struct {
+	UserID         uuid.UUID        `json:"user_id"`
+	ManagerID      uuid.UUID        `json:"manager_id"`
+	TerminatesAt   pgtype.Timestamp `json:"terminates_at"`
+	RequestedBy    uuid.UUID        `json:"requested_by"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	CompletedAt    pgtype.Timestamp `json:"completed_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type Organization struct {
+	ID                 uuid.UUID        `json:"id"`
+	Slug               string           `json:"slug"`
+	Name               string           `json:"name"`
+	EmailSender        pgtype.Text      `json:"email_sender"`
+	BrandName          pgtype.Text      `json:"brand_name"`
+	BrandColor         pgtype.Text      `json:"brand_color"`
+	LogoUrl            pgtype.Text      `json:"logo_url"`
+	CreatedAt          pgtype.Timestamp `json:"created_at"`
+	ScimTokenID        pgtype.Text      `json:"scim_token_id"`
+	ScimHashedToken    pgtype.Text      `json:"scim_hashed_token"`
+	ScimTokenCreatedAt pgtype.Timestamp `json:"scim_token_created_at"`
+}
+
 type Permission struct {
 	ID          uuid.UUID `json:"id"`
 	DisplayName string    `json:"display_name"`
 	Description string    `json:"description"`
 }
 
+type ProfileField struct {
+	ID             uuid.UUID        `json:"id"`
+	Key            string           `json:"key"`
+	Label          string           `json:"label"`
+	Type           string           `json:"type"`
+	Required       bool             `json:"required"`
+	Pattern        pgtype.Text      `json:"pattern"`
+	Permission     pgtype.Text      `json:"permission"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type RateLimit struct {
+	Key       string           `json:"key"`
+	Tokens    float64          `json:"tokens"`
+	Allowed   bool             `json:"allowed"`
+	UpdatedAt pgtype.Timestamp `json:"updated_at"`
+	FullAt    pgtype.Timestamp `json:"full_at"`
+}
+
+type RecoveryCode struct {
+	UserID         uuid.UUID        `json:"user_id"`
+	HashedCode     string           `json:"hashed_code"`
+	UsedAt         pgtype.Timestamp `json:"used_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type RevokedRole struct {
+	UserID         uuid.UUID        `json:"user_id"`
+	RoleID         uuid.UUID        `json:"role_id"`
+	Grantor        uuid.UUID        `json:"grantor"`
+	GrantedAt      pgtype.Timestamp `json:"granted_at"`
+	RevokedAt      pgtype.Timestamp `json:"revoked_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
 type Role struct {
-	ID          uuid.UUID `json:"id"`
-	DisplayName string    `json:"display_name"`
-	Description string    `json:"description"`
+	ID             uuid.UUID        `json:"id"`
+	DisplayName    string           `json:"display_name"`
+	Description    string           `json:"description"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+	DeletedAt      pgtype.Timestamp `json:"deleted_at"`
 }
 
 type RolesPermission struct {
-	RoleID       uuid.UUID `json:"role_id"`
-	PermissionID uuid.UUID `json:"permission_id"`
+	RoleID         uuid.UUID `json:"role_id"`
+	PermissionID   uuid.UUID `json:"permission_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+type ServiceAccount struct {
+	ID             uuid.UUID        `json:"id"`
+	Name           string           `json:"name"`
+	Description    string           `json:"description"`
+	CreatedBy      uuid.UUID        `json:"created_by"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	DisabledAt     pgtype.Timestamp `json:"disabled_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type ServiceAccountsPermission struct {
+	ServiceAccountID uuid.UUID `json:"service_account_id"`
+	PermissionID     uuid.UUID `json:"permission_id"`
+	OrganizationID   uuid.UUID `json:"organization_id"`
+}
+
+type TotpSecret struct {
+	UserID         uuid.UUID        `json:"user_id"`
+	Secret         string           `json:"secret"`
+	LastUsedStep   int64            `json:"last_used_step"`
+	ConfirmedAt    pgtype.Timestamp `json:"confirmed_at"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type TwoFactorRole struct {
+	RoleID         uuid.UUID `json:"role_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
 }
 
 type User struct {
-	ID             uuid.UUID   `json:"id"`
-	Name           string      `json:"name"`
-	Email          string      `json:"email"`
-	HashedPassword pgtype.Text `json:"hashed_password"`
-	Status         string      `json:"status"`
+	ID             uuid.UUID        `json:"id"`
+	Name           string           `json:"name"`
+	Email          string           `json:"email"`
+	HashedPassword pgtype.Text      `json:"hashed_password"`
+	Status         string           `json:"status"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+	DeletedAt      pgtype.Timestamp `json:"deleted_at"`
+	Profile        []byte           `json:"profile"`
 }
 
 type UsersRole struct {
-	UserID    uuid.UUID        `json:"user_id"`
-	RoleID    uuid.UUID        `json:"role_id"`
-	Grantor   uuid.UUID        `json:"grantor"`
-	GrantedAt pgtype.Timestamp `json:"granted_at"`
+	UserID         uuid.UUID        `json:"user_id"`
+	RoleID         uuid.UUID        `json:"role_id"`
+	Grantor        uuid.UUID        `json:"grantor"`
+	GrantedAt      pgtype.Timestamp `json:"granted_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+type Webhook struct {
+	ID                  uuid.UUID        `json:"id"`
+	Url                 string           `json:"url"`
+	EventTypes          []string         `json:"event_types"`
+	Secret              string           `json:"secret"`
+	Active              bool             `json:"active"`
+	ConsecutiveFailures int32            `json:"consecutive_failures"`
+	CreatedBy           uuid.UUID        `json:"created_by"`
+	CreatedAt           pgtype.Timestamp `json:"created_at"`
+	DisabledAt          pgtype.Timestamp `json:"disabled_at"`
+	OrganizationID      uuid.UUID        `json:"organization_id"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID        `json:"id"`
+	WebhookID      uuid.UUID        `json:"webhook_id"`
+	EventID        uuid.UUID        `json:"event_id"`
+	EventType      string           `json:"event_type"`
+	Payload        []byte           `json:"payload"`
+	Attempt        int32            `json:"attempt"`
+	StatusCode     pgtype.Int4      `json:"status_code"`
+	Error          pgtype.Text      `json:"error"`
+	Succeeded      bool             `json:"succeeded"`
+	DurationMs     int32            `json:"duration_ms"`
+	DeliveredAt    pgtype.Timestamp `json:"delivered_at"`
+	NextAttemptAt  pgtype.Timestamp `json:"next_attempt_at"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
 }
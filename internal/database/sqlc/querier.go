@@ -8,13 +8,187 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
-	CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error)
-	GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error)
-	GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow, error)
+	AddRoleMembers(ctx context.Context, arg AddRoleMembersParams) error
+	AddServiceAccountPermissions(ctx context.Context, arg AddServiceAccountPermissionsParams) error
+	AddTwoFactorRoles(ctx context.Context, arg AddTwoFactorRolesParams) error
+	// Copies the role grants of the employee to the revoked roles, before they
+	// are deleted.
+	ArchiveRolesForUser(ctx context.Context, arg ArchiveRolesForUserParams) error
+	CancelOffboarding(ctx context.Context, arg CancelOffboardingParams) (int64, error)
+	// Holds a due delivery attempt until locked_until, while it is made. The
+	// attempt becomes due again past it, in case the instance making it stops
+	// before recording it. No row is returned when another instance holds it.
+	ClaimWebhookDelivery(ctx context.Context, arg ClaimWebhookDeliveryParams) (WebhookDelivery, error)
+	CompleteOffboarding(ctx context.Context, arg CompleteOffboardingParams) error
+	CompleteWebhookDelivery(ctx context.Context, arg CompleteWebhookDeliveryParams) error
+	ConfirmTOTPSecret(ctx context.Context, arg ConfirmTOTPSecretParams) (int64, error)
+	CountRoles(ctx context.Context, arg CountRolesParams) (int64, error)
+	CountUnusedRecoveryCodes(ctx context.Context, arg CountUnusedRecoveryCodesParams) (int64, error)
+	CountUsers(ctx context.Context, arg CountUsersParams) (int64, error)
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
+	CreateErasure(ctx context.Context, arg CreateErasureParams) (Erasure, error)
+	CreateEvent(ctx context.Context, arg CreateEventParams) (Event, error)
+	CreateImpersonationSession(ctx context.Context, arg CreateImpersonationSessionParams) (ImpersonationSession, error)
+	CreatePendingTOTPSecret(ctx context.Context, arg CreatePendingTOTPSecretParams) (int64, error)
+	CreateProfileField(ctx context.Context, arg CreateProfileFieldParams) (ProfileField, error)
+	CreateRecoveryCodes(ctx context.Context, arg CreateRecoveryCodesParams) error
+	CreateRole(ctx context.Context, arg CreateRoleParams) (Role, error)
+	CreateServiceAccount(ctx context.Context, arg CreateServiceAccountParams) (ServiceAccount, error)
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	// Records a delivery attempt still to make, due at next_attempt_at.
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error)
+	// Deactivates the synchronized employees whose entry is no longer in the
+	// directory.
+	DeactivateMissingLDAPUsers(ctx context.Context, arg DeactivateMissingLDAPUsersParams) ([]User, error)
+	DeleteAccountLockout(ctx context.Context, arg DeleteAccountLockoutParams) (int64, error)
+	DeleteFullRateLimits(ctx context.Context) (int64, error)
+	DeleteKnownLoginsForUser(ctx context.Context, arg DeleteKnownLoginsForUserParams) error
+	DeleteLDAPAccount(ctx context.Context, arg DeleteLDAPAccountParams) error
+	DeleteOrganizationSCIMToken(ctx context.Context, id uuid.UUID) (Organization, error)
+	DeleteProfileField(ctx context.Context, arg DeleteProfileFieldParams) (int64, error)
+	DeleteRecoveryCodes(ctx context.Context, arg DeleteRecoveryCodesParams) error
+	DeleteRole(ctx context.Context, arg DeleteRoleParams) (int64, error)
+	DeleteRoleMembers(ctx context.Context, arg DeleteRoleMembersParams) error
+	DeleteRolePermissions(ctx context.Context, arg DeleteRolePermissionsParams) error
+	DeleteRolesForUser(ctx context.Context, arg DeleteRolesForUserParams) error
+	DeleteServiceAccount(ctx context.Context, arg DeleteServiceAccountParams) (int64, error)
+	DeleteServiceAccountPermissions(ctx context.Context, arg DeleteServiceAccountPermissionsParams) error
+	DeleteTOTPSecret(ctx context.Context, arg DeleteTOTPSecretParams) (int64, error)
+	DeleteTwoFactorRoles(ctx context.Context, organizationID uuid.UUID) error
+	DeleteUser(ctx context.Context, arg DeleteUserParams) (int64, error)
+	DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error)
+	EmployeeRequiresTwoFactor(ctx context.Context, arg EmployeeRequiresTwoFactorParams) (bool, error)
+	EndImpersonationSession(ctx context.Context, arg EndImpersonationSessionParams) (ImpersonationSession, error)
+	EndImpersonationSessionsForEmployee(ctx context.Context, arg EndImpersonationSessionsForEmployeeParams) error
+	GetAPIKeyByKeyID(ctx context.Context, arg GetAPIKeyByKeyIDParams) (GetAPIKeyByKeyIDRow, error)
+	GetAPIKeysForServiceAccount(ctx context.Context, arg GetAPIKeysForServiceAccountParams) ([]ApiKey, error)
+	GetAccountLockRemaining(ctx context.Context, arg GetAccountLockRemainingParams) (float64, error)
+	GetAccountLockout(ctx context.Context, arg GetAccountLockoutParams) (AccountLockout, error)
+	GetActiveImpersonationSession(ctx context.Context, arg GetActiveImpersonationSessionParams) (ImpersonationSession, error)
+	GetActiveWebhooksForEvent(ctx context.Context, arg GetActiveWebhooksForEventParams) ([]Webhook, error)
+	// The offboardings of deleted employees, or to deleted managers, wait for them
+	// to be restored or for the offboarding to be rescheduled.
+	GetDueOffboardings(ctx context.Context, arg GetDueOffboardingsParams) ([]uuid.UUID, error)
+	GetDueWebhookDeliveries(ctx context.Context, arg GetDueWebhookDeliveriesParams) ([]uuid.UUID, error)
+	GetErasure(ctx context.Context, arg GetErasureParams) (Erasure, error)
+	GetEventBySequence(ctx context.Context, arg GetEventBySequenceParams) (Event, error)
+	GetEventsAfterSequence(ctx context.Context, arg GetEventsAfterSequenceParams) ([]Event, error)
+	GetEventsForEmployee(ctx context.Context, arg GetEventsForEmployeeParams) ([]Event, error)
+	GetImpersonationSessions(ctx context.Context, arg GetImpersonationSessionsParams) ([]ImpersonationSession, error)
+	GetImpersonationSessionsForEmployee(ctx context.Context, arg GetImpersonationSessionsForEmployeeParams) ([]ImpersonationSession, error)
+	GetKnownLoginsForUser(ctx context.Context, arg GetKnownLoginsForUserParams) ([]KnownLogin, error)
+	GetLDAPAccount(ctx context.Context, arg GetLDAPAccountParams) (LdapAccount, error)
+	GetLDAPAccountByDN(ctx context.Context, arg GetLDAPAccountByDNParams) (LdapAccount, error)
+	GetLatestEventSequence(ctx context.Context, organizationID uuid.UUID) (int64, error)
+	GetOffboarding(ctx context.Context, arg GetOffboardingParams) (Offboarding, error)
+	GetOrganization(ctx context.Context, id uuid.UUID) (Organization, error)
+	GetOrganizationBySCIMTokenID(ctx context.Context, scimTokenID pgtype.Text) (Organization, error)
+	GetOrganizationBySlug(ctx context.Context, slug string) (Organization, error)
+	GetOrganizations(ctx context.Context) ([]Organization, error)
+	GetPermissions(ctx context.Context) ([]string, error)
+	GetPermissionsForEmployee(ctx context.Context, arg GetPermissionsForEmployeeParams) ([]string, error)
+	GetPermissionsForServiceAccount(ctx context.Context, arg GetPermissionsForServiceAccountParams) ([]string, error)
+	GetProfileField(ctx context.Context, arg GetProfileFieldParams) (ProfileField, error)
+	GetProfileFields(ctx context.Context, organizationID uuid.UUID) ([]ProfileField, error)
+	GetRevokedRolesForUser(ctx context.Context, arg GetRevokedRolesForUserParams) ([]GetRevokedRolesForUserRow, error)
+	GetRole(ctx context.Context, arg GetRoleParams) (Role, error)
+	GetRoleByDisplayName(ctx context.Context, arg GetRoleByDisplayNameParams) (Role, error)
+	GetRoleGrantsByGrantor(ctx context.Context, arg GetRoleGrantsByGrantorParams) ([]GetRoleGrantsByGrantorRow, error)
+	GetRoleGrantsForUser(ctx context.Context, arg GetRoleGrantsForUserParams) ([]GetRoleGrantsForUserRow, error)
+	GetRoleMembers(ctx context.Context, arg GetRoleMembersParams) ([]GetRoleMembersRow, error)
+	GetRoles(ctx context.Context, organizationID uuid.UUID) ([]GetRolesRow, error)
+	GetRolesForUser(ctx context.Context, arg GetRolesForUserParams) ([]GetRolesForUserRow, error)
+	GetServiceAccountByID(ctx context.Context, arg GetServiceAccountByIDParams) (ServiceAccount, error)
+	GetServiceAccounts(ctx context.Context, organizationID uuid.UUID) ([]ServiceAccount, error)
+	GetServiceAccountsCreatedBy(ctx context.Context, arg GetServiceAccountsCreatedByParams) ([]ServiceAccount, error)
+	// No employee grants the roles assigned by the identity provider, the
+	// directory or single sign-on. They are recorded as granted by the first
+	// employee of the organization to hold the admin permission still there,
+	// usually the root user.
+	GetSystemGrantor(ctx context.Context, organizationID uuid.UUID) (uuid.UUID, error)
+	GetTOTPSecret(ctx context.Context, arg GetTOTPSecretParams) (TotpSecret, error)
+	GetTwoFactorRoles(ctx context.Context, organizationID uuid.UUID) ([]uuid.UUID, error)
+	GetUser(ctx context.Context, arg GetUserParams) (User, error)
+	// Emails are compared regardless of case, like in UserEmailExists, as
+	// providers don't all keep the case of the address the employee was created
+	// with.
+	GetUserByEmail(ctx context.Context, arg GetUserByEmailParams) (User, error)
+	GetUserByID(ctx context.Context, arg GetUserByIDParams) (GetUserByIDRow, error)
+	GetWebhookByID(ctx context.Context, arg GetWebhookByIDParams) (Webhook, error)
+	GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	GetWebhooks(ctx context.Context, organizationID uuid.UUID) ([]Webhook, error)
+	GetWebhooksCreatedBy(ctx context.Context, arg GetWebhooksCreatedByParams) ([]Webhook, error)
+	ListPurgeableRoles(ctx context.Context, arg ListPurgeableRolesParams) ([]uuid.UUID, error)
+	ListPurgeableUsers(ctx context.Context, arg ListPurgeableUsersParams) ([]uuid.UUID, error)
+	ListRoles(ctx context.Context, arg ListRolesParams) ([]Role, error)
+	ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error)
+	LockAccount(ctx context.Context, arg LockAccountParams) error
+	// Locks an offboarding still to complete, skipping it when another instance
+	// is completing it.
+	LockDueOffboarding(ctx context.Context, arg LockDueOffboardingParams) (Offboarding, error)
+	// Serializes the events of the organization until the end of the transaction,
+	// so they take their sequences in the order they are committed in.
+	LockEvents(ctx context.Context, organizationID uuid.UUID) error
+	// Replaces the name and email address of the employee in the events about
+	// them.
+	PseudonymizeEvents(ctx context.Context, arg PseudonymizeEventsParams) (int64, error)
+	// Replaces the name and email address of the employee in the payloads of the
+	// deliveries of events about them.
+	PseudonymizeWebhookDeliveries(ctx context.Context, arg PseudonymizeWebhookDeliveriesParams) (int64, error)
+	// Roles revoked from employees are kept as long as their history is.
+	PurgeRole(ctx context.Context, arg PurgeRoleParams) (int64, error)
+	// Fails with a foreign key violation while other records reference the
+	// employee, such as the roles they granted.
+	PurgeUser(ctx context.Context, arg PurgeUserParams) (int64, error)
+	ReassignServiceAccounts(ctx context.Context, arg ReassignServiceAccountsParams) (int64, error)
+	ReassignWebhooks(ctx context.Context, arg ReassignWebhooksParams) (int64, error)
+	RecordFailedLogin(ctx context.Context, arg RecordFailedLoginParams) (AccountLockout, error)
+	RecordKnownLogin(ctx context.Context, arg RecordKnownLoginParams) (RecordKnownLoginRow, error)
+	RecordWebhookFailure(ctx context.Context, arg RecordWebhookFailureParams) (bool, error)
+	// Removes the values of a deleted field from the profiles of the employees.
+	RemoveProfileFieldValues(ctx context.Context, arg RemoveProfileFieldValuesParams) error
+	RemoveRoleMembers(ctx context.Context, arg RemoveRoleMembersParams) error
+	ResetWebhookFailures(ctx context.Context, arg ResetWebhookFailuresParams) error
+	RestoreRole(ctx context.Context, arg RestoreRoleParams) (Role, error)
+	RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error)
+	RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (int64, error)
+	// Schedules the offboarding of the employee, or reschedules it when it
+	// isn't completed yet.
+	ScheduleOffboarding(ctx context.Context, arg ScheduleOffboardingParams) (Offboarding, error)
+	// Finds the employees with a name or email address containing words starting
+	// with the words of the query, accents ignored, and the ones with a name
+	// similar to the query, to tolerate typos. The full-text matches rank first.
+	// The names and email addresses are escaped before the matches are
+	// highlighted, so the highlights are safe to render as HTML.
+	SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error)
+	SetOrganizationSCIMToken(ctx context.Context, arg SetOrganizationSCIMTokenParams) (Organization, error)
+	// The burst and rate are bound once, in "bucket", as sqlc can't rewrite named
+	// parameters inside a row-valued SET.
+	TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (TakeRateLimitTokenRow, error)
+	// Records the use of a key at most once a minute, sparing a write per
+	// request.
+	TouchAPIKey(ctx context.Context, arg TouchAPIKeyParams) error
+	UpdateOrganization(ctx context.Context, arg UpdateOrganizationParams) (Organization, error)
+	UpdateProfileField(ctx context.Context, arg UpdateProfileFieldParams) (ProfileField, error)
+	UpdateRoleDisplayName(ctx context.Context, arg UpdateRoleDisplayNameParams) (int64, error)
+	UpdateServiceAccount(ctx context.Context, arg UpdateServiceAccountParams) (ServiceAccount, error)
+	// Replaces a secret stored in plaintext with the same secret, encrypted.
+	UpdateTOTPSecret(ctx context.Context, arg UpdateTOTPSecretParams) error
 	UpdateUser(ctx context.Context, arg UpdateUserParams) error
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (int64, error)
+	UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) error
+	UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error)
+	UpsertLDAPAccount(ctx context.Context, arg UpsertLDAPAccountParams) error
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error)
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error)
+	// Deleted employees keep their email address until they're purged, so it's
+	// checked against them too.
+	UserEmailExists(ctx context.Context, arg UserEmailExistsParams) (bool, error)
 }
 
 var _ Querier = (*Queries)(nil)
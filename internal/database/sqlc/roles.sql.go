@@ -7,18 +7,371 @@ package database
 
 import (
 	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const addRoleMembers = `-- name: AddRoleMembers :exec
+INSERT INTO "users_roles" ("user_id", "role_id", "grantor", "organization_id")
+SELECT
+    "member",
+    $1,
+    $2,
+    $3
+FROM unnest($4::uuid[]) AS "member"
+ON CONFLICT ("user_id", "role_id") DO NOTHING
+`
+
+type AddRoleMembersParams struct {
+	RoleID         uuid.UUID   `json:"role_id"`
+	Grantor        uuid.UUID   `json:"grantor"`
+	OrganizationID uuid.UUID   `json:"organization_id"`
+	UserIds        []uuid.UUID `json:"user_ids"`
+}
+
+func (q *Queries) AddRoleMembers(ctx context.Context, arg AddRoleMembersParams) error {
+	_, err := q.db.Exec(ctx, addRoleMembers,
+		arg.RoleID,
+		arg.Grantor,
+		arg.OrganizationID,
+		arg.UserIds,
+	)
+	return err
+}
+
+const countRoles = `-- name: CountRoles :one
+SELECT count(*)
+FROM "roles"
+WHERE
+    (
+        $1::varchar IS NULL
+        OR "display_name" = $1
+    )
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL
+`
+
+type CountRolesParams struct {
+	DisplayName    pgtype.Text `json:"display_name"`
+	OrganizationID uuid.UUID   `json:"organization_id"`
+}
+
+func (q *Queries) CountRoles(ctx context.Context, arg CountRolesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countRoles, arg.DisplayName, arg.OrganizationID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createRole = `-- name: CreateRole :one
+INSERT INTO "roles" ("display_name", "description", "organization_id")
+VALUES ($1, $2, $3)
+RETURNING id, display_name, description, organization_id, deleted_at
+`
+
+type CreateRoleParams struct {
+	DisplayName    string    `json:"display_name"`
+	Description    string    `json:"description"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) CreateRole(ctx context.Context, arg CreateRoleParams) (Role, error) {
+	row := q.db.QueryRow(ctx, createRole, arg.DisplayName, arg.Description, arg.OrganizationID)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.DisplayName,
+		&i.Description,
+		&i.OrganizationID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const deleteRole = `-- name: DeleteRole :execrows
+UPDATE "roles"
+SET "deleted_at" = now()
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL
+`
+
+type DeleteRoleParams struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) DeleteRole(ctx context.Context, arg DeleteRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteRole, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteRoleMembers = `-- name: DeleteRoleMembers :exec
+DELETE FROM "users_roles"
+WHERE
+    "role_id" = $1
+    AND "organization_id" = $2
+`
+
+type DeleteRoleMembersParams struct {
+	RoleID         uuid.UUID `json:"role_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) DeleteRoleMembers(ctx context.Context, arg DeleteRoleMembersParams) error {
+	_, err := q.db.Exec(ctx, deleteRoleMembers, arg.RoleID, arg.OrganizationID)
+	return err
+}
+
+const deleteRolePermissions = `-- name: DeleteRolePermissions :exec
+DELETE FROM "roles_permissions"
+WHERE
+    "role_id" = $1
+    AND "organization_id" = $2
+`
+
+type DeleteRolePermissionsParams struct {
+	RoleID         uuid.UUID `json:"role_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) DeleteRolePermissions(ctx context.Context, arg DeleteRolePermissionsParams) error {
+	_, err := q.db.Exec(ctx, deleteRolePermissions, arg.RoleID, arg.OrganizationID)
+	return err
+}
+
+const getRole = `-- name: GetRole :one
+SELECT id, display_name, description, organization_id, deleted_at
+FROM "roles"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL
+`
+
+type GetRoleParams struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) GetRole(ctx context.Context, arg GetRoleParams) (Role, error) {
+	row := q.db.QueryRow(ctx, getRole, arg.ID, arg.OrganizationID)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.DisplayName,
+		&i.Description,
+		&i.OrganizationID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getRoleByDisplayName = `-- name: GetRoleByDisplayName :one
+SELECT id, display_name, description, organization_id, deleted_at
+FROM "roles"
+WHERE
+    "display_name" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL
+`
+
+type GetRoleByDisplayNameParams struct {
+	DisplayName    string    `json:"display_name"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) GetRoleByDisplayName(ctx context.Context, arg GetRoleByDisplayNameParams) (Role, error) {
+	row := q.db.QueryRow(ctx, getRoleByDisplayName, arg.DisplayName, arg.OrganizationID)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.DisplayName,
+		&i.Description,
+		&i.OrganizationID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getRoleMembers = `-- name: GetRoleMembers :many
+SELECT
+    "users"."id",
+    "users"."name"
+FROM "users"
+INNER JOIN "users_roles" ON "users"."id" = "users_roles"."user_id"
+WHERE
+    "users_roles"."role_id" = $1
+    AND "users_roles"."organization_id" = $2
+    AND "users"."deleted_at" IS NULL
+ORDER BY "users"."name"
+`
+
+type GetRoleMembersParams struct {
+	RoleID         uuid.UUID `json:"role_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+type GetRoleMembersRow struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+func (q *Queries) GetRoleMembers(ctx context.Context, arg GetRoleMembersParams) ([]GetRoleMembersRow, error) {
+	rows, err := q.db.Query(ctx, getRoleMembers, arg.RoleID, arg.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetRoleMembersRow{}
+	for rows.Next() {
+		var i GetRoleMembersRow
+		if err := rows.Scan(&i.ID, &i.Name); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getRoles = `-- name: GetRoles :many
 SELECT
     "id",
-    "code",
+    "display_name",
     "description"
-FROM roles
+FROM "roles"
+WHERE
+    "organization_id" = $1
+    AND "deleted_at" IS NULL
+`
+
+type GetRolesRow struct {
+	ID          uuid.UUID `json:"id"`
+	DisplayName string    `json:"display_name"`
+	Description string    `json:"description"`
+}
+
+func (q *Queries) GetRoles(ctx context.Context, organizationID uuid.UUID) ([]GetRolesRow, error) {
+	rows, err := q.db.Query(ctx, getRoles, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetRolesRow{}
+	for rows.Next() {
+		var i GetRolesRow
+		if err := rows.Scan(&i.ID, &i.DisplayName, &i.Description); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSystemGrantor = `-- name: GetSystemGrantor :one
+SELECT "users_roles"."user_id"
+FROM "users_roles"
+INNER JOIN
+    "roles_permissions"
+    ON "users_roles"."role_id" = "roles_permissions"."role_id"
+INNER JOIN
+    "permissions"
+    ON "roles_permissions"."permission_id" = "permissions"."id"
+INNER JOIN "roles" ON "users_roles"."role_id" = "roles"."id"
+INNER JOIN "users" ON "users_roles"."user_id" = "users"."id"
+WHERE
+    "permissions"."display_name" = 'admin'
+    AND "users_roles"."organization_id" = $1
+    AND "roles"."deleted_at" IS NULL
+    AND "users"."deleted_at" IS NULL
+ORDER BY "users_roles"."granted_at", "users_roles"."user_id"
+LIMIT 1
+`
+
+// No employee grants the roles assigned by the identity provider, the
+// directory or single sign-on. They are recorded as granted by the first
+// employee of the organization to hold the admin permission still there,
+// usually the root user.
+func (q *Queries) GetSystemGrantor(ctx context.Context, organizationID uuid.UUID) (uuid.UUID, error) {
+	row := q.db.QueryRow(ctx, getSystemGrantor, organizationID)
+	var user_id uuid.UUID
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const listPurgeableRoles = `-- name: ListPurgeableRoles :many
+SELECT "id"
+FROM "roles"
+WHERE
+    "deleted_at" < $1
+    AND "organization_id" = $2
+ORDER BY "deleted_at"
+`
+
+type ListPurgeableRolesParams struct {
+	DeletedBefore  pgtype.Timestamp `json:"deleted_before"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+func (q *Queries) ListPurgeableRoles(ctx context.Context, arg ListPurgeableRolesParams) ([]uuid.UUID, error) {
+	rows, err := q.db.Query(ctx, listPurgeableRoles, arg.DeletedBefore, arg.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRoles = `-- name: ListRoles :many
+SELECT id, display_name, description, organization_id, deleted_at
+FROM "roles"
+WHERE
+    (
+        $1::varchar IS NULL
+        OR "display_name" = $1
+    )
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL
+ORDER BY "display_name"
+LIMIT $4 OFFSET $3
 `
 
-func (q *Queries) GetRoles(ctx context.Context) ([]Role, error) {
-	rows, err := q.db.Query(ctx, getRoles)
+type ListRolesParams struct {
+	DisplayName    pgtype.Text `json:"display_name"`
+	OrganizationID uuid.UUID   `json:"organization_id"`
+	Offset         int32       `json:"offset"`
+	Limit          int32       `json:"limit"`
+}
+
+func (q *Queries) ListRoles(ctx context.Context, arg ListRolesParams) ([]Role, error) {
+	rows, err := q.db.Query(ctx, listRoles,
+		arg.DisplayName,
+		arg.OrganizationID,
+		arg.Offset,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -26,7 +379,13 @@ func (q *Queries) GetRoles(ctx context.Context) ([]Role, error) {
 	items := []Role{}
 	for rows.Next() {
 		var i Role
-		if err := rows.Scan(&i.ID, &i.Code, &i.Description); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.DisplayName,
+			&i.Description,
+			&i.OrganizationID,
+			&i.DeletedAt,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -36,3 +395,100 @@ func (q *Queries) GetRoles(ctx context.Context) ([]Role, error) {
 	}
 	return items, nil
 }
+
+const purgeRole = `-- name: PurgeRole :execrows
+DELETE FROM "roles"
+WHERE
+    "roles"."id" = $1
+    AND "roles"."organization_id" = $2
+    AND "roles"."deleted_at" IS NOT NULL
+    AND NOT EXISTS (
+        SELECT 1
+        FROM "revoked_roles"
+        WHERE "revoked_roles"."role_id" = "roles"."id"
+    )
+`
+
+type PurgeRoleParams struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+// Roles revoked from employees are kept as long as their history is.
+func (q *Queries) PurgeRole(ctx context.Context, arg PurgeRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeRole, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const removeRoleMembers = `-- name: RemoveRoleMembers :exec
+DELETE FROM "users_roles"
+WHERE
+    "role_id" = $1
+    AND "user_id" = any($2::uuid[])
+    AND "organization_id" = $3
+`
+
+type RemoveRoleMembersParams struct {
+	RoleID         uuid.UUID   `json:"role_id"`
+	UserIds        []uuid.UUID `json:"user_ids"`
+	OrganizationID uuid.UUID   `json:"organization_id"`
+}
+
+func (q *Queries) RemoveRoleMembers(ctx context.Context, arg RemoveRoleMembersParams) error {
+	_, err := q.db.Exec(ctx, removeRoleMembers, arg.RoleID, arg.UserIds, arg.OrganizationID)
+	return err
+}
+
+const restoreRole = `-- name: RestoreRole :one
+UPDATE "roles"
+SET "deleted_at" = NULL
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NOT NULL
+RETURNING id, display_name, description, organization_id, deleted_at
+`
+
+type RestoreRoleParams struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) RestoreRole(ctx context.Context, arg RestoreRoleParams) (Role, error) {
+	row := q.db.QueryRow(ctx, restoreRole, arg.ID, arg.OrganizationID)
+	var i Role
+	err := row.Scan(
+		&i.ID,
+		&i.DisplayName,
+		&i.Description,
+		&i.OrganizationID,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateRoleDisplayName = `-- name: UpdateRoleDisplayName :execrows
+UPDATE "roles"
+SET "display_name" = $2
+WHERE
+    "id" = $1
+    AND "organization_id" = $3
+    AND "deleted_at" IS NULL
+`
+
+type UpdateRoleDisplayNameParams struct {
+	ID             uuid.UUID `json:"id"`
+	DisplayName    string    `json:"display_name"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) UpdateRoleDisplayName(ctx context.Context, arg UpdateRoleDisplayNameParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateRoleDisplayName, arg.ID, arg.DisplayName, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
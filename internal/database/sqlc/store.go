@@ -2,16 +2,40 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/exaring/otelpgx"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+type organizationContextKey struct{}
+
+// WithOrganization returns a copy of ctx for the queries of the organization.
+// The connections acquired with it only see the rows of the organization, as
+// enforced by the row level security policies of the tenant tables.
+func WithOrganization(ctx context.Context, organizationID uuid.UUID) context.Context {
+	return context.WithValue(ctx, organizationContextKey{}, organizationID)
+}
+
+// OrganizationFromContext returns the organization ctx was scoped to with
+// WithOrganization.
+func OrganizationFromContext(ctx context.Context) (uuid.UUID, bool) {
+	organizationID, ok := ctx.Value(organizationContextKey{}).(uuid.UUID)
+	return organizationID, ok
+}
+
 type Store interface {
 	Querier
 	ExecTx(ctx context.Context, fn func(*Queries) error) error
+	Listen(ctx context.Context, channel string, fn func(payload string)) error
+	Ping(ctx context.Context) error
+	MigrationVersion(ctx context.Context) (version int64, dirty bool, err error)
+	Stat() *pgxpool.Stat
 }
 
 // SQLStore provides all functions to execute SQL queries and transactions
@@ -20,12 +44,21 @@ type SQLStore struct {
 	db *pgxpool.Pool
 }
 
-// NewStore returns a new a Store
+// NewStore returns a new a Store. Every query it runs is traced with the
+// global OpenTelemetry tracer provider.
 func NewStore(dsn string) (Store, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	db, err := pgxpool.New(ctx, fmt.Sprintf("postgres://%s", dsn))
+	config, err := pgxpool.ParseConfig(fmt.Sprintf("postgres://%s", dsn))
+	if err != nil {
+		return nil, err
+	}
+
+	config.ConnConfig.Tracer = otelpgx.NewTracer(otelpgx.WithSpanNameFunc(queryName))
+	config.BeforeAcquire = setOrganization
+
+	db, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		return nil, err
 	}
@@ -60,3 +93,75 @@ func (store *SQLStore) ExecTx(ctx context.Context, fn func(*Queries) error) erro
 
 	return tx.Commit(ctx)
 }
+
+// Listen subscribes to a PostgreSQL notification channel on a dedicated
+// connection and calls fn with the payload of every notification received.
+// It blocks until ctx is cancelled or the connection fails.
+func (store *SQLStore) Listen(ctx context.Context, channel string, fn func(payload string)) error {
+	conn, err := store.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		fn(notification.Payload)
+	}
+}
+
+// Ping checks that a connection to the database can be established.
+func (store *SQLStore) Ping(ctx context.Context) error {
+	return store.db.Ping(ctx)
+}
+
+// MigrationVersion returns the schema version recorded by golang-migrate and
+// whether the last migration failed halfway. The version is zero when no
+// migration has been applied.
+func (store *SQLStore) MigrationVersion(ctx context.Context) (version int64, dirty bool, err error) {
+	err = store.db.QueryRow(ctx, `SELECT "version", "dirty" FROM "schema_migrations" LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+// Stat returns a snapshot of the connection pool statistics.
+func (store *SQLStore) Stat() *pgxpool.Stat {
+	return store.db.Stat()
+}
+
+// setOrganization scopes the connection to the organization of ctx, or to no
+// organization at all, so a connection never keeps the organization of the
+// previous request it ran. The connection is destroyed when that fails.
+func setOrganization(ctx context.Context, conn *pgx.Conn) bool {
+	var setting string
+	if organizationID, ok := OrganizationFromContext(ctx); ok {
+		setting = organizationID.String()
+	}
+
+	_, err := conn.Exec(ctx, "SELECT set_config('app.organization_id', $1, false)", setting)
+	return err == nil
+}
+
+// queryName names the span of a query after the sqlc query it runs, taken from
+// its "-- name: GetWebhooks :many" header, or after its first keyword.
+func queryName(stmt string) string {
+	if name, found := strings.CutPrefix(stmt, "-- name: "); found {
+		name, _, _ = strings.Cut(name, " ")
+		return name
+	}
+
+	name, _, _ := strings.Cut(strings.TrimSpace(stmt), " ")
+	return strings.ToUpper(name)
+}
@@ -12,12 +12,47 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countUsers = `-- name: CountUsers :one
+SELECT count(*)
+FROM "users"
+WHERE
+    (
+        $1::varchar IS NULL
+        OR lower("email") = lower($1)
+    )
+    AND (
+        $2::jsonb IS NULL
+        OR "profile" @> $2
+    )
+    AND ("deleted_at" IS NOT NULL) = $3::boolean
+    AND "organization_id" = $4
+`
+
+type CountUsersParams struct {
+	Email          pgtype.Text `json:"email"`
+	Profile        []byte      `json:"profile"`
+	Deleted        bool        `json:"deleted"`
+	OrganizationID uuid.UUID   `json:"organization_id"`
+}
+
+func (q *Queries) CountUsers(ctx context.Context, arg CountUsersParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countUsers,
+		arg.Email,
+		arg.Profile,
+		arg.Deleted,
+		arg.OrganizationID,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO
-"users" ("name", "email", "status", "hashed_password")
+"users" ("name", "email", "status", "hashed_password", "organization_id")
 VALUES
-($1, $2, $3, $4)
-RETURNING "id", "name", "email", "status"
+($1, $2, $3, $4, $5)
+RETURNING id, name, email, hashed_password, status, organization_id, deleted_at, profile
 `
 
 type CreateUserParams struct {
@@ -25,57 +60,172 @@ type CreateUserParams struct {
 	Email          string      `json:"email"`
 	Status         string      `json:"status"`
 	HashedPassword pgtype.Text `json:"hashed_password"`
+	OrganizationID uuid.UUID   `json:"organization_id"`
 }
 
-type CreateUserRow struct {
-	ID     uuid.UUID `json:"id"`
-	Name   string    `json:"name"`
-	Email  string    `json:"email"`
-	Status string    `json:"status"`
-}
-
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
 	row := q.db.QueryRow(ctx, createUser,
 		arg.Name,
 		arg.Email,
 		arg.Status,
 		arg.HashedPassword,
+		arg.OrganizationID,
 	)
-	var i CreateUserRow
+	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.Name,
 		&i.Email,
+		&i.HashedPassword,
 		&i.Status,
+		&i.OrganizationID,
+		&i.DeletedAt,
+		&i.Profile,
 	)
 	return i, err
 }
 
-const getUserByEmail = `-- name: GetUserByEmail :one
+const deleteRolesForUser = `-- name: DeleteRolesForUser :exec
+DELETE FROM "users_roles"
+WHERE
+    "user_id" = $1
+    AND "organization_id" = $2
+`
+
+type DeleteRolesForUserParams struct {
+	UserID         uuid.UUID `json:"user_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) DeleteRolesForUser(ctx context.Context, arg DeleteRolesForUserParams) error {
+	_, err := q.db.Exec(ctx, deleteRolesForUser, arg.UserID, arg.OrganizationID)
+	return err
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
+UPDATE "users"
+SET "deleted_at" = now()
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL
+`
+
+type DeleteUserParams struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) DeleteUser(ctx context.Context, arg DeleteUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUser, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getRolesForUser = `-- name: GetRolesForUser :many
 SELECT
-    "name",
-    "email",
-    "status",
-    "hashed_password"
+    "roles"."id",
+    "roles"."display_name"
+FROM "roles"
+INNER JOIN "users_roles" ON "roles"."id" = "users_roles"."role_id"
+WHERE
+    "users_roles"."user_id" = $1
+    AND "users_roles"."organization_id" = $2
+    AND "roles"."deleted_at" IS NULL
+ORDER BY "roles"."display_name"
+`
+
+type GetRolesForUserParams struct {
+	UserID         uuid.UUID `json:"user_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+type GetRolesForUserRow struct {
+	ID          uuid.UUID `json:"id"`
+	DisplayName string    `json:"display_name"`
+}
+
+func (q *Queries) GetRolesForUser(ctx context.Context, arg GetRolesForUserParams) ([]GetRolesForUserRow, error) {
+	rows, err := q.db.Query(ctx, getRolesForUser, arg.UserID, arg.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetRolesForUserRow{}
+	for rows.Next() {
+		var i GetRolesForUserRow
+		if err := rows.Scan(&i.ID, &i.DisplayName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, name, email, hashed_password, status, organization_id, deleted_at, profile
 FROM "users"
-WHERE "email" = $1
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL
 `
 
-type GetUserByEmailRow struct {
-	Name           string      `json:"name"`
-	Email          string      `json:"email"`
-	Status         string      `json:"status"`
-	HashedPassword pgtype.Text `json:"hashed_password"`
+type GetUserParams struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
 }
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
-	row := q.db.QueryRow(ctx, getUserByEmail, email)
-	var i GetUserByEmailRow
+func (q *Queries) GetUser(ctx context.Context, arg GetUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, getUser, arg.ID, arg.OrganizationID)
+	var i User
 	err := row.Scan(
+		&i.ID,
 		&i.Name,
 		&i.Email,
+		&i.HashedPassword,
 		&i.Status,
+		&i.OrganizationID,
+		&i.DeletedAt,
+		&i.Profile,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, name, email, hashed_password, status, organization_id, deleted_at, profile
+FROM "users"
+WHERE
+    lower("email") = lower($1)
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL
+`
+
+type GetUserByEmailParams struct {
+	Email          string    `json:"email"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+// Emails are compared regardless of case, like in UserEmailExists, as
+// providers don't all keep the case of the address the employee was created
+// with.
+func (q *Queries) GetUserByEmail(ctx context.Context, arg GetUserByEmailParams) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, arg.Email, arg.OrganizationID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
 		&i.HashedPassword,
+		&i.Status,
+		&i.OrganizationID,
+		&i.DeletedAt,
+		&i.Profile,
 	)
 	return i, err
 }
@@ -87,9 +237,17 @@ SELECT
     "status",
     "hashed_password"
 FROM "users"
-WHERE "id" = $1
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NULL
 `
 
+type GetUserByIDParams struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
 type GetUserByIDRow struct {
 	Name           string      `json:"name"`
 	Email          string      `json:"email"`
@@ -97,8 +255,8 @@ type GetUserByIDRow struct {
 	HashedPassword pgtype.Text `json:"hashed_password"`
 }
 
-func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow, error) {
-	row := q.db.QueryRow(ctx, getUserByID, id)
+func (q *Queries) GetUserByID(ctx context.Context, arg GetUserByIDParams) (GetUserByIDRow, error) {
+	row := q.db.QueryRow(ctx, getUserByID, arg.ID, arg.OrganizationID)
 	var i GetUserByIDRow
 	err := row.Scan(
 		&i.Name,
@@ -109,6 +267,258 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow
 	return i, err
 }
 
+const listPurgeableUsers = `-- name: ListPurgeableUsers :many
+SELECT "id"
+FROM "users"
+WHERE
+    "deleted_at" < $1
+    AND "organization_id" = $2
+ORDER BY "deleted_at"
+`
+
+type ListPurgeableUsersParams struct {
+	DeletedBefore  pgtype.Timestamp `json:"deleted_before"`
+	OrganizationID uuid.UUID        `json:"organization_id"`
+}
+
+func (q *Queries) ListPurgeableUsers(ctx context.Context, arg ListPurgeableUsersParams) ([]uuid.UUID, error) {
+	rows, err := q.db.Query(ctx, listPurgeableUsers, arg.DeletedBefore, arg.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, name, email, hashed_password, status, organization_id, deleted_at, profile
+FROM "users"
+WHERE
+    (
+        $1::varchar IS NULL
+        OR lower("email") = lower($1)
+    )
+    AND (
+        $2::jsonb IS NULL
+        OR "profile" @> $2
+    )
+    AND ("deleted_at" IS NOT NULL) = $3::boolean
+    AND "organization_id" = $4
+ORDER BY "email"
+LIMIT $6 OFFSET $5
+`
+
+type ListUsersParams struct {
+	Email          pgtype.Text `json:"email"`
+	Profile        []byte      `json:"profile"`
+	Deleted        bool        `json:"deleted"`
+	OrganizationID uuid.UUID   `json:"organization_id"`
+	Offset         int32       `json:"offset"`
+	Limit          int32       `json:"limit"`
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsers,
+		arg.Email,
+		arg.Profile,
+		arg.Deleted,
+		arg.OrganizationID,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.HashedPassword,
+			&i.Status,
+			&i.OrganizationID,
+			&i.DeletedAt,
+			&i.Profile,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeUser = `-- name: PurgeUser :execrows
+DELETE FROM "users"
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NOT NULL
+`
+
+type PurgeUserParams struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+// Fails with a foreign key violation while other records reference the
+// employee, such as the roles they granted.
+func (q *Queries) PurgeUser(ctx context.Context, arg PurgeUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeUser, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE "users"
+SET "deleted_at" = NULL
+WHERE
+    "id" = $1
+    AND "organization_id" = $2
+    AND "deleted_at" IS NOT NULL
+RETURNING id, name, email, hashed_password, status, organization_id, deleted_at, profile
+`
+
+type RestoreUserParams struct {
+	ID             uuid.UUID `json:"id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, restoreUser, arg.ID, arg.OrganizationID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Status,
+		&i.OrganizationID,
+		&i.DeletedAt,
+		&i.Profile,
+	)
+	return i, err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT
+    "id",
+    "name",
+    "email",
+    "status",
+    "profile",
+    ts_rank(
+        employee_search_vector("name", "email"),
+        to_tsquery('unaccented', $1)
+    ) AS "rank",
+    word_similarity(
+        unaccent_immutable($2), unaccent_immutable("name")
+    ) AS "similarity",
+    ts_headline(
+        'unaccented',
+        replace(replace(replace("name", '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        to_tsquery('unaccented', $1),
+        'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'
+    )::text AS "name_highlight",
+    ts_headline(
+        'unaccented',
+        replace(replace(replace("email", '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        to_tsquery('unaccented', $1),
+        'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'
+    )::text AS "email_highlight"
+FROM "users"
+WHERE
+    (
+        employee_search_vector("name", "email")
+        @@ to_tsquery('unaccented', $1)
+        OR unaccent_immutable($2) <% unaccent_immutable("name")
+    )
+    AND ("status" = 'active' OR $3::boolean)
+    AND "deleted_at" IS NULL
+    AND "organization_id" = $4
+ORDER BY "rank" DESC, "similarity" DESC, "name"
+LIMIT $5
+`
+
+type SearchUsersParams struct {
+	Query          string    `json:"query"`
+	Term           string    `json:"term"`
+	Inactive       bool      `json:"inactive"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Limit          int32     `json:"limit"`
+}
+
+type SearchUsersRow struct {
+	ID             uuid.UUID `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	Status         string    `json:"status"`
+	Profile        []byte    `json:"profile"`
+	Rank           float32   `json:"rank"`
+	Similarity     float32   `json:"similarity"`
+	NameHighlight  string    `json:"name_highlight"`
+	EmailHighlight string    `json:"email_highlight"`
+}
+
+// Finds the employees with a name or email address containing words starting
+// with the words of the query, accents ignored, and the ones with a name
+// similar to the query, to tolerate typos. The full-text matches rank first.
+// The names and email addresses are escaped before the matches are
+// highlighted, so the highlights are safe to render as HTML.
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.Query(ctx, searchUsers,
+		arg.Query,
+		arg.Term,
+		arg.Inactive,
+		arg.OrganizationID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchUsersRow{}
+	for rows.Next() {
+		var i SearchUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Status,
+			&i.Profile,
+			&i.Rank,
+			&i.Similarity,
+			&i.NameHighlight,
+			&i.EmailHighlight,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateUser = `-- name: UpdateUser :exec
 UPDATE "users"
 SET
@@ -118,6 +528,7 @@ SET
     "status" = $5
 WHERE
     "id" = $1
+    AND "organization_id" = $6
 RETURNING "id", "name", "email", "status"
 `
 
@@ -127,6 +538,7 @@ type UpdateUserParams struct {
 	Email          string      `json:"email"`
 	HashedPassword pgtype.Text `json:"hashed_password"`
 	Status         string      `json:"status"`
+	OrganizationID uuid.UUID   `json:"organization_id"`
 }
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
@@ -136,6 +548,81 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
 		arg.Email,
 		arg.HashedPassword,
 		arg.Status,
+		arg.OrganizationID,
 	)
 	return err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :execrows
+UPDATE "users"
+SET "hashed_password" = $1
+WHERE
+    "id" = $2
+    AND "hashed_password" = $3
+    AND "organization_id" = $4
+    AND "deleted_at" IS NULL
+`
+
+type UpdateUserPasswordParams struct {
+	NewHashedPassword pgtype.Text `json:"new_hashed_password"`
+	ID                uuid.UUID   `json:"id"`
+	OldHashedPassword pgtype.Text `json:"old_hashed_password"`
+	OrganizationID    uuid.UUID   `json:"organization_id"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUserPassword,
+		arg.NewHashedPassword,
+		arg.ID,
+		arg.OldHashedPassword,
+		arg.OrganizationID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :exec
+UPDATE "users"
+SET "profile" = $2
+WHERE
+    "id" = $1
+    AND "organization_id" = $3
+    AND "deleted_at" IS NULL
+`
+
+type UpdateUserProfileParams struct {
+	ID             uuid.UUID `json:"id"`
+	Profile        []byte    `json:"profile"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) error {
+	_, err := q.db.Exec(ctx, updateUserProfile, arg.ID, arg.Profile, arg.OrganizationID)
+	return err
+}
+
+const userEmailExists = `-- name: UserEmailExists :one
+SELECT EXISTS (
+    SELECT 1
+    FROM "users"
+    WHERE
+        lower("email") = lower($1)
+        AND "organization_id" = $2
+)
+`
+
+type UserEmailExistsParams struct {
+	Email          string    `json:"email"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+}
+
+// Deleted employees keep their email address until they're purged, so it's
+// checked against them too.
+func (q *Queries) UserEmailExists(ctx context.Context, arg UserEmailExistsParams) (bool, error) {
+	row := q.db.QueryRow(ctx, userEmailExists, arg.Email, arg.OrganizationID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,205 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// pageSize is the number of entries requested per page when listing users,
+// below the 1000 entries Active Directory returns at most by default.
+const pageSize = 500
+
+// accountDisabled is the flag of the userAccountControl attribute of Active
+// Directory marking disabled accounts.
+const accountDisabled = 0x2
+
+var ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+
+// Config describes the LDAP server and where users are found in it.
+type Config struct {
+	// URL is the ldap:// or ldaps:// URL of the server.
+	URL string
+
+	// StartTLS upgrades ldap:// connections to TLS.
+	StartTLS bool
+
+	// BindDN and BindPassword are the credentials of the account searching
+	// the directory.
+	BindDN       string
+	BindPassword string
+
+	// BaseDN is where users are searched, and UserFilter selects them.
+	BaseDN     string
+	UserFilter string
+
+	// The attributes holding the email address, name and group DNs of
+	// users.
+	EmailAttribute string
+	NameAttribute  string
+	GroupAttribute string
+
+	Timeout time.Duration
+}
+
+// User is a user entry of the directory.
+type User struct {
+	DN     string
+	Email  string
+	Name   string
+	Groups []string
+
+	// Disabled is set for the accounts Active Directory reports as
+	// disabled.
+	Disabled bool
+}
+
+// Directory reads users from an LDAP server and authenticates them with a
+// bind. Every operation uses its own connection, as they are infrequent.
+type Directory struct {
+	config Config
+}
+
+func NewDirectory(cfg Config) *Directory {
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(objectClass=person)"
+	}
+
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "mail"
+	}
+
+	if cfg.NameAttribute == "" {
+		cfg.NameAttribute = "cn"
+	}
+
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "memberOf"
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	return &Directory{config: cfg}
+}
+
+// Users returns the users matching the user filter under the base DN. Entries
+// without an email address are skipped, as they can't be employees.
+func (d *Directory) Users(ctx context.Context) ([]User, error) {
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.Bind(d.config.BindDN, d.config.BindPassword)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: bind as %s: %w", d.config.BindDN, err)
+	}
+
+	req := goldap.NewSearchRequest(
+		d.config.BaseDN,
+		goldap.ScopeWholeSubtree,
+		goldap.NeverDerefAliases,
+		0, 0, false,
+		d.config.UserFilter,
+		[]string{d.config.EmailAttribute, d.config.NameAttribute, d.config.GroupAttribute, "userAccountControl"},
+		nil,
+	)
+
+	result, err := conn.SearchWithPaging(req, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: search users: %w", err)
+	}
+
+	users := make([]User, 0, len(result.Entries))
+
+	for _, entry := range result.Entries {
+		email := strings.TrimSpace(entry.GetAttributeValue(d.config.EmailAttribute))
+		if email == "" {
+			continue
+		}
+
+		user := User{
+			DN:     entry.DN,
+			Email:  email,
+			Name:   strings.TrimSpace(entry.GetAttributeValue(d.config.NameAttribute)),
+			Groups: entry.GetAttributeValues(d.config.GroupAttribute),
+		}
+
+		if uac := entry.GetAttributeValue("userAccountControl"); uac != "" {
+			flags, err := strconv.ParseInt(uac, 10, 64)
+			user.Disabled = err == nil && flags&accountDisabled != 0
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// Authenticate binds as the user with the given DN, returning
+// ErrInvalidCredentials when the password is incorrect. Empty passwords are
+// rejected without contacting the server, as LDAP servers treat a bind without
+// password as an anonymous bind that succeeds.
+func (d *Directory) Authenticate(ctx context.Context, dn, password string) error {
+	if password == "" {
+		return ErrInvalidCredentials
+	}
+
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.Bind(dn, password)
+	if err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return ErrInvalidCredentials
+		}
+
+		return fmt.Errorf("ldap: bind as %s: %w", dn, err)
+	}
+
+	return nil
+}
+
+func (d *Directory) dial(ctx context.Context) (*goldap.Conn, error) {
+	timeout := d.config.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
+	}
+
+	conn, err := goldap.DialURL(d.config.URL, goldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: dial %s: %w", d.config.URL, err)
+	}
+
+	conn.SetTimeout(timeout)
+
+	if d.config.StartTLS {
+		u, err := url.Parse(d.config.URL)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		err = conn.StartTLS(&tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: start TLS: %w", err)
+		}
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,115 @@
+package ldap
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// GroupRoles maps the DNs of directory groups to the names of the roles their
+// members are given. It implements flag.Value, written as
+// "<role>:<group DN>" entries separated by semicolons, such as
+// "staff:cn=HR,ou=Groups,dc=example,dc=com;leader:cn=Leads,ou=Groups,dc=example,dc=com".
+// Several groups can give the same role.
+type GroupRoles map[string]string
+
+func (g GroupRoles) String() string {
+	entries := make([]string, 0, len(g))
+
+	for dn, role := range g {
+		entries = append(entries, role+":"+dn)
+	}
+
+	slices.Sort(entries)
+
+	return strings.Join(entries, ";")
+}
+
+func (g *GroupRoles) Set(value string) error {
+	roles := GroupRoles{}
+
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		role, dn, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(role) == "" {
+			return fmt.Errorf("invalid group mapping %q, must be <role>:<group DN>", entry)
+		}
+
+		normalized, err := normalizeDN(dn)
+		if err != nil {
+			return fmt.Errorf("invalid group DN %q: %w", dn, err)
+		}
+
+		roles[normalized] = strings.TrimSpace(role)
+	}
+
+	*g = roles
+
+	return nil
+}
+
+// Roles returns the sorted names of the roles given by the groups, ignoring
+// the groups that aren't mapped.
+func (g GroupRoles) Roles(groups []string) []string {
+	var roles []string
+
+	for _, group := range groups {
+		dn, err := normalizeDN(group)
+		if err != nil {
+			continue
+		}
+
+		if role, ok := g[dn]; ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	slices.Sort(roles)
+
+	return roles
+}
+
+// Managed returns the sorted names of every mapped role. The membership of
+// these roles is managed by the directory, the other roles being left alone.
+func (g GroupRoles) Managed() []string {
+	var roles []string
+
+	for _, role := range g {
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	slices.Sort(roles)
+
+	return roles
+}
+
+// normalizeDN returns the DN in lowercase without spaces around its
+// separators, so equivalent DNs compare equal.
+func normalizeDN(dn string) (string, error) {
+	parsed, err := goldap.ParseDN(strings.TrimSpace(dn))
+	if err != nil {
+		return "", err
+	}
+
+	rdns := make([]string, 0, len(parsed.RDNs))
+
+	for _, rdn := range parsed.RDNs {
+		attributes := make([]string, 0, len(rdn.Attributes))
+
+		for _, attribute := range rdn.Attributes {
+			attributes = append(attributes, strings.ToLower(attribute.Type)+"="+strings.ToLower(attribute.Value))
+		}
+
+		rdns = append(rdns, strings.Join(attributes, "+"))
+	}
+
+	return strings.Join(rdns, ","), nil
+}
@@ -0,0 +1,165 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// BreachList is a Bloom filter of the SHA-1 hashes of passwords known to have
+// leaked. It answers whether a password is in the list with no false
+// negatives and a configurable rate of false positives, in a fraction of the
+// memory the list itself takes. Lists are made with NewBreachList or
+// LoadBreachList.
+type BreachList struct {
+	bits   []uint64
+	m      uint64
+	k      uint64
+	length int
+}
+
+// NewBreachList returns an empty list sized for n passwords with the given
+// false positive rate.
+func NewBreachList(n int, falsePositiveRate float64) *BreachList {
+	n = max(n, 1)
+
+	m := math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+
+	return &BreachList{
+		bits: make([]uint64, (uint64(m)+63)/64),
+		m:    uint64(m),
+		k:    max(uint64(k), 1),
+	}
+}
+
+// LoadBreachList reads the breach list file at path, which holds one entry per
+// line. Entries are either plaintext passwords, or the uppercase hexadecimal
+// SHA-1 hashes of passwords optionally followed by a colon and a count, as in
+// the Have I Been Pwned downloads. Empty lines and lines starting with # are
+// ignored. The file is read twice, first to size the list.
+func LoadBreachList(path string, falsePositiveRate float64) (*BreachList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	n := 0
+
+	err = scanBreachList(f, func(digest [sha1.Size]byte) { n++ })
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	list := NewBreachList(n, falsePositiveRate)
+
+	err = scanBreachList(f, list.add)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func scanBreachList(r io.Reader, fn func(digest [sha1.Size]byte)) error {
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		digest, ok := parseSHA1(entry)
+		if !ok {
+			digest = sha1.Sum([]byte(entry))
+		}
+
+		fn(digest)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return fmt.Errorf("password: reading breach list: %w", err)
+	}
+
+	return nil
+}
+
+// parseSHA1 parses an entry of a Have I Been Pwned list, "HASH" or
+// "HASH:count".
+func parseSHA1(entry string) ([sha1.Size]byte, bool) {
+	var digest [sha1.Size]byte
+
+	hash, _, _ := strings.Cut(entry, ":")
+	if len(hash) != hex.EncodedLen(sha1.Size) || strings.ToUpper(hash) != hash {
+		return digest, false
+	}
+
+	_, err := hex.Decode(digest[:], []byte(hash))
+	if err != nil {
+		return digest, false
+	}
+
+	return digest, true
+}
+
+// Add adds the password to the list.
+func (l *BreachList) Add(plaintextPassword string) {
+	l.add(sha1.Sum([]byte(plaintextPassword)))
+}
+
+// Contains reports whether the password is likely in the list.
+func (l *BreachList) Contains(plaintextPassword string) bool {
+	if l.m == 0 {
+		return false
+	}
+
+	h1, h2 := l.hashes(sha1.Sum([]byte(plaintextPassword)))
+
+	for i := uint64(0); i < l.k; i++ {
+		bit := (h1 + i*h2) % l.m
+		if l.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Len returns the number of entries added to the list.
+func (l *BreachList) Len() int {
+	return l.length
+}
+
+func (l *BreachList) add(digest [sha1.Size]byte) {
+	h1, h2 := l.hashes(digest)
+
+	for i := uint64(0); i < l.k; i++ {
+		bit := (h1 + i*h2) % l.m
+		l.bits[bit/64] |= 1 << (bit % 64)
+	}
+
+	l.length++
+}
+
+// hashes derives the two hashes combined into the k hashes of the filter from
+// the digest, which is already uniformly distributed.
+func (l *BreachList) hashes(digest [sha1.Size]byte) (uint64, uint64) {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+
+	return h1, h2
+}
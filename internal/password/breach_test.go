@@ -0,0 +1,108 @@
+package password
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBreachList(t *testing.T) {
+	const n = 10_000
+
+	list := NewBreachList(n, 0.001)
+
+	for i := range n {
+		list.Add(fmt.Sprintf("breached-%d", i))
+	}
+
+	if list.Len() != n {
+		t.Errorf("got length %d; want %d", list.Len(), n)
+	}
+
+	for i := range n {
+		if !list.Contains(fmt.Sprintf("breached-%d", i)) {
+			t.Fatalf("breached-%d: missing from the list", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := range n {
+		if list.Contains(fmt.Sprintf("safe-%d", i)) {
+			falsePositives++
+		}
+	}
+
+	// The expected rate is 0.1%, ten in ten thousand.
+	if falsePositives > 50 {
+		t.Errorf("got %d false positives in %d; want about %d", falsePositives, n, n/1000)
+	}
+}
+
+func TestBreachListEmpty(t *testing.T) {
+	var list BreachList
+
+	if list.Contains("password") {
+		t.Error("the zero value contains a password")
+	}
+}
+
+func TestLoadBreachList(t *testing.T) {
+	hash := func(password string) string {
+		return fmt.Sprintf("%X", sha1.Sum([]byte(password)))
+	}
+
+	lines := []string{
+		"# Passwords leaked in the test breach",
+		"",
+		"plaintext-password",
+		hash("hashed-password"),
+		hash("counted-password") + ":42",
+		// Lowercase hashes aren't in the Have I Been Pwned format, so they
+		// are taken as plaintext.
+		strings.ToLower(hash("lowercase-password")),
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := LoadBreachList(path, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if list.Len() != 4 {
+		t.Errorf("got length %d; want 4", list.Len())
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: "plaintext-password", want: true},
+		{password: "hashed-password", want: true},
+		{password: "counted-password", want: true},
+		{password: "lowercase-password", want: false},
+		{password: strings.ToLower(hash("lowercase-password")), want: true},
+		{password: "# Passwords leaked in the test breach", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := list.Contains(tt.password); got != tt.want {
+			t.Errorf("%s: got %t; want %t", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestLoadBreachListMissingFile(t *testing.T) {
+	_, err := LoadBreachList(filepath.Join(t.TempDir(), "missing.txt"), 0.001)
+	if err == nil {
+		t.Error("got no error; want one")
+	}
+}
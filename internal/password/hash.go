@@ -1,21 +1,101 @@
 package password
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// MaxLength is the maximum length of a password, in bytes. Argon2id has no
+// limit of its own, this only bounds the work done for a single request.
+const MaxLength = 256
+
+// Params are the argon2id parameters passwords are hashed with.
+type Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follow the second recommended option of RFC 9106, with less
+// parallelism.
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var ErrInvalidHash = errors.New("password: hash is not in a supported format")
+
+const argon2idPrefix = "$argon2id$"
+
+// Hash hashes the password with argon2id and DefaultParams, returning the hash
+// in the PHC string format.
 func Hash(plaintextPassword string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+	p := DefaultParams
+
+	salt := make([]byte, p.SaltLength)
+
+	_, err := rand.Read(salt)
 	if err != nil {
 		return "", err
 	}
 
-	return string(hashedPassword), nil
+	key := argon2.IDKey([]byte(plaintextPassword), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	hashedPassword := fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+
+	return hashedPassword, nil
 }
 
+// dummyHash is what a password is compared against when there is no hash, so
+// that the time taken doesn't reveal whether an employee has a password.
+var dummyHash = sync.OnceValues(func() (string, error) {
+	return Hash("dummy password")
+})
+
+// Matches reports whether the password matches the hash. Both argon2id hashes
+// and the bcrypt hashes made before argon2id was adopted are supported. No
+// password matches an empty hash, which employees signing in with single
+// sign-on only have, but it takes as long to find out as with any other hash.
 func Matches(plaintextPassword, hashedPassword string) (bool, error) {
+	if hashedPassword == "" {
+		dummy, err := dummyHash()
+		if err != nil {
+			return false, err
+		}
+
+		_, err = Matches(plaintextPassword, dummy)
+
+		return false, err
+	}
+
+	if strings.HasPrefix(hashedPassword, argon2idPrefix) {
+		p, salt, key, err := decodeArgon2id(hashedPassword)
+		if err != nil {
+			return false, err
+		}
+
+		otherKey := argon2.IDKey([]byte(plaintextPassword), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+		return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plaintextPassword))
 	if err != nil {
 		switch {
@@ -28,3 +108,55 @@ func Matches(plaintextPassword, hashedPassword string) (bool, error) {
 
 	return true, nil
 }
+
+// NeedsRehash reports whether the hash was made with another algorithm or other
+// parameters than Hash currently uses, and should be replaced by a new hash of
+// the password the next time it is known.
+func NeedsRehash(hashedPassword string) bool {
+	if !strings.HasPrefix(hashedPassword, argon2idPrefix) {
+		return true
+	}
+
+	p, _, _, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return true
+	}
+
+	return p != DefaultParams
+}
+
+func decodeArgon2id(hashedPassword string) (Params, []byte, []byte, error) {
+	var p Params
+
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism)
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrInvalidHash
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
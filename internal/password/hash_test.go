@@ -0,0 +1,107 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHash(t *testing.T) {
+	hashedPassword, err := Hash("x7#Kp2!vQz9@Lm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$", argon2.Version, DefaultParams.Memory, DefaultParams.Iterations, DefaultParams.Parallelism)
+	if !strings.HasPrefix(hashedPassword, prefix) {
+		t.Errorf("got hash %q; want the prefix %q", hashedPassword, prefix)
+	}
+
+	otherHash, err := Hash("x7#Kp2!vQz9@Lm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if otherHash == hashedPassword {
+		t.Error("got the same hash twice; want a new salt for every hash")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	argon2idHash, err := Hash("x7#Kp2!vQz9@Lm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("x7#Kp2!vQz9@Lm"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		password       string
+		hashedPassword string
+		want           bool
+		wantErr        error
+	}{
+		{name: "argon2id", password: "x7#Kp2!vQz9@Lm", hashedPassword: argon2idHash, want: true},
+		{name: "argon2id mismatch", password: "x7#Kp2!vQz9@Ln", hashedPassword: argon2idHash},
+		{name: "bcrypt", password: "x7#Kp2!vQz9@Lm", hashedPassword: string(bcryptHash), want: true},
+		{name: "bcrypt mismatch", password: "x7#Kp2!vQz9@Ln", hashedPassword: string(bcryptHash)},
+		{name: "empty hash", password: "x7#Kp2!vQz9@Lm"},
+		{name: "empty password and hash"},
+		{name: "malformed argon2id", password: "x7#Kp2!vQz9@Lm", hashedPassword: "$argon2id$v=19$m=65536$salt", wantErr: ErrInvalidHash},
+		{name: "other argon2 version", password: "x7#Kp2!vQz9@Lm", hashedPassword: strings.Replace(argon2idHash, "v=19", "v=16", 1), wantErr: ErrInvalidHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Matches(tt.password, tt.hashedPassword)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v; want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	current, err := Hash("x7#Kp2!vQz9@Lm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("x7#Kp2!vQz9@Lm"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		hashedPassword string
+		want           bool
+	}{
+		{name: "current", hashedPassword: current},
+		{name: "bcrypt", hashedPassword: string(bcryptHash), want: true},
+		{name: "fewer iterations", hashedPassword: strings.Replace(current, ",t=3,", ",t=1,", 1), want: true},
+		{name: "less memory", hashedPassword: strings.Replace(current, "m=65536,", "m=19456,", 1), want: true},
+		{name: "malformed", hashedPassword: "$argon2id$v=19$garbage", want: true},
+		{name: "empty", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hashedPassword); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
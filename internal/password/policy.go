@@ -0,0 +1,140 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy holds the rules passwords chosen by users must follow. The zero value
+// only enforces MaxLength and rejects the common passwords.
+type Policy struct {
+	// MinLength and MaxLength bound the length of passwords, in characters
+	// and bytes respectively. A MaxLength of zero or above the package
+	// MaxLength means the package MaxLength.
+	MinLength int
+	MaxLength int
+
+	// MinCharacterClasses is the number of character classes among
+	// lowercase letters, uppercase letters, digits and symbols a password
+	// must contain.
+	MinCharacterClasses int
+
+	// MinScore is the minimum strength score, between 0 and 4, of a
+	// password as estimated by Strength.
+	MinScore int
+
+	// Breached, when set, holds the passwords known to have leaked, which
+	// are rejected in addition to the common passwords.
+	Breached *BreachList
+}
+
+// Violation describes a rule of the policy a password breaks, with a stable
+// code to report it with.
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// minContextLength is the length below which the personal information given to
+// Check is too short to look for in passwords.
+const minContextLength = 3
+
+// Check returns the rules of the policy the password breaks, or nil when it
+// follows all of them. userInputs is the personal information of the user the
+// password is for, such as their name and email address, which the password
+// must not contain and which is cheap to guess.
+func (p Policy) Check(plaintextPassword string, userInputs ...string) []Violation {
+	var violations []Violation
+
+	maxLength := p.MaxLength
+	if maxLength <= 0 || maxLength > MaxLength {
+		maxLength = MaxLength
+	}
+
+	if utf8.RuneCountInString(plaintextPassword) < p.MinLength {
+		violations = append(violations, Violation{"password_too_short", fmt.Sprintf("Password must be at least %d characters long", p.MinLength)})
+	}
+
+	if len(plaintextPassword) > maxLength {
+		violations = append(violations, Violation{"password_too_long", fmt.Sprintf("Password must not be more than %d bytes long", maxLength)})
+	}
+
+	if characterClasses(plaintextPassword) < p.MinCharacterClasses {
+		message := fmt.Sprintf("Password must contain at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinCharacterClasses)
+		violations = append(violations, Violation{"password_too_simple", message})
+	}
+
+	words := contextWords(userInputs)
+
+	lower := strings.ToLower(plaintextPassword)
+	for _, word := range words {
+		if strings.Contains(lower, word) {
+			violations = append(violations, Violation{"password_contains_personal_info", "Password must not contain your name or email address"})
+			break
+		}
+	}
+
+	switch {
+	case IsCommon(plaintextPassword):
+		violations = append(violations, Violation{"password_too_common", "Password is too common"})
+	case p.Breached != nil && p.Breached.Contains(plaintextPassword):
+		violations = append(violations, Violation{"password_breached", "Password has appeared in a data breach, please choose another one"})
+	case p.MinScore > 0 && Strength(plaintextPassword, words...) < p.MinScore:
+		violations = append(violations, Violation{"password_too_weak", "Password is too easy to guess"})
+	}
+
+	return violations
+}
+
+// characterClasses returns how many of lowercase letters, uppercase letters,
+// digits and symbols s contains.
+func characterClasses(s string) int {
+	var lower, upper, digit, symbol int
+
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
+
+// contextWords splits the personal information of a user into the lowercase
+// words worth looking for in their password: the words of their name, and the
+// local part of their email address along with its words.
+func contextWords(userInputs []string) []string {
+	var words []string
+
+	add := func(word string) {
+		if utf8.RuneCountInString(word) >= minContextLength {
+			words = append(words, word)
+		}
+	}
+
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+
+		if local, _, ok := strings.Cut(input, "@"); ok {
+			add(local)
+			input = local
+		}
+
+		for _, word := range strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			add(word)
+		}
+	}
+
+	return words
+}
@@ -0,0 +1,65 @@
+package password
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	breached := NewBreachList(1, 0.001)
+	breached.Add("Leaked-Passw0rd!")
+
+	policy := Policy{
+		MinLength:           12,
+		MaxLength:           64,
+		MinCharacterClasses: 3,
+		MinScore:            3,
+		Breached:            breached,
+	}
+
+	tests := []struct {
+		name       string
+		password   string
+		userInputs []string
+		want       []string
+	}{
+		{name: "compliant", password: "x7#Kp2!vQz9@Lm"},
+		{name: "too short", password: "x7#Kp2!v", want: []string{"password_too_short"}},
+		{name: "too long", password: "x7#Kp2!vQz9@Lm" + strings.Repeat("a", 64), want: []string{"password_too_long"}},
+		{name: "too simple", password: "xkcdqpvmzbtrwl", want: []string{"password_too_simple"}},
+		{name: "personal info", password: "Lovelace#2024!x", userInputs: []string{"Ada Lovelace", "ada.lovelace@example.com"}, want: []string{"password_contains_personal_info"}},
+		{name: "common", password: "password", want: []string{"password_too_short", "password_too_simple", "password_too_common"}},
+		{name: "breached", password: "Leaked-Passw0rd!", want: []string{"password_breached"}},
+		{name: "too weak", password: "Aaaaaaaaaaaa1", want: []string{"password_too_weak"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, violation := range policy.Check(tt.password, tt.userInputs...) {
+				got = append(got, violation.Code)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyZeroValue(t *testing.T) {
+	var policy Policy
+
+	if violations := policy.Check("abc"); len(violations) != 0 {
+		t.Errorf("got %v; want no violation", violations)
+	}
+
+	if violations := policy.Check("123456"); len(violations) != 1 || violations[0].Code != "password_too_common" {
+		t.Errorf("got %v; want password_too_common", violations)
+	}
+
+	if violations := policy.Check(strings.Repeat("x", MaxLength+1)); len(violations) != 1 || violations[0].Code != "password_too_long" {
+		t.Errorf("got %v; want password_too_long", violations)
+	}
+}
@@ -0,0 +1,170 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// commonRanks maps the lowercase common passwords to their rank in
+// CommonPasswords, the most common first.
+var commonRanks = func() map[string]int {
+	ranks := make(map[string]int, len(CommonPasswords))
+	for i, p := range CommonPasswords {
+		if _, ok := ranks[p]; !ok {
+			ranks[p] = i + 1
+		}
+	}
+	return ranks
+}()
+
+// maxCommonLength is the length of the longest common password, the longest
+// substring of a password worth looking up in commonRanks.
+var maxCommonLength = func() int {
+	n := 0
+	for _, p := range CommonPasswords {
+		n = max(n, utf8.RuneCountInString(p))
+	}
+	return n
+}()
+
+// IsCommon reports whether the password is one of CommonPasswords.
+func IsCommon(plaintextPassword string) bool {
+	_, ok := commonRanks[strings.ToLower(plaintextPassword)]
+	return ok
+}
+
+// keyboardRows are the rows of a QWERTY keyboard, sequences of which are
+// guessed early.
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// Strength estimates how hard the password is to guess, on the same 0 to 4
+// scale as zxcvbn: 0 is guessable in under 10^3 guesses, 1 under 10^6, 2 under
+// 10^8, 3 under 10^10 and 4 takes more. The password is split into the
+// cheapest sequence of common passwords, userInputs, repeated characters,
+// alphabetical or keyboard sequences and single characters, and the guesses
+// for each are multiplied.
+func Strength(plaintextPassword string, userInputs ...string) int {
+	bits := entropy([]rune(plaintextPassword), userInputs)
+
+	switch {
+	case bits < 10:
+		return 0
+	case bits < 20:
+		return 1
+	case bits < 26.6:
+		return 2
+	case bits < 33.2:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// entropy returns the base 2 logarithm of the number of guesses needed to find
+// the password.
+func entropy(password []rune, userInputs []string) float64 {
+	lower := []rune(strings.ToLower(string(password)))
+
+	inputs := make(map[string]bool, len(userInputs))
+	maxInputLength := 0
+	for _, input := range userInputs {
+		inputs[input] = true
+		maxInputLength = max(maxInputLength, utf8.RuneCountInString(input))
+	}
+
+	// best[i] is the entropy of the cheapest split of password[:i], every
+	// match after the first costing an extra bit for its position.
+	best := make([]float64, len(password)+1)
+	for i := 1; i <= len(password); i++ {
+		best[i] = math.Inf(1)
+	}
+
+	for i := range password {
+		if math.IsInf(best[i], 1) {
+			continue
+		}
+
+		cost := best[i]
+		if i > 0 {
+			cost++
+		}
+
+		relax := func(j int, bits float64) {
+			best[j] = min(best[j], cost+bits)
+		}
+
+		relax(i+1, math.Log2(charsetSize(password[i])))
+
+		for j := i + minContextLength; j <= len(password) && j-i <= max(maxCommonLength, maxInputLength); j++ {
+			word := string(lower[i:j])
+			uppercase := 0.0
+			if string(password[i:j]) != word {
+				uppercase = 1
+			}
+
+			if inputs[word] {
+				relax(j, uppercase)
+			}
+
+			if rank, ok := commonRanks[word]; ok {
+				relax(j, math.Log2(float64(rank)+1)+uppercase)
+			}
+		}
+
+		// Repeated characters: guessing the character, then the length.
+		j := i + 1
+		for j < len(password) && password[j] == password[i] {
+			j++
+		}
+		for k := i + 3; k <= j; k++ {
+			relax(k, math.Log2(charsetSize(password[i]))+math.Log2(float64(k-i)))
+		}
+
+		// Alphabetical and numerical sequences, ascending or descending:
+		// guessing the start and the direction, then the length.
+		for _, step := range []rune{1, -1} {
+			j := i + 1
+			for j < len(password) && lower[j]-lower[j-1] == step {
+				j++
+			}
+			for k := i + 3; k <= j; k++ {
+				relax(k, math.Log2(charsetSize(password[i]))+1+math.Log2(float64(k-i)))
+			}
+		}
+
+		// Keyboard sequences: guessing the row and the start, then the
+		// length.
+		for _, row := range keyboardRows {
+			start := strings.IndexRune(row, lower[i])
+			if start < 0 {
+				continue
+			}
+
+			j := i
+			for j < len(password) && start+j-i < len(row) && rune(row[start+j-i]) == lower[j] {
+				j++
+			}
+			for k := i + 4; k <= j; k++ {
+				relax(k, math.Log2(float64(len(keyboardRows)*len(row)))+math.Log2(float64(k-i)))
+			}
+		}
+	}
+
+	return best[len(password)]
+}
+
+// charsetSize returns the number of characters of the class of r.
+func charsetSize(r rune) float64 {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	case r >= '0' && r <= '9':
+		return 10
+	case r < utf8.RuneSelf && unicode.IsPrint(r):
+		return 33
+	default:
+		return 100
+	}
+}
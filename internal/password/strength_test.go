@@ -0,0 +1,57 @@
+package password
+
+import "testing"
+
+func TestStrength(t *testing.T) {
+	tests := []struct {
+		password   string
+		userInputs []string
+		want       int
+	}{
+		{password: "", want: 0},
+		{password: "password", want: 0},
+		{password: "Password", want: 0},
+		{password: "aaaaaaaa", want: 0},
+		{password: "abcdefgh", want: 0},
+		{password: "qwertyuiop", want: 0},
+		{password: "ada1815", userInputs: []string{"ada", "lovelace"}, want: 1},
+		{password: "x7#Kp2!vQz9@Lm", want: 4},
+		{password: "correct horse battery staple", want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			got := Strength(tt.password, tt.userInputs...)
+			if got != tt.want {
+				t.Errorf("got %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrengthUserInputs(t *testing.T) {
+	without := Strength("lovelace1815")
+	with := Strength("lovelace1815", "ada", "lovelace")
+
+	if with >= without {
+		t.Errorf("got %d with the user inputs and %d without; want less with them", with, without)
+	}
+}
+
+func TestIsCommon(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: "password", want: true},
+		{password: "PASSWORD", want: true},
+		{password: "qwerty", want: true},
+		{password: "x7#Kp2!vQz9@Lm", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsCommon(tt.password); got != tt.want {
+			t.Errorf("%s: got %t; want %t", tt.password, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package pubsub
+
+import "sync"
+
+// Broker fans out published messages to every current subscriber. Publishing
+// never blocks: a subscriber that falls behind has its channel closed and is
+// expected to subscribe again and catch up on its own.
+type Broker[T any] struct {
+	mu          sync.Mutex
+	subscribers map[chan T]struct{}
+	buffer      int
+}
+
+func NewBroker[T any](buffer int) *Broker[T] {
+	return &Broker[T]{
+		subscribers: make(map[chan T]struct{}),
+		buffer:      buffer,
+	}
+}
+
+// Subscribe returns a channel receiving every message published from now on,
+// and a function that must be called to stop the subscription.
+func (b *Broker[T]) Subscribe() (<-chan T, func()) {
+	ch := make(chan T, b.buffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+func (b *Broker[T]) Publish(msg T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- msg:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket allowing Burst requests at once, refilled at the
+// rate of Burst requests per Period. The zero Limit allows everything.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseLimit parses a limit written as "<burst>/<period>", such as "5/15m".
+func ParseLimit(s string) (Limit, error) {
+	burst, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, must be <requests>/<period>", s)
+	}
+
+	n, err := strconv.Atoi(burst)
+	if err != nil || n < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, the number of requests must be a positive integer", s)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, the period must be a positive duration", s)
+	}
+
+	return Limit{Burst: n, Period: d}, nil
+}
+
+func (l Limit) IsZero() bool {
+	return l.Burst == 0
+}
+
+func (l Limit) String() string {
+	if l.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprintf("%d/%s", l.Burst, l.Period)
+}
+
+// Set implements flag.Value. An empty value disables the limit.
+func (l *Limit) Set(value string) error {
+	if value == "" {
+		*l = Limit{}
+		return nil
+	}
+
+	limit, err := ParseLimit(value)
+	if err != nil {
+		return err
+	}
+
+	*l = limit
+
+	return nil
+}
+
+// rate returns the number of tokens added to the bucket per second.
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// Result is the outcome of taking a token. RetryAfter is how long until a
+// token is available again when the request is not allowed.
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	if allowed {
+		return Result{Allowed: true}
+	}
+
+	seconds := (1 - tokens) / limit.rate()
+
+	return Result{RetryAfter: time.Duration(math.Ceil(seconds * float64(time.Second)))}
+}
+
+// Store keeps the token buckets. Take removes a token from the bucket of key,
+// refilling it first according to limit, and reports whether one was
+// available.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
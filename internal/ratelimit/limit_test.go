@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "5/15m", want: Limit{Burst: 5, Period: 15 * time.Minute}},
+		{value: "120/1m", want: Limit{Burst: 120, Period: time.Minute}},
+		{value: "0/1s", want: Limit{Period: time.Second}},
+		{value: "5", wantErr: true},
+		{value: "five/1m", wantErr: true},
+		{value: "-1/1m", wantErr: true},
+		{value: "5/minute", wantErr: true},
+		{value: "5/0s", wantErr: true},
+		{value: "5/-1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLimit(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v; want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitSet(t *testing.T) {
+	limit := Limit{Burst: 5, Period: time.Minute}
+
+	err := limit.Set("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !limit.IsZero() || limit.String() != "" {
+		t.Errorf("got %q; want the limit disabled", limit)
+	}
+
+	err = limit.Set("20/1m0s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if limit.String() != "20/1m0s" {
+		t.Errorf("got %q; want %q", limit, "20/1m0s")
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	limit := Limit{Burst: 5, Period: 15 * time.Minute}
+
+	tests := []struct {
+		name    string
+		tokens  float64
+		allowed bool
+		want    Result
+	}{
+		{name: "allowed", tokens: 0, allowed: true, want: Result{Allowed: true}},
+		{name: "empty", tokens: 0, want: Result{RetryAfter: 3 * time.Minute}},
+		{name: "half a token", tokens: 0.5, want: Result{RetryAfter: 90 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newResult(limit, tt.tokens, tt.allowed); got != tt.want {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Burst: 3, Period: 3 * time.Minute}
+
+	for i := range limit.Burst {
+		res, err := s.Take(context.Background(), "client", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !res.Allowed {
+			t.Fatalf("request %d: denied; want the burst allowed", i+1)
+		}
+	}
+
+	res, err := s.Take(context.Background(), "client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > time.Minute {
+		t.Errorf("got %+v; want a denial with a retry within a minute", res)
+	}
+
+	// Other keys have buckets of their own.
+	res, err = s.Take(context.Background(), "other client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Allowed {
+		t.Error("other key denied")
+	}
+
+	// A token is added every minute.
+	s.buckets["client"].updated = s.buckets["client"].updated.Add(-time.Minute)
+
+	res, err = s.Take(context.Background(), "client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Allowed {
+		t.Error("denied after a minute; want the refilled token taken")
+	}
+
+	// The bucket holds no more than the burst, however long it waited.
+	s.buckets["client"].updated = s.buckets["client"].updated.Add(-time.Hour)
+
+	for range limit.Burst {
+		s.Take(context.Background(), "client", limit)
+	}
+
+	res, err = s.Take(context.Background(), "client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Allowed {
+		t.Error("allowed more than the burst")
+	}
+}
+
+func TestMemoryStoreZeroLimit(t *testing.T) {
+	s := NewMemoryStore()
+
+	for range 10 {
+		res, err := s.Take(context.Background(), "client", Limit{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !res.Allowed {
+			t.Fatal("denied by the zero limit")
+		}
+	}
+
+	if len(s.buckets) != 0 {
+		t.Errorf("got %d buckets; want none for the zero limit", len(s.buckets))
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s := NewMemoryStore()
+	limit := Limit{Burst: 2, Period: time.Minute}
+
+	s.Take(context.Background(), "full", limit)
+	s.Take(context.Background(), "empty", limit)
+	s.Take(context.Background(), "empty", limit)
+
+	s.buckets["full"].fullAt = time.Now().Add(-time.Second)
+	s.lastSweep = time.Now().Add(-sweepInterval)
+
+	s.Take(context.Background(), "other", limit)
+
+	if _, ok := s.buckets["full"]; ok {
+		t.Error("full bucket not swept")
+	}
+
+	if _, ok := s.buckets["empty"]; !ok {
+		t.Error("bucket still refilling swept")
+	}
+}
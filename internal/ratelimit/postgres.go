@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+)
+
+// PostgresStore keeps the buckets in the rate_limits table, so the limits are
+// shared by every instance of the application. Each Take is a single atomic
+// statement.
+type PostgresStore struct {
+	queries   database.Querier
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresStore(queries database.Querier) *PostgresStore {
+	return &PostgresStore{
+		queries:   queries,
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.IsZero() {
+		return Result{Allowed: true}, nil
+	}
+
+	err := s.sweep(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+
+	row, err := s.queries.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: float64(limit.Burst),
+		Rate:  limit.rate(),
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return newResult(limit, row.Tokens, row.Allowed), nil
+}
+
+// sweep deletes the buckets that refilled completely. It runs at most once per
+// sweepInterval on each instance.
+func (s *PostgresStore) sweep(ctx context.Context) error {
+	s.mu.Lock()
+
+	if time.Since(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	_, err := s.queries.DeleteFullRateLimits(ctx)
+
+	return err
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	database "github.com/brGuirra/uai/internal/database/sqlc"
+)
+
+// testQuerier records the buckets the PostgresStore takes tokens from, and
+// answers with tokens.
+type testQuerier struct {
+	database.Querier
+	takes  []database.TakeRateLimitTokenParams
+	tokens float64
+	sweeps int
+}
+
+func (q *testQuerier) TakeRateLimitToken(ctx context.Context, arg database.TakeRateLimitTokenParams) (database.TakeRateLimitTokenRow, error) {
+	q.takes = append(q.takes, arg)
+
+	if q.tokens >= 1 {
+		q.tokens--
+		return database.TakeRateLimitTokenRow{Tokens: q.tokens, Allowed: true}, nil
+	}
+
+	return database.TakeRateLimitTokenRow{Tokens: q.tokens}, nil
+}
+
+func (q *testQuerier) DeleteFullRateLimits(ctx context.Context) (int64, error) {
+	q.sweeps++
+	return 0, nil
+}
+
+func TestPostgresStoreTake(t *testing.T) {
+	q := &testQuerier{tokens: 1.25}
+	s := NewPostgresStore(q)
+	limit := Limit{Burst: 5, Period: 15 * time.Minute}
+
+	res, err := s.Take(context.Background(), "login-ip:192.0.2.1", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Allowed {
+		t.Errorf("got %+v; want allowed", res)
+	}
+
+	want := database.TakeRateLimitTokenParams{Key: "login-ip:192.0.2.1", Burst: 5, Rate: 5.0 / 900}
+	if len(q.takes) != 1 || q.takes[0] != want {
+		t.Errorf("got buckets %+v; want %+v", q.takes, want)
+	}
+
+	res, err = s.Take(context.Background(), "login-ip:192.0.2.1", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token is added every 3 minutes, and a quarter is left.
+	if res.Allowed || res.RetryAfter != 135*time.Second {
+		t.Errorf("got %+v; want a retry after 2m15s", res)
+	}
+}
+
+func TestPostgresStoreZeroLimit(t *testing.T) {
+	q := &testQuerier{}
+	s := NewPostgresStore(q)
+
+	res, err := s.Take(context.Background(), "api:192.0.2.1", Limit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Allowed || len(q.takes) != 0 {
+		t.Errorf("got %+v after %d queries; want allowed without any", res, len(q.takes))
+	}
+}
+
+func TestPostgresStoreSweep(t *testing.T) {
+	q := &testQuerier{tokens: 10}
+	s := NewPostgresStore(q)
+	limit := Limit{Burst: 10, Period: time.Minute}
+
+	s.Take(context.Background(), "api:192.0.2.1", limit)
+	s.Take(context.Background(), "api:192.0.2.1", limit)
+
+	if q.sweeps != 0 {
+		t.Errorf("got %d sweeps; want none within the interval", q.sweeps)
+	}
+
+	s.lastSweep = time.Now().Add(-sweepInterval)
+
+	s.Take(context.Background(), "api:192.0.2.1", limit)
+	s.Take(context.Background(), "api:192.0.2.1", limit)
+
+	if q.sweeps != 1 {
+		t.Errorf("got %d sweeps; want 1 once the interval elapsed", q.sweeps)
+	}
+}
+
+// TestPostgresStoreBucket runs the token bucket of the rate_limits table. It
+// needs a migrated database, whose DSN is given in UAI_TEST_DB_DSN, in the
+// same format as the -db-dsn flag.
+func TestPostgresStoreBucket(t *testing.T) {
+	dsn := os.Getenv("UAI_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("UAI_TEST_DB_DSN is not set")
+	}
+
+	store, err := database.NewStore(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewPostgresStore(store)
+	limit := Limit{Burst: 3, Period: time.Hour}
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+
+	for i := range limit.Burst {
+		res, err := s.Take(context.Background(), key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !res.Allowed {
+			t.Fatalf("request %d: denied; want the burst allowed", i+1)
+		}
+	}
+
+	res, err := s.Take(context.Background(), key, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A token is added every 20 minutes, and almost none was since the first
+	// request.
+	if res.Allowed || math.Abs((res.RetryAfter-20*time.Minute).Seconds()) > 5 {
+		t.Errorf("got %+v; want a retry after about 20m", res)
+	}
+}
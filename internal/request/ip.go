@@ -0,0 +1,101 @@
+package request
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies are the addresses of the proxies in front of the application,
+// whose X-Forwarded-For and X-Real-IP headers are believed. It implements
+// flag.Value, written as IP addresses or CIDR ranges separated by spaces.
+type TrustedProxies []netip.Prefix
+
+func (p TrustedProxies) String() string {
+	entries := make([]string, len(p))
+
+	for i, prefix := range p {
+		entries[i] = prefix.String()
+	}
+
+	return strings.Join(entries, " ")
+}
+
+func (p *TrustedProxies) Set(value string) error {
+	var proxies TrustedProxies
+
+	for _, entry := range strings.Fields(value) {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return fmt.Errorf("invalid trusted proxy %q, must be an IP address or a CIDR range", entry)
+			}
+
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+
+		proxies = append(proxies, prefix.Masked())
+	}
+
+	*p = proxies
+
+	return nil
+}
+
+// Contains reports whether addr is the address of a trusted proxy.
+func (p TrustedProxies) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP returns the IP address of the client of r. When the request comes
+// from a trusted proxy, it is the rightmost address of X-Forwarded-For that
+// isn't a trusted proxy, or else the X-Real-IP header, so clients can't choose
+// their address by sending the headers themselves.
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	client, err := netip.ParseAddr(host)
+	if err != nil || !p.Contains(client) {
+		return host
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+		if err != nil {
+			return client.Unmap().String()
+		}
+
+		return realIP.Unmap().String()
+	}
+
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+
+		client = hop
+		if !p.Contains(client) {
+			break
+		}
+	}
+
+	return client.Unmap().String()
+}
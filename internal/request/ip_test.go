@@ -0,0 +1,72 @@
+package request
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxiesSet(t *testing.T) {
+	var proxies TrustedProxies
+
+	err := proxies.Set("10.0.0.0/8 192.0.2.10 ::ffff:198.51.100.7 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "10.0.0.0/8 192.0.2.10/32 198.51.100.7/32 2001:db8::/32"
+	if got := proxies.String(); got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	err = proxies.Set("10.0.0.0/8 proxy.internal")
+	if err == nil {
+		t.Error("got no error for a host name; want one")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	var proxies TrustedProxies
+
+	err := proxies.Set("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		realIP       string
+		want         string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.5:5123", want: "203.0.113.5"},
+		{name: "spoofed by a direct client", remoteAddr: "203.0.113.5:5123", forwardedFor: []string{"198.51.100.1"}, realIP: "198.51.100.2", want: "203.0.113.5"},
+		{name: "through a proxy", remoteAddr: "10.0.0.2:5123", forwardedFor: []string{"203.0.113.5"}, want: "203.0.113.5"},
+		{name: "through proxies", remoteAddr: "10.0.0.2:5123", forwardedFor: []string{"203.0.113.5, 10.0.0.3"}, want: "203.0.113.5"},
+		{name: "spoofed through a proxy", remoteAddr: "10.0.0.2:5123", forwardedFor: []string{"198.51.100.1, 203.0.113.5"}, want: "203.0.113.5"},
+		{name: "several headers", remoteAddr: "10.0.0.2:5123", forwardedFor: []string{"198.51.100.1", "203.0.113.5"}, want: "203.0.113.5"},
+		{name: "garbage", remoteAddr: "10.0.0.2:5123", forwardedFor: []string{"203.0.113.5, garbage, 10.0.0.3"}, want: "10.0.0.3"},
+		{name: "real IP", remoteAddr: "10.0.0.2:5123", realIP: "203.0.113.5", want: "203.0.113.5"},
+		{name: "proxy only", remoteAddr: "10.0.0.2:5123", want: "10.0.0.2"},
+		{name: "IPv6", remoteAddr: "[2001:db8::1]:5123", forwardedFor: []string{"198.51.100.1"}, want: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := proxies.ClientIP(r); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package response
+
+import (
+	"net/http"
+
+	"github.com/brGuirra/uai/internal/validator"
+)
+
+const problemTypePrefix = "urn:uai:problem:"
+
+// Problem is an RFC 7807 problem details object. Code is a stable,
+// machine-readable identifier of the kind of problem, which is also the last
+// segment of Type.
+type Problem struct {
+	Type      string                       `json:"type"`
+	Title     string                       `json:"title"`
+	Status    int                          `json:"status"`
+	Detail    string                       `json:"detail,omitempty"`
+	Instance  string                       `json:"instance,omitempty"`
+	Code      string                       `json:"code"`
+	RequestID string                       `json:"requestId,omitempty"`
+	Errors    map[string][]validator.Error `json:"errors,omitempty"`
+}
+
+// NewProblem returns a problem with the type and title derived from code and
+// status.
+func NewProblem(status int, code, detail string) Problem {
+	return Problem{
+		Type:   problemTypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// ProblemJSON writes p as an application/problem+json response.
+func ProblemJSON(w http.ResponseWriter, p Problem, headers http.Header) error {
+	return write(w, p.Status, "application/problem+json", p, headers)
+}
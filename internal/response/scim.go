@@ -0,0 +1,9 @@
+package response
+
+import "net/http"
+
+// SCIMJSON writes data as an application/scim+json response, the media type of
+// SCIM 2.0.
+func SCIMJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
+	return write(w, status, "application/scim+json", data, headers)
+}
@@ -0,0 +1,128 @@
+package scim
+
+// ServiceProviderConfig returns the configuration of the service provider,
+// served under baseURL, as of RFC 7643 section 5.
+func ServiceProviderConfig(baseURL string) map[string]any {
+	return map[string]any{
+		"schemas":        []string{ServiceProviderConfigSchema},
+		"patch":          map[string]any{"supported": true},
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": MaxResults},
+		"changePassword": map[string]any{"supported": true},
+		"sort":           map[string]any{"supported": false},
+		"etag":           map[string]any{"supported": true},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Authentication with the SCIM bearer token of the application",
+			"primary":     true,
+		}},
+		"meta": Meta{
+			ResourceType: "ServiceProviderConfig",
+			Location:     baseURL + "/ServiceProviderConfig",
+		},
+	}
+}
+
+// ResourceTypes returns the resource types of the service provider, served
+// under baseURL, as of RFC 7643 section 6.
+func ResourceTypes(baseURL string) []any {
+	return []any{
+		resourceType(baseURL, "User", "/Users", UserSchema),
+		resourceType(baseURL, "Group", "/Groups", GroupSchema),
+	}
+}
+
+func resourceType(baseURL, name, endpoint, schema string) map[string]any {
+	return map[string]any{
+		"schemas":     []string{ResourceTypeSchema},
+		"id":          name,
+		"name":        name,
+		"endpoint":    endpoint,
+		"description": name,
+		"schema":      schema,
+		"meta": Meta{
+			ResourceType: "ResourceType",
+			Location:     baseURL + "/ResourceTypes/" + name,
+		},
+	}
+}
+
+// Schemas returns the definitions of the attributes of the resources the
+// service provider supports, served under baseURL, as of RFC 7643 section 7.
+func Schemas(baseURL string) []any {
+	return []any{
+		schema(baseURL, UserSchema, "User", "User Account", []map[string]any{
+			attribute("userName", "string", true, "readWrite", "server", "The email address of the employee"),
+			subAttributes(attribute("name", "complex", false, "readWrite", "none", "The name of the employee"),
+				attribute("formatted", "string", false, "readWrite", "none", "The full name"),
+				attribute("givenName", "string", false, "readWrite", "none", "The given name"),
+				attribute("familyName", "string", false, "readWrite", "none", "The family name"),
+			),
+			attribute("displayName", "string", false, "readWrite", "none", "The name of the employee"),
+			multiValued(subAttributes(attribute("emails", "complex", false, "readOnly", "none", "The email address of the employee, which is their userName"),
+				attribute("value", "string", false, "readOnly", "none", "The email address"),
+				attribute("type", "string", false, "readOnly", "none", "Always work"),
+				attribute("primary", "boolean", false, "readOnly", "none", "Always true"),
+			)),
+			attribute("active", "boolean", false, "readWrite", "none", "Whether the employee is active, inactive employees are deactivated"),
+			neverReturned(attribute("password", "string", false, "writeOnly", "none", "The password of the employee, which must comply with the password policy")),
+			multiValued(subAttributes(attribute("groups", "complex", false, "readOnly", "none", "The roles of the employee"),
+				attribute("value", "string", false, "readOnly", "none", "The ID of the role"),
+				attribute("display", "string", false, "readOnly", "none", "The name of the role"),
+				attribute("$ref", "reference", false, "readOnly", "none", "The URI of the role"),
+			)),
+		}),
+		schema(baseURL, GroupSchema, "Group", "Role", []map[string]any{
+			attribute("displayName", "string", true, "readWrite", "server", "The name of the role"),
+			multiValued(subAttributes(attribute("members", "complex", false, "readWrite", "none", "The employees holding the role"),
+				attribute("value", "string", false, "immutable", "none", "The ID of the employee"),
+				attribute("display", "string", false, "readOnly", "none", "The name of the employee"),
+				attribute("$ref", "reference", false, "readOnly", "none", "The URI of the employee"),
+			)),
+		}),
+	}
+}
+
+func schema(baseURL, id, name, description string, attributes []map[string]any) map[string]any {
+	return map[string]any{
+		"schemas":     []string{SchemaSchema},
+		"id":          id,
+		"name":        name,
+		"description": description,
+		"attributes":  attributes,
+		"meta": Meta{
+			ResourceType: "Schema",
+			Location:     baseURL + "/Schemas/" + id,
+		},
+	}
+}
+
+func attribute(name, typ string, required bool, mutability, uniqueness, description string) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"type":        typ,
+		"multiValued": false,
+		"description": description,
+		"required":    required,
+		"caseExact":   false,
+		"mutability":  mutability,
+		"returned":    "default",
+		"uniqueness":  uniqueness,
+	}
+}
+
+func subAttributes(a map[string]any, subAttributes ...map[string]any) map[string]any {
+	a["subAttributes"] = subAttributes
+	return a
+}
+
+func neverReturned(a map[string]any) map[string]any {
+	a["returned"] = "never"
+	return a
+}
+
+func multiValued(a map[string]any) map[string]any {
+	a["multiValued"] = true
+	return a
+}
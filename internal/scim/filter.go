@@ -0,0 +1,109 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnsupportedFilter = errors.New("scim: only filters of the form 'attribute eq \"value\"' are supported")
+	ErrInvalidPathSyntax = errors.New("scim: invalid attribute path")
+)
+
+// Filter is an equality filter, the only kind identity providers use to look
+// up resources before provisioning them.
+type Filter struct {
+	// Attribute is the lowercase attribute path, such as username or
+	// emails.value.
+	Attribute string
+	Value     string
+}
+
+// ParseFilter parses a filter of the form 'attribute eq "value"'. Attribute
+// names are case-insensitive.
+func ParseFilter(filter string) (Filter, error) {
+	attribute, rest, ok := strings.Cut(strings.TrimSpace(filter), " ")
+	if !ok {
+		return Filter{}, ErrUnsupportedFilter
+	}
+
+	operator, value, ok := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok || !strings.EqualFold(operator, "eq") {
+		return Filter{}, ErrUnsupportedFilter
+	}
+
+	var s string
+
+	err := json.Unmarshal([]byte(strings.TrimSpace(value)), &s)
+	if err != nil {
+		return Filter{}, ErrUnsupportedFilter
+	}
+
+	return Filter{Attribute: strings.ToLower(attribute), Value: s}, nil
+}
+
+// Path is the attribute path of a PATCH operation, such as name.givenName or
+// members[value eq "2819c223"].
+type Path struct {
+	// Attribute and SubAttribute are lowercase.
+	Attribute    string
+	SubAttribute string
+
+	// ValueFilter selects the values of a multi-valued attribute.
+	ValueFilter *Filter
+}
+
+// ParsePath parses the attribute path of a PATCH operation. An empty path
+// returns the zero Path, targeting the whole resource.
+func ParsePath(path string) (Path, error) {
+	var p Path
+
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return p, nil
+	}
+
+	// Schema URNs prefixing core attributes are dropped.
+	for _, schema := range []string{UserSchema + ":", GroupSchema + ":"} {
+		if len(path) > len(schema) && strings.EqualFold(path[:len(schema)], schema) {
+			path = path[len(schema):]
+		}
+	}
+
+	if i := strings.IndexByte(path, '['); i >= 0 {
+		j := strings.LastIndexByte(path, ']')
+		if j < i {
+			return p, ErrInvalidPathSyntax
+		}
+
+		filter, err := ParseFilter(path[i+1 : j])
+		if err != nil {
+			return p, err
+		}
+
+		p.ValueFilter = &filter
+
+		rest := path[j+1:]
+		if rest != "" {
+			if rest[0] != '.' || len(rest) == 1 {
+				return p, ErrInvalidPathSyntax
+			}
+
+			p.SubAttribute = strings.ToLower(rest[1:])
+		}
+
+		path = path[:i]
+	} else if attribute, subAttribute, ok := strings.Cut(path, "."); ok {
+		path = attribute
+		p.SubAttribute = strings.ToLower(subAttribute)
+	}
+
+	if path == "" {
+		return p, ErrInvalidPathSyntax
+	}
+
+	p.Attribute = strings.ToLower(path)
+
+	return p, nil
+}
@@ -0,0 +1,92 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PatchRequest is the body of a PATCH request.
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is an operation of a PATCH request. Op is lowercase once the
+// request is validated, as some identity providers capitalize it.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Validate checks the schema of the request and the kind of its operations,
+// normalizing the op of every operation to lowercase.
+func (p *PatchRequest) Validate() error {
+	if len(p.Schemas) != 1 || p.Schemas[0] != PatchOpSchema {
+		return fmt.Errorf("scim: PATCH requests must use the %s schema", PatchOpSchema)
+	}
+
+	if len(p.Operations) == 0 {
+		return fmt.Errorf("scim: PATCH requests must have at least one operation")
+	}
+
+	for i := range p.Operations {
+		op := &p.Operations[i]
+		op.Op = strings.ToLower(op.Op)
+
+		switch op.Op {
+		case "add", "replace":
+			if len(op.Value) == 0 {
+				return fmt.Errorf("scim: %s operations must have a value", op.Op)
+			}
+		case "remove":
+			if op.Path == "" {
+				return fmt.Errorf("scim: remove operations must have a path")
+			}
+		default:
+			return fmt.Errorf("scim: unsupported operation %q", op.Op)
+		}
+	}
+
+	return nil
+}
+
+// Attributes returns the value of an operation without path as a map of
+// lowercase attribute names to values, flattening sub-attributes as in
+// name.givenname. Identity providers use this form to replace several
+// attributes at once.
+func (op PatchOperation) Attributes() (map[string]json.RawMessage, error) {
+	var values map[string]json.RawMessage
+
+	err := json.Unmarshal(op.Value, &values)
+	if err != nil {
+		return nil, fmt.Errorf("scim: the value of an operation without path must be an object")
+	}
+
+	attributes := make(map[string]json.RawMessage, len(values))
+
+	for name, value := range values {
+		path, err := ParsePath(name)
+		if err != nil {
+			return nil, err
+		}
+
+		name = path.Attribute
+		if path.SubAttribute != "" {
+			name += "." + path.SubAttribute
+		}
+
+		var sub map[string]json.RawMessage
+		if name == "name" && json.Unmarshal(value, &sub) == nil {
+			for subName, subValue := range sub {
+				attributes["name."+strings.ToLower(subName)] = subValue
+			}
+			continue
+		}
+
+		attributes[name] = value
+	}
+
+	return attributes, nil
+}
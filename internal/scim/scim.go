@@ -0,0 +1,141 @@
+// Package scim implements the protocol parts of SCIM 2.0 (RFC 7643 and RFC
+// 7644) that don't depend on how resources are stored: messages, filters,
+// PATCH operations, ETags and the discovery documents.
+package scim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	UserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	ServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	ResourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// Error types of RFC 7644 section 3.12.
+const (
+	ErrInvalidFilter = "invalidFilter"
+	ErrUniqueness    = "uniqueness"
+	ErrMutability    = "mutability"
+	ErrInvalidSyntax = "invalidSyntax"
+	ErrInvalidPath   = "invalidPath"
+	ErrInvalidValue  = "invalidValue"
+	ErrNoTarget      = "noTarget"
+)
+
+// MaxResults is the maximum number of resources returned in a page of a list.
+const MaxResults = 100
+
+// Error is a SCIM error response. It is also an error, so it can be returned
+// by the code handling a request down to the handler.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (e Error) Error() string {
+	return "scim: " + e.Detail
+}
+
+// StatusCode returns the HTTP status of the error.
+func (e Error) StatusCode() int {
+	status, _ := strconv.Atoi(e.Status)
+	return status
+}
+
+func NewError(status int, scimType, detail string) Error {
+	return Error{
+		Schemas:  []string{ErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+// ListResponse is a page of resources.
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+func NewListResponse(total int64, startIndex int, resources []any) ListResponse {
+	if resources == nil {
+		resources = []any{}
+	}
+
+	return ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// Meta is the meta attribute of resources.
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+	Version      string `json:"version,omitempty"`
+}
+
+// Pagination returns the 1-based start index and the count requested by the
+// startIndex and count query parameters, defaulting to the first page of
+// MaxResults resources.
+func Pagination(r *http.Request) (startIndex, count int) {
+	startIndex, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+
+	count, err = strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count > MaxResults {
+		count = MaxResults
+	}
+
+	return startIndex, max(count, 0)
+}
+
+// ETag returns a weak entity tag of the representation of a resource, v being
+// the resource without its meta attribute.
+func ETag(v any) (string, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(js)
+
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// MatchesETag reports whether an If-Match or If-None-Match header value holds
+// etag or is a wildcard. Weak comparison is used, as with the ETags of ETag.
+func MatchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,218 @@
+package settings
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[redacted]"
+
+var rgxDSNPassword = regexp.MustCompile(`^([^:@/]+):([^@]+)@`)
+
+// Load fills in every flag of fs that was not set on the command line. A value
+// is taken from the <prefix>_<FLAG_NAME> environment variable when it exists,
+// or else from the configuration file at path. This gives the precedence:
+// defaults < configuration file < environment variables < command-line flags.
+//
+// The configuration file may be YAML (.yml, .yaml) or TOML (.toml). Its keys
+// are flag names, and nested tables are joined with dashes, so
+//
+//	smtp:
+//	  host: localhost
+//
+// sets the smtp-host flag. An empty path skips the configuration file.
+func Load(fs *flag.FlagSet, prefix, path string) error {
+	fileValues := map[string]string{}
+
+	if path != "" {
+		var err error
+
+		fileValues, err = readFile(path)
+		if err != nil {
+			return err
+		}
+
+		for name := range fileValues {
+			if fs.Lookup(name) == nil {
+				return fmt.Errorf("settings: unknown setting %q in %s", name, path)
+			}
+		}
+	}
+
+	setOnCommandLine := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	var errs []error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if setOnCommandLine[f.Name] {
+			return
+		}
+
+		if value, ok := os.LookupEnv(EnvName(prefix, f.Name)); ok {
+			errs = append(errs, set(fs, f.Name, value, EnvName(prefix, f.Name)))
+			return
+		}
+
+		if value, ok := fileValues[f.Name]; ok {
+			errs = append(errs, set(fs, f.Name, value, path))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// EnvName returns the environment variable holding the value of a flag.
+func EnvName(prefix, flagName string) string {
+	return prefix + "_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// CheckSecrets returns an error listing the given flags which still hold
+// their default value. The flags with an empty default are skipped, as leaving
+// them empty disables what they configure rather than sharing a known secret.
+func CheckSecrets(fs *flag.FlagSet, secrets ...string) error {
+	var defaults []string
+
+	for _, name := range secrets {
+		f := fs.Lookup(name)
+		if f != nil && f.DefValue != "" && f.Value.String() == f.DefValue {
+			defaults = append(defaults, f.Name)
+		}
+	}
+
+	if len(defaults) > 0 {
+		return fmt.Errorf("settings: refusing to use the default value of %s", strings.Join(defaults, ", "))
+	}
+
+	return nil
+}
+
+// Print writes the value of every flag of fs but the omitted ones to w as
+// YAML, which can be used as a configuration file. The values of the secrets
+// are replaced by a placeholder, and so are the passwords in DSNs.
+func Print(w io.Writer, fs *flag.FlagSet, secrets []string, omit ...string) error {
+	values := map[string]string{}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if slices.Contains(omit, f.Name) {
+			return
+		}
+
+		value := f.Value.String()
+
+		switch {
+		case value == "":
+		case slices.Contains(secrets, f.Name):
+			value = redacted
+		default:
+			value = rgxDSNPassword.ReplaceAllString(value, "$1:"+redacted+"@")
+		}
+
+		values[f.Name] = value
+	})
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, err := yaml.Marshal(values[name])
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "%s: %s", name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func set(fs *flag.FlagSet, name, value, source string) error {
+	err := fs.Set(name, value)
+	if err != nil {
+		return fmt.Errorf("settings: invalid value %q for %s from %s: %w", value, name, source, err)
+	}
+
+	return nil
+}
+
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]any
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(b, &data)
+	case ".toml":
+		err = toml.Unmarshal(b, &data)
+	default:
+		return nil, fmt.Errorf("settings: unsupported configuration file format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	flatten("", data, values)
+
+	return values, nil
+}
+
+func flatten(prefix string, data map[string]any, values map[string]string) {
+	for key, value := range data {
+		if prefix != "" {
+			key = prefix + "-" + key
+		}
+
+		switch v := value.(type) {
+		case map[string]any:
+			flatten(key, v, values)
+		case []any:
+			items := make([]string, len(v))
+			for i := range v {
+				items[i] = fmt.Sprint(v[i])
+			}
+			values[key] = strings.Join(items, " ")
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// StringList is a flag.Value holding a space separated list of strings.
+type StringList []string
+
+func (l *StringList) String() string {
+	if l == nil {
+		return ""
+	}
+
+	return strings.Join(*l, " ")
+}
+
+func (l *StringList) Set(value string) error {
+	*l = strings.Fields(value)
+	return nil
+}
@@ -0,0 +1,147 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/emersion/go-msgauth/dkim"
+	"github.com/wneessen/go-mail"
+)
+
+const dkimSignatureHeader mail.Header = "DKIM-Signature"
+
+// dkimHeaderKeys are the header fields covered by the signature, following
+// the recommendations of RFC 6376 section 5.4.1.
+var dkimHeaderKeys = []string{
+	"From",
+	"To",
+	"Subject",
+	"Date",
+	"Message-ID",
+	"MIME-Version",
+	"Content-Type",
+	"List-Unsubscribe",
+	"List-Unsubscribe-Post",
+}
+
+type dkimSigner struct {
+	domain   string
+	selector string
+	key      crypto.Signer
+}
+
+// EnableDKIM makes the mailer sign every outgoing message with the private key
+// stored in the PEM file at keyFile. When domain is empty the domain of the
+// sender address is used.
+func (m *Mailer) EnableDKIM(domain, selector, keyFile string) error {
+	if selector == "" {
+		return errors.New("dkim: selector must not be empty")
+	}
+
+	if domain == "" {
+		domain = m.domain
+	}
+
+	if !aligned(m.domain, domain) {
+		return fmt.Errorf("dkim: sender domain %q is not aligned with the signing domain %q", m.domain, domain)
+	}
+
+	pemBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+
+	key, err := parseDKIMPrivateKey(pemBytes)
+	if err != nil {
+		return err
+	}
+
+	m.dkim = &dkimSigner{
+		domain:   domain,
+		selector: selector,
+		key:      key,
+	}
+
+	return nil
+}
+
+// sign renders msg and adds a DKIM-Signature header to it. The message must
+// not change after it has been signed, so headers that go-mail would otherwise
+// generate on every render (Date, Message-ID and the MIME boundary) have to be
+// set before calling sign.
+func (s *dkimSigner) sign(msg *mail.Msg) error {
+	raw := new(bytes.Buffer)
+
+	_, err := msg.WriteTo(raw)
+	if err != nil {
+		return err
+	}
+
+	signer, err := dkim.NewSigner(&dkim.SignOptions{
+		Domain:                 s.domain,
+		Selector:               s.selector,
+		Signer:                 s.key,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             dkimHeaderKeys,
+	})
+	if err != nil {
+		return err
+	}
+	defer signer.Close()
+
+	_, err = signer.Write(raw.Bytes())
+	if err != nil {
+		return err
+	}
+
+	err = signer.Close()
+	if err != nil {
+		return err
+	}
+
+	signature := strings.TrimPrefix(signer.Signature(), string(dkimSignatureHeader)+": ")
+	signature = strings.TrimSuffix(signature, "\r\n")
+
+	msg.SetGenHeaderPreformatted(dkimSignatureHeader, signature)
+
+	return nil
+}
+
+func parseDKIMPrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("dkim: no PEM block found in private key file")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case ed25519.PrivateKey:
+			return key, nil
+		}
+
+		return nil, fmt.Errorf("dkim: unsupported private key type %T", key)
+
+	default:
+		return nil, fmt.Errorf("dkim: unsupported PEM block type %q", block.Type)
+	}
+}
@@ -0,0 +1,310 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-msgauth/dkim"
+	"github.com/wneessen/go-mail"
+)
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func encodePKCS8(t *testing.T, key any) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestParseDKIMPrivateKey(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	ed25519Key := newEd25519Key(t)
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pem     []byte
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{
+			name: "PKCS #1 RSA key",
+			pem:  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			want: rsaKey.Public(),
+		},
+		{
+			name: "PKCS #8 RSA key",
+			pem:  encodePKCS8(t, rsaKey),
+			want: rsaKey.Public(),
+		},
+		{
+			name: "PKCS #8 Ed25519 key",
+			pem:  encodePKCS8(t, ed25519Key),
+			want: ed25519Key.Public(),
+		},
+		{
+			name:    "PKCS #8 ECDSA key",
+			pem:     encodePKCS8(t, ecdsaKey),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported block type",
+			pem:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("certificate")}),
+			wantErr: true,
+		},
+		{
+			name:    "no PEM block",
+			pem:     []byte("not a key"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parseDKIMPrivateKey(tt.pem)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error; want one")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			public, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+			if !ok || !public.Equal(tt.want) {
+				t.Errorf("got a different key than the one encoded")
+			}
+		})
+	}
+}
+
+func TestDKIMSign(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	ed25519Key := newEd25519Key(t)
+
+	rsaPublic, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		record string
+	}{
+		{
+			name:   "RSA",
+			key:    rsaKey,
+			record: "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPublic),
+		},
+		{
+			name:   "Ed25519",
+			key:    ed25519Key,
+			record: "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(ed25519Key.Public().(ed25519.PublicKey)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := mail.NewMsg()
+
+			err := msg.From("Example <no-reply@example.org>")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = msg.To("alice@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg.Subject("Welcome")
+			msg.SetBodyString(mail.TypeTextPlain, "Hello Alice")
+			msg.SetGenHeader(mail.HeaderListUnsubscribe, "<https://example.org/unsubscribe>")
+			msg.SetMessageIDWithValue("1.abc@example.org")
+			msg.SetDate()
+			msg.SetBoundary("abc")
+
+			signer := &dkimSigner{domain: "example.org", selector: "mail", key: tt.key}
+
+			err = signer.sign(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			raw := new(bytes.Buffer)
+
+			_, err = msg.WriteTo(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lookups := 0
+
+			verifications, err := dkim.VerifyWithOptions(bytes.NewReader(raw.Bytes()), &dkim.VerifyOptions{
+				LookupTXT: func(domain string) ([]string, error) {
+					lookups++
+
+					if domain != "mail._domainkey.example.org" {
+						return nil, errors.New("no such record")
+					}
+
+					return []string{tt.record}, nil
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(verifications) != 1 {
+				t.Fatalf("got %d signatures; want 1", len(verifications))
+			}
+
+			verification := verifications[0]
+
+			if verification.Err != nil {
+				t.Fatalf("got verification error %q; want none", verification.Err)
+			}
+
+			if verification.Domain != "example.org" {
+				t.Errorf("got signing domain %q; want %q", verification.Domain, "example.org")
+			}
+
+			for _, key := range []string{"From", "Message-ID", "List-Unsubscribe"} {
+				if !containsFold(verification.HeaderKeys, key) {
+					t.Errorf("%s is not covered by the signature", key)
+				}
+			}
+
+			if lookups != 1 {
+				t.Errorf("got %d key lookups; want 1", lookups)
+			}
+		})
+	}
+}
+
+func TestEnableDKIM(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "dkim.pem")
+
+	err := os.WriteFile(keyFile, encodePKCS8(t, newEd25519Key(t)), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		sender  string
+		domain  string
+		want    string
+		wantErr bool
+	}{
+		{name: "sender domain", sender: "example.org", domain: "", want: "example.org"},
+		{name: "parent domain", sender: "mail.example.org", domain: "example.org", want: "example.org"},
+		{name: "other domain", sender: "example.org", domain: "example.com", wantErr: true},
+		{name: "subdomain", sender: "example.org", domain: "mail.example.org", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Mailer{domain: tt.sender}
+
+			err := m.EnableDKIM(tt.domain, "mail", keyFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error; want one")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if m.dkim.domain != tt.want {
+				t.Errorf("got signing domain %q; want %q", m.dkim.domain, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSender(t *testing.T) {
+	signing := &Mailer{dkim: &dkimSigner{domain: "example.org"}}
+
+	tests := []struct {
+		name   string
+		mailer *Mailer
+		sender string
+		want   error
+	}{
+		{name: "signing domain", mailer: signing, sender: "Acme <hr@example.org>"},
+		{name: "signing domain in upper case", mailer: signing, sender: "hr@EXAMPLE.ORG"},
+		{name: "subdomain", mailer: signing, sender: "hr@acme.example.org"},
+		{name: "other domain", mailer: signing, sender: "hr@acme.com", want: ErrUnalignedSender},
+		{name: "domain with the same suffix", mailer: signing, sender: "hr@badexample.org", want: ErrUnalignedSender},
+		{name: "without DKIM", mailer: &Mailer{}, sender: "hr@acme.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.mailer.CheckSender(tt.sender)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got error %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
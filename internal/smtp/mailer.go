@@ -2,50 +2,201 @@ package smtp
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/brGuirra/uai/assets"
 	"github.com/brGuirra/uai/internal/funcs"
 
-	"github.com/wneessen/go-mail"
+	gomail "github.com/wneessen/go-mail"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	htmlTemplate "html/template"
 	textTemplate "text/template"
 )
 
-const defaultTimeout = 10 * time.Second
+const (
+	defaultTimeout = 10 * time.Second
+
+	tracerName = "github.com/brGuirra/uai/internal/smtp"
+)
+
+// ErrUnalignedSender is returned when sending on behalf of an address whose
+// domain is not aligned with the DKIM signing domain. DMARC would fail for
+// such emails, so they are refused instead of being delivered to spam.
+var ErrUnalignedSender = errors.New("smtp: sender domain is not aligned with the DKIM signing domain")
 
 type Mailer struct {
-	client mail.Client
+	client gomail.Client
+	host   string
+	opts   []gomail.Option
 	from   string
+	domain string
+	dkim   *dkimSigner
+	sent   atomic.Int64
+	failed atomic.Int64
 }
 
+// NewMailer creates a mailer that delivers through the given SMTP server. When
+// username is empty no authentication is attempted and STARTTLS becomes
+// opportunistic, which allows using a local SMTP sink during development.
 func NewMailer(host string, port int, username, password, from string) (*Mailer, error) {
-	client, err := mail.NewClient(host, mail.WithTimeout(defaultTimeout), mail.WithSMTPAuth(mail.SMTPAuthLogin), mail.WithPort(port), mail.WithUsername(username), mail.WithPassword(password))
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []gomail.Option{gomail.WithTimeout(defaultTimeout), gomail.WithPort(port)}
+
+	if username != "" {
+		opts = append(opts, gomail.WithSMTPAuth(gomail.SMTPAuthLogin), gomail.WithUsername(username), gomail.WithPassword(password))
+	} else {
+		opts = append(opts, gomail.WithTLSPolicy(gomail.TLSOpportunistic))
+	}
+
+	client, err := gomail.NewClient(host, opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	mailer := &Mailer{
 		client: *client,
+		host:   host,
+		opts:   opts,
 		from:   from,
+		domain: addressDomain(sender),
 	}
 
 	return mailer, nil
 }
 
-func (m *Mailer) Send(recipient string, data any, patterns ...string) error {
+// Ping checks that the SMTP server accepts connections, going through the same
+// TLS and authentication steps as when sending an email. It uses its own
+// connection so it can run alongside Send.
+func (m *Mailer) Ping(ctx context.Context) error {
+	client, err := gomail.NewClient(m.host, m.opts...)
+	if err != nil {
+		return err
+	}
+
+	err = client.DialWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return client.Close()
+}
+
+// Send delivers a transactional email rendered from the given templates.
+func (m *Mailer) Send(ctx context.Context, recipient string, data any, patterns ...string) error {
+	return m.record(m.send(ctx, m.from, recipient, nil, data, patterns...))
+}
+
+// SendFrom delivers a transactional email like Send, on behalf of the given
+// sender, such as the address of an organization. The sender of the mailer is
+// used when it is empty.
+func (m *Mailer) SendFrom(ctx context.Context, sender, recipient string, data any, patterns ...string) error {
+	if sender == "" {
+		sender = m.from
+	}
+
+	return m.record(m.send(ctx, sender, recipient, nil, data, patterns...))
+}
+
+// SendNotification delivers a non-transactional email. Besides the regular
+// headers it carries List-Unsubscribe (with RFC 8058 one-click support) so mail
+// providers can offer the recipient a way to opt out.
+func (m *Mailer) SendNotification(ctx context.Context, recipient, unsubscribeURL string, data any, patterns ...string) error {
+	headers := map[gomail.Header]string{
+		gomail.HeaderListUnsubscribe:     fmt.Sprintf("<%s>", unsubscribeURL),
+		gomail.HeaderListUnsubscribePost: "List-Unsubscribe=One-Click",
+		gomail.HeaderPrecedence:          "bulk",
+	}
+
+	return m.record(m.send(ctx, m.from, recipient, headers, data, patterns...))
+}
+
+// CheckSender reports whether emails can be sent on behalf of sender. When
+// DKIM is enabled the sender domain must be the signing domain or one of its
+// subdomains, which is what DMARC relaxed alignment requires.
+func (m *Mailer) CheckSender(sender string) error {
+	address, err := mail.ParseAddress(sender)
+	if err != nil {
+		return err
+	}
+
+	if m.dkim != nil && !aligned(addressDomain(address), m.dkim.domain) {
+		return ErrUnalignedSender
+	}
+
+	return nil
+}
+
+// Stats returns how many emails were sent and how many could not be sent since
+// the mailer was created.
+func (m *Mailer) Stats() (sent, failed int64) {
+	return m.sent.Load(), m.failed.Load()
+}
+
+func (m *Mailer) record(err error) error {
+	if err != nil {
+		m.failed.Add(1)
+	} else {
+		m.sent.Add(1)
+	}
+
+	return err
+}
+
+func (m *Mailer) send(ctx context.Context, sender, recipient string, headers map[gomail.Header]string, data any, patterns ...string) (err error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "smtp send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("server.address", m.host),
+			attribute.StringSlice("email.templates", patterns),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+	}()
+
+	address, err := mail.ParseAddress(sender)
+	if err != nil {
+		return err
+	}
+
+	domain := addressDomain(address)
+
+	if m.dkim != nil && !aligned(domain, m.dkim.domain) {
+		return ErrUnalignedSender
+	}
+
 	for i := range patterns {
 		patterns[i] = "emails/" + patterns[i]
 	}
-	msg := mail.NewMsg()
+	msg := gomail.NewMsg()
 
-	err := msg.To(recipient)
+	err = msg.To(recipient)
 	if err != nil {
 		return err
 	}
 
-	err = msg.From(m.from)
+	err = msg.From(sender)
 	if err != nil {
 		return err
 	}
@@ -69,7 +220,7 @@ func (m *Mailer) Send(recipient string, data any, patterns ...string) error {
 		return err
 	}
 
-	msg.SetBodyString(mail.TypeTextPlain, plainBody.String())
+	msg.SetBodyString(gomail.TypeTextPlain, plainBody.String())
 
 	if ts.Lookup("htmlBody") != nil {
 		ts, err := htmlTemplate.New("").Funcs(funcs.TemplateFuncs).ParseFS(assets.EmbeddedFiles, patterns...)
@@ -83,20 +234,72 @@ func (m *Mailer) Send(recipient string, data any, patterns ...string) error {
 			return err
 		}
 
-		msg.AddAlternativeString(mail.TypeTextHTML, htmlBody.String())
+		msg.AddAlternativeString(gomail.TypeTextHTML, htmlBody.String())
+	}
+
+	for header, value := range headers {
+		msg.SetGenHeader(header, value)
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	msg.SetMessageIDWithValue(fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), token, domain))
+	msg.SetDate()
+	msg.SetBoundary(token)
+
+	if m.dkim != nil {
+		err = m.dkim.sign(msg)
+		if err != nil {
+			return err
+		}
 	}
 
 	for i := 1; i <= 3; i++ {
-		err = m.client.DialAndSend(msg)
+		err = m.client.DialAndSendWithContext(ctx, msg)
 
 		if nil == err {
 			return nil
 		}
 
+		span.AddEvent("send failed", trace.WithAttributes(attribute.Int("attempt", i), attribute.String("error", err.Error())))
+
 		if i != 3 {
-			time.Sleep(2 * time.Second)
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(2 * time.Second):
+			}
 		}
 	}
 
 	return err
 }
+
+// randomToken returns a random hex string used to build the Message-ID and the
+// MIME boundary. Both must be fixed before the message is signed, otherwise
+// go-mail generates new ones on every render and the signature breaks.
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// addressDomain returns the lower-cased domain of address.
+func addressDomain(address *mail.Address) string {
+	return strings.ToLower(address.Address[strings.LastIndex(address.Address, "@")+1:])
+}
+
+// aligned reports whether domain is the signing domain or a subdomain of it.
+func aligned(domain, signingDomain string) bool {
+	signingDomain = strings.ToLower(signingDomain)
+
+	return domain == signingDomain || strings.HasSuffix(domain, "."+signingDomain)
+}
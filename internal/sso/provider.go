@@ -0,0 +1,197 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrStateMismatch = errors.New("sso: state mismatch")
+	ErrNonceMismatch = errors.New("sso: nonce mismatch")
+	ErrMissingEmail  = errors.New("sso: ID token has no verified email")
+)
+
+// Config describes the client registered with the OpenID Connect provider.
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Provider signs users in with an OpenID Connect provider using the
+// authorization code flow with PKCE. The provider metadata is discovered on
+// first use, so the application starts even when the provider is down.
+type Provider struct {
+	config Config
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// Identity is the user the provider signed in.
+type Identity struct {
+	Issuer  string
+	Subject string
+	Email   string
+	Name    string
+
+	// AuthMethods are the authentication methods the provider reported in
+	// the amr claim, if any.
+	AuthMethods []string
+}
+
+// AuthRequest holds the values of an authentication request that must be kept
+// by the application until the user comes back from the provider.
+type AuthRequest struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+func NewProvider(cfg Config) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	return &Provider{config: cfg}
+}
+
+// NewAuthRequest generates the random values of a new authentication request.
+func NewAuthRequest() (AuthRequest, error) {
+	state, err := randomString()
+	if err != nil {
+		return AuthRequest{}, err
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		return AuthRequest{}, err
+	}
+
+	return AuthRequest{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+	}, nil
+}
+
+// AuthCodeURL returns the URL of the provider to redirect the user to.
+func (p *Provider) AuthCodeURL(ctx context.Context, req AuthRequest) (string, error) {
+	config, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return config.AuthCodeURL(req.State, oidc.Nonce(req.Nonce), oauth2.S256ChallengeOption(req.Verifier)), nil
+}
+
+// Exchange trades the authorization code the user came back with for an ID
+// token, which is validated against the provider keys, the client ID and the
+// nonce of req. state is the state the user came back with.
+func (p *Provider) Exchange(ctx context.Context, req AuthRequest, state, code string) (Identity, error) {
+	if subtle.ConstantTimeCompare([]byte(state), []byte(req.State)) != 1 {
+		return Identity{}, ErrStateMismatch
+	}
+
+	config, verifier, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(req.Verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("sso: exchanging code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, errors.New("sso: token response has no ID token")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, fmt.Errorf("sso: verifying ID token: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(req.Nonce)) != 1 {
+		return Identity{}, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email         string   `json:"email"`
+		EmailVerified bool     `json:"email_verified"`
+		Name          string   `json:"name"`
+		AMR           []string `json:"amr"`
+	}
+
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return Identity{}, fmt.Errorf("sso: decoding ID token claims: %w", err)
+	}
+
+	// Employees are matched by email, so an address the provider didn't
+	// verify, or didn't report as verified, could take over the account of
+	// another employee.
+	if claims.Email == "" || !claims.EmailVerified {
+		return Identity{}, ErrMissingEmail
+	}
+
+	return Identity{
+		Issuer:      idToken.Issuer,
+		Subject:     idToken.Subject,
+		Email:       strings.ToLower(claims.Email),
+		Name:        claims.Name,
+		AuthMethods: claims.AMR,
+	}, nil
+}
+
+// discover fetches the provider metadata the first time it is called, and
+// again after a failure.
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth2 != nil {
+		return p.oauth2, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.config.IssuerURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sso: discovering provider: %w", err)
+	}
+
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.config.Scopes,
+	}
+
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+
+	return p.oauth2, p.verifier, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
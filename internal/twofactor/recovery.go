@@ -0,0 +1,61 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// RecoveryCodeCount is the number of recovery codes generated at once.
+const RecoveryCodeCount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes returns a set of one-time recovery codes, formatted as
+// "xxxxx-xxxxx", along with the hashes to store in their place.
+func NewRecoveryCodes() (codes, hashes []string, err error) {
+	for range RecoveryCodeCount {
+		b := make([]byte, 10)
+
+		_, err = rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(recoveryEncoding.EncodeToString(b)[:10])
+
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the hash stored for a recovery code. Case, spaces
+// and dashes are ignored. The codes have enough entropy for a plain SHA-256 to
+// be safe, which also lets them be looked up by hash.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	sum := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// IsTOTPCode reports whether code looks like a TOTP code rather than a recovery
+// code.
+func IsTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
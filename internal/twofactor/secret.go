@@ -0,0 +1,91 @@
+package twofactor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MinKeyLength is the minimum length of the key of a SecretBox.
+const MinKeyLength = 32
+
+// sealedPrefix marks the sealed secrets, telling them apart from the ones
+// stored in plaintext before secrets were encrypted. Base32 secrets never
+// contain a colon.
+const sealedPrefix = "v1:"
+
+// ErrInvalidSecret is returned by Open for a secret sealed with another key or
+// for another employee, or altered since.
+var ErrInvalidSecret = errors.New("twofactor: secret can't be decrypted")
+
+// SecretBox encrypts TOTP secrets with AES-256-GCM, so they aren't disclosed
+// by a copy of the database. Every secret is bound to the employee it belongs
+// to, so it can't be moved to another one either.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox returns a SecretBox encrypting with a key derived from key,
+// which must be at least MinKeyLength characters long.
+func NewSecretBox(key string) (*SecretBox, error) {
+	if len(key) < MinKeyLength {
+		return nil, errors.New("twofactor: key must be at least 32 characters long")
+	}
+
+	sum := sha256.Sum256([]byte(key))
+
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts the secret of the employee for storage.
+func (b *SecretBox) Seal(secret string, employeeID uuid.UUID) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(secret), employeeID[:])
+
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a secret of the employee stored by Seal. A secret stored in
+// plaintext is returned as is, with sealed false, so the caller can seal it.
+func (b *SecretBox) Open(stored string, employeeID uuid.UUID) (secret string, sealed bool, err error) {
+	encoded, found := strings.CutPrefix(stored, sealedPrefix)
+	if !found {
+		return stored, false, nil
+	}
+
+	raw, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return "", true, ErrInvalidSecret
+	}
+
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, employeeID[:])
+	if err != nil {
+		return "", true, ErrInvalidSecret
+	}
+
+	return string(plaintext), true, nil
+}
@@ -0,0 +1,87 @@
+package twofactor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSecretBox(t *testing.T) {
+	box, err := NewSecretBox("x8tq2m5v7cgn3jk4p6wz9rbd2hf5ls7a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	employeeID := uuid.New()
+
+	stored, err := box.Seal(rfc6238Secret, employeeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(stored, rfc6238Secret) {
+		t.Fatalf("sealed secret %q holds the secret", stored)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		secret, sealed, err := box.Open(stored, employeeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !sealed || secret != rfc6238Secret {
+			t.Errorf("got %q and sealed %t; want %q and sealed", secret, sealed, rfc6238Secret)
+		}
+	})
+
+	t.Run("another employee", func(t *testing.T) {
+		_, _, err := box.Open(stored, uuid.New())
+		if !errors.Is(err, ErrInvalidSecret) {
+			t.Errorf("got error %v; want %v", err, ErrInvalidSecret)
+		}
+	})
+
+	t.Run("another key", func(t *testing.T) {
+		other, err := NewSecretBox("ccw3wg3ombip3656l672bgwm3svsz7sh")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = other.Open(stored, employeeID)
+		if !errors.Is(err, ErrInvalidSecret) {
+			t.Errorf("got error %v; want %v", err, ErrInvalidSecret)
+		}
+	})
+
+	t.Run("altered", func(t *testing.T) {
+		altered := stored[:len(stored)-2] + "AA"
+		if altered == stored {
+			altered = stored[:len(stored)-2] + "BB"
+		}
+
+		_, _, err := box.Open(altered, employeeID)
+		if !errors.Is(err, ErrInvalidSecret) {
+			t.Errorf("got error %v; want %v", err, ErrInvalidSecret)
+		}
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+		secret, sealed, err := box.Open(rfc6238Secret, employeeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sealed || secret != rfc6238Secret {
+			t.Errorf("got %q and sealed %t; want %q and not sealed", secret, sealed, rfc6238Secret)
+		}
+	})
+}
+
+func TestNewSecretBoxShortKey(t *testing.T) {
+	_, err := NewSecretBox("too short")
+	if err == nil {
+		t.Fatal("got no error; want one")
+	}
+}
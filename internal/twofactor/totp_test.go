@@ -0,0 +1,74 @@
+package twofactor
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the test vectors of RFC 6238 appendix B,
+// the ASCII string "12345678901234567890", base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The test vectors of RFC 6238 appendix B for SHA-1. The codes are the last
+// six digits of the eight digit codes of the RFC, as truncating to six digits
+// keeps the same binary code modulo 10^6.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{unix: 59, code: "287082"},
+	{unix: 1111111109, code: "081804"},
+	{unix: 1111111111, code: "050471"},
+	{unix: 1234567890, code: "005924"},
+	{unix: 2000000000, code: "279037"},
+	{unix: 20000000000, code: "353130"},
+}
+
+func TestValidateRFC6238Vectors(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		now := time.Unix(v.unix, 0)
+
+		step, ok := Validate(rfc6238Secret, v.code, 0, now)
+		if !ok {
+			t.Errorf("%d: code %s rejected", v.unix, v.code)
+			continue
+		}
+
+		if want := v.unix / period; step != want {
+			t.Errorf("%d: got step %d; want %d", v.unix, step, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	// The code of the RFC 6238 vector at 1111111109, in step 37037036.
+	const code = "081804"
+
+	at := time.Unix(1111111109, 0)
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		now      time.Time
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: code, now: at, wantStep: 37037036, wantOK: true},
+		{name: "previous step", code: code, now: at.Add(period * time.Second), wantStep: 37037036, wantOK: true},
+		{name: "next step", code: code, now: at.Add(-period * time.Second), wantStep: 37037036, wantOK: true},
+		{name: "outside the skew", code: code, now: at.Add(2 * period * time.Second)},
+		{name: "step already used", code: code, lastStep: 37037036, now: at},
+		{name: "wrong code", code: "123456", now: at},
+		{name: "empty code", code: "", now: at},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfc6238Secret, tt.code, tt.lastStep, tt.now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("got step %d and %t; want step %d and %t", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
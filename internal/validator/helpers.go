@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"golang.org/x/exp/constraints"
@@ -84,3 +85,8 @@ func IsURL(value string) bool {
 
 	return u.Scheme != "" && u.Host != ""
 }
+
+func IsDate(value string) bool {
+	_, err := time.Parse(time.DateOnly, value)
+	return err == nil
+}
@@ -1,40 +1,53 @@
 package validator
 
+// Error is a single validation failure. Code is a stable, machine-readable
+// identifier such as "email_taken"; Detail is the human-readable explanation.
+type Error struct {
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
 type Validator struct {
-	Errors      []string          `json:",omitempty"`
-	FieldErrors map[string]string `json:",omitempty"`
+	Errors      []Error            `json:",omitempty"`
+	FieldErrors map[string][]Error `json:",omitempty"`
 }
 
 func (v Validator) HasErrors() bool {
 	return len(v.Errors) != 0 || len(v.FieldErrors) != 0
 }
 
-func (v *Validator) AddError(message string) {
+func (v *Validator) AddError(code, message string) {
 	if v.Errors == nil {
-		v.Errors = []string{}
+		v.Errors = []Error{}
 	}
 
-	v.Errors = append(v.Errors, message)
+	v.Errors = append(v.Errors, Error{Code: code, Detail: message})
 }
 
-func (v *Validator) AddFieldError(key, message string) {
+// AddFieldError records an error for the field key. A field collects every
+// failed check, except repeated ones with the same code.
+func (v *Validator) AddFieldError(key, code, message string) {
 	if v.FieldErrors == nil {
-		v.FieldErrors = map[string]string{}
+		v.FieldErrors = map[string][]Error{}
 	}
 
-	if _, exists := v.FieldErrors[key]; !exists {
-		v.FieldErrors[key] = message
+	for _, err := range v.FieldErrors[key] {
+		if err.Code == code {
+			return
+		}
 	}
+
+	v.FieldErrors[key] = append(v.FieldErrors[key], Error{Code: code, Detail: message})
 }
 
-func (v *Validator) Check(ok bool, message string) {
+func (v *Validator) Check(ok bool, code, message string) {
 	if !ok {
-		v.AddError(message)
+		v.AddError(code, message)
 	}
 }
 
-func (v *Validator) CheckField(ok bool, key, message string) {
+func (v *Validator) CheckField(ok bool, key, code, message string) {
 	if !ok {
-		v.AddFieldError(key, message)
+		v.AddFieldError(key, code, message)
 	}
 }
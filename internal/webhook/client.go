@@ -0,0 +1,237 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	SignatureHeader = "X-UAI-Signature"
+	EventHeader     = "X-UAI-Event"
+	DeliveryHeader  = "X-UAI-Delivery"
+
+	// MaxAttempts is the number of times an event is delivered to an endpoint
+	// failing to receive it, before it is given up.
+	MaxAttempts = 5
+
+	defaultTimeout = 10 * time.Second
+	retryBackoff   = time.Minute
+
+	tracerName = "github.com/brGuirra/uai/internal/webhook"
+)
+
+var (
+	// ErrForbiddenAddress is returned for the endpoints on a private, loopback,
+	// link-local or other non-public address, which would let the deliveries
+	// reach the network of the application.
+	ErrForbiddenAddress = errors.New("webhook: endpoint on a private, loopback or link-local address")
+
+	ErrUnsupportedScheme = errors.New("webhook: endpoint URL scheme is not http or https")
+)
+
+// Attempt describes the outcome of a single delivery attempt. StatusCode is
+// zero when no response was received.
+type Attempt struct {
+	Number     int
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+type Client struct {
+	http      *http.Client
+	userAgent string
+}
+
+// NewClient returns a client refusing to connect to the addresses CheckURL
+// rejects. The address is checked when it is dialled, so an endpoint whose DNS
+// records changed since it was checked, or redirecting, can't reach them
+// either. Proxies are not used, as they would dial in place of the client.
+func NewClient(userAgent string) *Client {
+	dialer := &net.Dialer{
+		Timeout: defaultTimeout,
+		Control: checkDialAddress,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Client{
+		http: &http.Client{
+			Timeout:   defaultTimeout,
+			Transport: transport,
+		},
+		userAgent: userAgent,
+	}
+}
+
+// Backoff returns how long to wait after the given failed attempt before the
+// next one. It doubles with every attempt.
+func Backoff(attempt int) time.Duration {
+	return retryBackoff << (attempt - 1)
+}
+
+// CheckURL checks that rawURL is an http or https URL whose host resolves to
+// public addresses only.
+func CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrUnsupportedScheme
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if !isPublic(addr.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+
+	return nil
+}
+
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublic(ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// nonPublicNetworks are the ranges that aren't routed on the internet, and that
+// the methods of net.IP don't cover: the shared address space of carrier-grade
+// NAT, from RFC 6598, and the benchmarking range of RFC 2544.
+var nonPublicNetworks = []*net.IPNet{
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	{IP: net.IPv4(198, 18, 0, 0), Mask: net.CIDRMask(15, 32)},
+}
+
+func isPublic(ip net.IP) bool {
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return !ip.IsPrivate() &&
+		!ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// Sign returns the value of the signature header for payload. The timestamp is
+// part of the signed content so receivers can reject replayed requests.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return fmt.Sprintf("t=%s,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Deliver makes the given attempt at posting payload to url. The attempt is
+// traced, and the trace context is propagated to the receiver. Scheduling the
+// next attempt when it fails is up to the caller, with Backoff.
+func (c *Client) Deliver(ctx context.Context, url, secret, eventType, deliveryID string, payload []byte, number int) Attempt {
+	attempt := c.post(ctx, url, secret, eventType, deliveryID, payload, number)
+	attempt.Number = number
+
+	return attempt
+}
+
+func (c *Client) post(ctx context.Context, url, secret, eventType, deliveryID string, payload []byte, number int) (attempt Attempt) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "webhook delivery",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodPost),
+			attribute.String("url.full", url),
+			attribute.String("webhook.event", eventType),
+			attribute.String("webhook.delivery", deliveryID),
+			attribute.Int("webhook.attempt", number),
+		),
+	)
+	defer func() {
+		if attempt.StatusCode != 0 {
+			span.SetAttributes(attribute.Int("http.response.status_code", attempt.StatusCode))
+		}
+
+		if attempt.Err != nil {
+			span.RecordError(attempt.Err)
+			span.SetStatus(codes.Error, attempt.Err.Error())
+		}
+
+		span.End()
+	}()
+
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return Attempt{Err: err}
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Sign(secret, start, payload))
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return Attempt{Duration: time.Since(start), Err: err}
+	}
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	attempt = Attempt{
+		StatusCode: res.StatusCode,
+		Duration:   time.Since(start),
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		attempt.Err = fmt.Errorf("webhook: unexpected status code %d", res.StatusCode)
+	}
+
+	return attempt
+}
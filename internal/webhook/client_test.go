@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	payload := []byte(`{"type":"employee.created"}`)
+
+	got := Sign("whsec_test", timestamp, payload)
+	want := "t=1700000000,v1=a55a9bcd7a178a1ca7cc4e6131ec9c62a5f4ec0eec9a24d0e909d4cf8a0a1fe5"
+
+	if got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	if other := Sign("whsec_test", timestamp.Add(time.Second), payload); other == got {
+		t.Error("got the same signature at another time; want the timestamp signed")
+	}
+
+	if other := Sign("whsec_other", timestamp, payload); other == got {
+		t.Error("got the same signature with another secret")
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{name: "public", url: "https://93.184.216.34/hooks"},
+		{name: "public IPv6", url: "https://[2606:2800:220:1:248:1893:25c8:1946]/hooks"},
+		{name: "http", url: "http://93.184.216.34/hooks"},
+		{name: "other scheme", url: "ftp://93.184.216.34/hooks", wantErr: ErrUnsupportedScheme},
+		{name: "loopback", url: "http://127.0.0.1:8080/hooks", wantErr: ErrForbiddenAddress},
+		{name: "loopback IPv6", url: "http://[::1]/hooks", wantErr: ErrForbiddenAddress},
+		{name: "private", url: "https://10.0.0.5/hooks", wantErr: ErrForbiddenAddress},
+		{name: "private IPv6", url: "https://[fd00::1]/hooks", wantErr: ErrForbiddenAddress},
+		{name: "link-local", url: "http://169.254.169.254/latest/meta-data", wantErr: ErrForbiddenAddress},
+		{name: "unspecified", url: "http://0.0.0.0/hooks", wantErr: ErrForbiddenAddress},
+		{name: "carrier-grade NAT", url: "https://100.100.0.1/hooks", wantErr: ErrForbiddenAddress},
+		{name: "benchmarking", url: "https://198.19.255.1/hooks", wantErr: ErrForbiddenAddress},
+		{name: "IPv4-mapped private", url: "https://[::ffff:192.168.1.1]/hooks", wantErr: ErrForbiddenAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckURL(context.Background(), tt.url)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v; want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: time.Minute},
+		{attempt: 2, want: 2 * time.Minute},
+		{attempt: 3, want: 4 * time.Minute},
+		{attempt: MaxAttempts - 1, want: 8 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt); got != tt.want {
+			t.Errorf("attempt %d: got %s; want %s", tt.attempt, got, tt.want)
+		}
+	}
+}